@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// checkIngredientAvailability asks the recipe service which ingredients the
+// catalog can't supply. By default that is one call for every ingredient.
+// With availabilityCheckBatchSize set the ingredients are checked in batches,
+// at most availabilityCheckConcurrency at a time, so a problem ingredient
+// fails only its own batch. Ingredients whose batch failed are returned as
+// unchecked, and the whole check shares availabilityCheckTimeout.
+func (fe *frontendServer) checkIngredientAvailability(ctx context.Context, log logrus.FieldLogger, userID string, names []string) (unmatched, unchecked []string) {
+	ctx, cancel := context.WithTimeout(ctx, availabilityCheckTimeout)
+	defer cancel()
+
+	batches := batchIngredients(names, availabilityCheckBatchSize)
+	results := make([]*pb.ProcessRecipeResponse, len(batches))
+	sem := make(chan struct{}, max(availabilityCheckConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			resp, err := fe.recipeService().ProcessRecipeRequest(ctx, &pb.ProcessRecipeRequestMessage{
+				Message: fmt.Sprintf("Check ingredient availability: %s", strings.Join(batch, ", ")),
+				UserId:  userID,
+			})
+			if err != nil {
+				log.WithError(err).WithField("ingredients", batch).Warn("ingredient availability check failed")
+				return
+			}
+			results[i] = resp
+		}()
+	}
+	wg.Wait()
+
+	for i, resp := range results {
+		if resp == nil {
+			unchecked = append(unchecked, batches[i]...)
+			continue
+		}
+		unmatched = append(unmatched, resp.GetUnmatchedIngredients()...)
+	}
+	return unmatched, unchecked
+}
+
+// batchIngredients splits names into batches of at most size names. A size
+// of zero or less puts them all in one batch.
+func batchIngredients(names []string, size int) [][]string {
+	if len(names) == 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = len(names)
+	}
+	var batches [][]string
+	for start := 0; start < len(names); start += size {
+		batches = append(batches, names[start:min(start+size, len(names))])
+	}
+	return batches
+}
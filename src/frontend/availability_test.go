@@ -0,0 +1,120 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestBatchIngredients(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e"}
+	tests := []struct {
+		size int
+		want [][]string
+	}{
+		{0, [][]string{{"a", "b", "c", "d", "e"}}},
+		{2, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{5, [][]string{{"a", "b", "c", "d", "e"}}},
+		{10, [][]string{{"a", "b", "c", "d", "e"}}},
+	}
+	for _, tt := range tests {
+		if got := batchIngredients(names, tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("batchIngredients(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+	if got := batchIngredients(nil, 2); got != nil {
+		t.Errorf("got %q for no ingredients, want no batches", got)
+	}
+}
+
+func TestCheckIngredientAvailabilityMergesBatches(t *testing.T) {
+	defer func(size, concurrency int) {
+		availabilityCheckBatchSize, availabilityCheckConcurrency = size, concurrency
+	}(availabilityCheckBatchSize, availabilityCheckConcurrency)
+	availabilityCheckBatchSize, availabilityCheckConcurrency = 2, 2
+
+	fe, backends := newTestFrontend(t)
+	var mu sync.Mutex
+	running, most := 0, 0
+	backends.recipe.process = func(_ context.Context, req *pb.ProcessRecipeRequestMessage) (*pb.ProcessRecipeResponse, error) {
+		mu.Lock()
+		running++
+		most = max(most, running)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		list := strings.TrimPrefix(req.GetMessage(), "Check ingredient availability: ")
+		if strings.Contains(list, "Durian") {
+			return nil, errors.New("could not parse durian")
+		}
+		var unmatched []string
+		for _, name := range strings.Split(list, ", ") {
+			if strings.HasPrefix(name, "Saffron") {
+				unmatched = append(unmatched, name)
+			}
+		}
+		return &pb.ProcessRecipeResponse{Success: true, UnmatchedIngredients: unmatched}, nil
+	}
+
+	names := []string{"Saffron Threads", "Rice", "Durian", "Onion", "Saffron Powder", "Stock", "Peas"}
+	unmatched, unchecked := fe.checkIngredientAvailability(context.Background(), log, testSessionID, names)
+
+	if want := []string{"Saffron Threads", "Saffron Powder"}; !reflect.DeepEqual(unmatched, want) {
+		t.Errorf("got unmatched %q, want %q", unmatched, want)
+	}
+	if want := []string{"Durian", "Onion"}; !reflect.DeepEqual(unchecked, want) {
+		t.Errorf("got unchecked %q, want %q", unchecked, want)
+	}
+	if n := len(backends.recipe.processRequests()); n != 4 {
+		t.Errorf("made %d availability calls, want 4", n)
+	}
+	if most > 2 {
+		t.Errorf("ran %d checks at once, want at most 2", most)
+	}
+}
+
+func TestCheckIngredientAvailabilityDefaultsToOneCall(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.recipe.process = func(context.Context, *pb.ProcessRecipeRequestMessage) (*pb.ProcessRecipeResponse, error) {
+		return &pb.ProcessRecipeResponse{Success: true}, nil
+	}
+
+	var names []string
+	for i := 0; i < 20; i++ {
+		names = append(names, fmt.Sprintf("Ingredient %d", i))
+	}
+	unmatched, unchecked := fe.checkIngredientAvailability(context.Background(), log, testSessionID, names)
+
+	if len(unmatched) != 0 || len(unchecked) != 0 {
+		t.Errorf("got unmatched %q and unchecked %q, want neither", unmatched, unchecked)
+	}
+	if n := len(backends.recipe.processRequests()); n != 1 {
+		t.Errorf("made %d availability calls, want 1", n)
+	}
+}
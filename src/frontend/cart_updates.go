@@ -0,0 +1,937 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/money"
+)
+
+var (
+	// How long an SSE cart update stream may sit idle before it is pinged,
+	// so proxies with a 30-60s read timeout keep it open, and how often
+	// WebSocket clients are pinged, busy or not. Set by
+	// SSE_HEARTBEAT_INTERVAL and kept below cartUpdatePongWait.
+	cartUpdateKeepalive = 15 * time.Second
+
+	// How long a WebSocket client may go without answering a ping.
+	cartUpdatePongWait = 60 * time.Second
+
+	// How many more times a stream's initial cart read is tried, and how
+	// long it waits between tries, before telling the client to refetch.
+	cartUpdateInitialRetries    = 2
+	cartUpdateInitialRetryDelay = 250 * time.Millisecond
+)
+
+const cartUpdateWriteWait = 10 * time.Second
+
+// cartPushTimeout bounds a background push of a changed cart, which has no
+// request deadline to inherit.
+const cartPushTimeout = 5 * time.Second
+
+// cartUpdateHub fans cart updates out to every client subscribed for a user.
+// The zero value is ready to use.
+type cartUpdateHub struct {
+	mu            sync.Mutex
+	clients       map[string]map[chan CartUpdate]struct{} // userID -> subscribed clients
+	announcements map[chan Announcement]struct{}
+	currencies    map[string]string // userID -> currency of the latest client
+	lastSeq       uint64
+
+	// Closed when shutdown begins, to have clients reconnect elsewhere, and
+	// when it ends, to end the streams still open. Made on first use.
+	draining, closed chan struct{}
+}
+
+// nextSeq reserves a sequence number for a cart that is about to be read.
+// Numbers follow the order reads begin in, not the order they finish in, so
+// an overlapping read that started earlier can still see the newer cart.
+func (h *cartUpdateHub) nextSeq() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSeq++
+	return h.lastSeq
+}
+
+// subscribe registers a new client for userID. The returned function
+// unregisters it.
+func (h *cartUpdateHub) subscribe(userID string) (<-chan CartUpdate, func()) {
+	ch := make(chan CartUpdate, cartUpdateBufferSize)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients == nil {
+		h.clients = make(map[string]map[chan CartUpdate]struct{})
+	}
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[chan CartUpdate]struct{})
+	}
+	h.clients[userID][ch] = struct{}{}
+	cartUpdateClientsActive.Inc()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.clients[userID][ch]; !ok {
+			return
+		}
+		cartUpdateClientsActive.Dec()
+		delete(h.clients[userID], ch)
+		if len(h.clients[userID]) == 0 {
+			delete(h.clients, userID)
+			delete(h.currencies, userID)
+		}
+	}
+}
+
+// setCurrency records the currency userID's client asked for, so updates
+// pushed without a request can be priced in it.
+func (h *cartUpdateHub) setCurrency(userID, currency string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.currencies == nil {
+		h.currencies = make(map[string]string)
+	}
+	h.currencies[userID] = currency
+}
+
+// currency is the one recorded for userID, or defaultCurrency if there is
+// none.
+func (h *cartUpdateHub) currency(userID string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if c, ok := h.currencies[userID]; ok {
+		return c
+	}
+	return defaultCurrency
+}
+
+// drainSignals returns the channels closed when shutdown begins and ends.
+func (h *cartUpdateHub) drainSignals() (draining, closed <-chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.initDrainSignals()
+	return h.draining, h.closed
+}
+
+// initDrainSignals makes the drain channels if they don't exist yet. h.mu
+// must be held.
+func (h *cartUpdateHub) initDrainSignals() {
+	if h.draining == nil {
+		h.draining = make(chan struct{})
+		h.closed = make(chan struct{})
+	}
+}
+
+// beginDrain tells every stream, including ones opened from now on, to have
+// its client reconnect.
+func (h *cartUpdateHub) beginDrain() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.initDrainSignals()
+	closeSignal(h.draining)
+}
+
+// closeStreams ends every stream, including ones opened from now on.
+func (h *cartUpdateHub) closeStreams() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.initDrainSignals()
+	closeSignal(h.draining)
+	closeSignal(h.closed)
+}
+
+// closeSignal closes ch unless it already is. Callers hold the lock that
+// guards ch.
+func closeSignal(ch chan struct{}) {
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
+// hasClients reports whether userID has any cart update clients.
+func (h *cartUpdateHub) hasClients(userID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients[userID]) > 0
+}
+
+// clientCount is how many cart update clients are subscribed.
+func (h *cartUpdateHub) clientCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, clients := range h.clients {
+		n += len(clients)
+	}
+	return n
+}
+
+// publish hands update to each of userID's clients without blocking, and
+// returns how many clients there were and how many of them were behind. Each
+// update carries the whole cart, so when a client's buffer is full its oldest
+// pending update is discarded to make room: a slow client skips intermediate
+// states but always ends up with the latest cart.
+func (h *cartUpdateHub) publish(userID string, update CartUpdate) (clients, coalesced int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients[userID] {
+		select {
+		case ch <- update:
+			continue
+		default:
+		}
+		// Only publish sends, and it holds h.mu, so after taking one
+		// update out (or the client doing so) there is room for this one.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- update
+		coalesced++
+	}
+	return len(h.clients[userID]), coalesced
+}
+
+// subscribeAnnouncements registers a client for announcements, which go to
+// every client regardless of user. The returned function unregisters it.
+func (h *cartUpdateHub) subscribeAnnouncements() (<-chan Announcement, func()) {
+	ch := make(chan Announcement, cartUpdateBufferSize)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.announcements == nil {
+		h.announcements = make(map[chan Announcement]struct{})
+	}
+	h.announcements[ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.announcements, ch)
+	}
+}
+
+// broadcast hands a to every announcement client without blocking and
+// returns how many clients got it. Announcements are separate events rather
+// than snapshots, so a client whose buffer is full misses this one.
+func (h *cartUpdateHub) broadcast(a Announcement) (delivered int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.announcements {
+		select {
+		case ch <- a:
+			delivered++
+		default:
+		}
+	}
+	return delivered
+}
+
+// reconnectLimiter throttles users whose cart update clients reconnect too
+// often, such as a buggy client reconnecting in a tight loop. It remembers
+// each user's connections within the last cartUpdateReconnectWindow. The zero
+// value is ready to use.
+type reconnectLimiter struct {
+	mu        sync.Mutex
+	conns     map[string][]time.Time // userID -> connection times, oldest first
+	lastSweep time.Time
+}
+
+// allow records a connection by userID at now if the user has made fewer
+// than cartUpdateMaxReconnects in the window. Otherwise it reports how long
+// until the oldest of them leaves the window. Refused connections aren't
+// recorded.
+func (l *reconnectLimiter) allow(userID string, now time.Time) (bool, time.Duration) {
+	if cartUpdateMaxReconnects <= 0 || cartUpdateReconnectWindow <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns == nil {
+		l.conns = make(map[string][]time.Time)
+	}
+	cutoff := now.Add(-cartUpdateReconnectWindow)
+	// Drop users who stopped connecting so the map doesn't grow forever.
+	if now.Sub(l.lastSweep) >= cartUpdateReconnectWindow {
+		for u, times := range l.conns {
+			if !times[len(times)-1].After(cutoff) {
+				delete(l.conns, u)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	times := l.conns[userID]
+	for len(times) > 0 && !times[0].After(cutoff) {
+		times = times[1:]
+	}
+	if len(times) >= cartUpdateMaxReconnects {
+		l.conns[userID] = times
+		return false, times[0].Sub(cutoff)
+	}
+	l.conns[userID] = append(times, now)
+	return true, 0
+}
+
+// throttleReconnects answers 429 and returns false if userID has reconnected
+// to the cart update stream too often.
+func (fe *frontendServer) throttleReconnects(w http.ResponseWriter, log logrus.FieldLogger, userID string) bool {
+	ok, retryAfter := fe.cartUpdateReconnects.allow(userID, time.Now())
+	if ok {
+		return true
+	}
+	secs := int((retryAfter + time.Second - 1) / time.Second)
+	log.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"retry_after": secs,
+	}).Warn("cart update client is reconnecting too often, throttling")
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, "too many cart update reconnects", http.StatusTooManyRequests)
+	return false
+}
+
+// logSampler keeps routine logs from flooding the output when the events
+// behind them are frequent. It lets a log line through at most once per
+// interval per key and counts the ones it holds back. The interval is
+// cartUpdateLogInterval unless the caller passes its own. The zero value is
+// ready to use.
+type logSampler struct {
+	mu        sync.Mutex
+	entries   map[string]sampledLog
+	lastSweep time.Time
+}
+
+type sampledLog struct {
+	logged     time.Time // when the line was last let through
+	seen       time.Time // when the line was last asked for
+	suppressed int
+}
+
+// allow reports whether the line for key may be logged at now, and if so how
+// many were held back since the last one.
+func (s *logSampler) allow(key string, now time.Time) (bool, int) {
+	return s.allowEvery(key, now, cartUpdateLogInterval)
+}
+
+// allowEvery is allow with the given interval. Zero lets every line through.
+func (s *logSampler) allowEvery(key string, now time.Time, interval time.Duration) (bool, int) {
+	if interval <= 0 {
+		return true, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[string]sampledLog)
+	}
+	// Drop keys that have gone quiet so the map doesn't grow forever. Their
+	// next line is let through anyway.
+	if now.Sub(s.lastSweep) >= interval {
+		for k, e := range s.entries {
+			if now.Sub(e.seen) >= interval {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	e, ok := s.entries[key]
+	if ok && now.Sub(e.logged) < interval {
+		e.seen = now
+		e.suppressed++
+		s.entries[key] = e
+		return false, 0
+	}
+	s.entries[key] = sampledLog{logged: now, seen: now}
+	return true, e.suppressed
+}
+
+// sample returns l, annotated with how many lines were skipped, if the line
+// for key may be logged now.
+func (s *logSampler) sample(l logrus.FieldLogger, key string) (logrus.FieldLogger, bool) {
+	return s.sampleEvery(l, key, cartUpdateLogInterval)
+}
+
+// sampleEvery is sample with the given interval.
+func (s *logSampler) sampleEvery(l logrus.FieldLogger, key string, interval time.Duration) (logrus.FieldLogger, bool) {
+	ok, suppressed := s.allowEvery(key, time.Now(), interval)
+	if ok && suppressed > 0 {
+		l = l.WithField("suppressed", suppressed)
+	}
+	return l, ok
+}
+
+// productNameCache remembers product names and prices for productNameCacheTTL
+// so that fanning out cart updates doesn't look up the same product over and
+// over.
+// It holds at most one entry per catalog product. The zero value is ready to
+// use.
+type productNameCache struct {
+	mu      sync.Mutex
+	entries map[string]productNameEntry
+}
+
+type productNameEntry struct {
+	name     string
+	priceUSD *pb.Money
+	expires  time.Time
+}
+
+func (c *productNameCache) get(productID string, now time.Time) (string, bool) {
+	e, ok := c.lookup(productID, now)
+	return e.name, ok
+}
+
+func (c *productNameCache) lookup(productID string, now time.Time) (productNameEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[productID]
+	if !ok || !now.Before(e.expires) {
+		return productNameEntry{}, false
+	}
+	return e, true
+}
+
+func (c *productNameCache) set(p *pb.Product, now time.Time) {
+	if productNameCacheTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]productNameEntry)
+	}
+	c.entries[p.GetId()] = productNameEntry{name: p.GetName(), priceUSD: p.GetPriceUsd(), expires: now.Add(productNameCacheTTL)}
+}
+
+// cartUpdateSender writes cart updates to one connected client. SSE and
+// WebSocket each implement it so they can share streamCartUpdates.
+type cartUpdateSender interface {
+	send(CartUpdate) error
+	announce(Announcement) error
+	keepalive() error
+	// keepaliveWhenIdle reports whether keepalives are only needed while
+	// nothing else is being sent, rather than at a fixed interval.
+	keepaliveWhenIdle() bool
+	// cartUnavailable tells the client the initial cart couldn't be read,
+	// so it should fetch the cart itself rather than assume it is empty.
+	cartUnavailable() error
+	// reconnect tells the client this frontend is shutting down, so it
+	// should reconnect, which gets it another replica.
+	reconnect() error
+}
+
+// streamCartUpdates sends the user's current cart and then every published
+// update until ctx is done or a write fails. Updates that are no newer than
+// one already sent are skipped, so the client never goes back to an older
+// cart. That includes updates published while the initial cart was being
+// read but whose own read started before it.
+func (fe *frontendServer) streamCartUpdates(ctx context.Context, log logrus.FieldLogger, userID, currency string, sender cartUpdateSender) {
+	updates, unsubscribe := fe.cartUpdateClients.subscribe(userID)
+	defer unsubscribe()
+	fe.cartUpdateClients.setCurrency(userID, currency)
+	announcements, unsubscribeAnnouncements := fe.cartUpdateClients.subscribeAnnouncements()
+	defer unsubscribeAnnouncements()
+	draining, closed := fe.cartUpdateClients.drainSignals()
+
+	var last uint64
+	seq, cart, err := fe.initialCart(ctx, userID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.WithError(err).Warn("could not read the initial cart for cart updates")
+		if err := sender.cartUnavailable(); err != nil {
+			return
+		}
+	} else {
+		if err := sender.send(fe.newCartUpdate(ctx, log, seq, cart, currency)); err != nil {
+			return
+		}
+		last = seq
+	}
+
+	ticker := time.NewTicker(cartUpdateKeepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case update := <-updates:
+			if update.Seq <= last {
+				log.WithField("seq", update.Seq).Debug("skipping cart update whose read began before the one already sent")
+				continue
+			}
+			if err := sender.send(update); err != nil {
+				log.WithError(err).Debug("cart update client went away")
+				return
+			}
+			last = update.Seq
+			if sender.keepaliveWhenIdle() {
+				ticker.Reset(cartUpdateKeepalive)
+			}
+		case a := <-announcements:
+			if err := sender.announce(a); err != nil {
+				log.WithError(err).Debug("cart update client went away")
+				return
+			}
+			if sender.keepaliveWhenIdle() {
+				ticker.Reset(cartUpdateKeepalive)
+			}
+		case <-ticker.C:
+			if err := sender.keepalive(); err != nil {
+				log.WithError(err).Debug("cart update client went away")
+				return
+			}
+		case <-draining:
+			draining = nil // tell the client once
+			if err := sender.reconnect(); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// initialCart reads userID's cart for the start of a stream, retrying
+// briefly since a client that gets nothing shows an empty cart until the next
+// update. seq is reserved before the successful read.
+func (fe *frontendServer) initialCart(ctx context.Context, userID string) (seq uint64, cart []*pb.CartItem, err error) {
+	for attempt := 0; ; attempt++ {
+		seq = fe.cartUpdateClients.nextSeq()
+		if cart, err = fe.getCart(ctx, userID); err == nil || attempt >= cartUpdateInitialRetries {
+			return seq, cart, err
+		}
+		select {
+		case <-time.After(cartUpdateInitialRetryDelay):
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		}
+	}
+}
+
+// newCartUpdate builds the update for cart, with its total converted to
+// currency. If the total can't be worked out the update carries only the
+// count and items, since a client is better served by those than by nothing.
+func (fe *frontendServer) newCartUpdate(ctx context.Context, log logrus.FieldLogger, seq uint64, cart []*pb.CartItem, currency string) CartUpdate {
+	// Convert protobuf cart items to serializable format with product names
+	cartItems := make([]CartItem, len(cart))
+	totalUSD, priced := pb.Money{CurrencyCode: "USD"}, true
+	for i, item := range cart {
+		name, priceUSD, err := fe.getCartProduct(item.ProductId)
+		cartItems[i] = CartItem{
+			ProductID:   item.ProductId,
+			ProductName: name,
+			Quantity:    item.Quantity,
+		}
+		switch {
+		case status.Code(err) == codes.NotFound:
+			// Left out of the total, as on the cart page.
+		case err != nil:
+			priced = false
+		case priced && priceUSD != nil:
+			totalUSD = money.Must(money.Sum(totalUSD, money.MultiplySlow(*priceUSD, uint32(item.GetQuantity()))))
+		}
+	}
+	update := CartUpdate{
+		Seq:   seq,
+		Count: cartSize(cart),
+		Items: cartItems,
+	}
+	if !priced {
+		return update
+	}
+	total, err := fe.convertCurrency(ctx, &totalUSD, currency)
+	if err != nil {
+		log.WithError(err).WithField("currency", currency).Warn("could not convert cart total, sending the cart update without it")
+		return update
+	}
+	update.TotalPrice = total
+	return update
+}
+
+// notifyCartUpdate publishes cart, read under sequence number seq, to
+// userID's update clients.
+func (fe *frontendServer) notifyCartUpdate(userID string, seq uint64, cart []*pb.CartItem) {
+	// Pushes have no request to take a deadline or currency from.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	update := fe.newCartUpdate(ctx, log, seq, cart, fe.cartUpdateClients.currency(userID))
+	clients, coalesced := fe.cartUpdateClients.publish(userID, update)
+
+	l, ok := fe.cartUpdateLogs.sample(log, "notify:"+userID)
+	if !ok {
+		return
+	}
+	l = l.WithFields(logrus.Fields{
+		"user_id":          userID,
+		"cart_items_count": update.Count,
+		"clients":          clients,
+	})
+	switch {
+	case clients == 0:
+		l.Debug("no cart update client found for user")
+	case coalesced > 0:
+		l.WithField("coalesced", coalesced).Info("sent cart update, replacing a stale one for slow clients")
+	default:
+		l.Info("successfully sent cart update")
+	}
+}
+
+// announceRecipeAdded tells every connected client that a recipe was added
+// to a cart, when broadcastRecipeAdds is on. The cart itself stays private to
+// its user.
+func (fe *frontendServer) announceRecipeAdded(recipeID, title string) {
+	if !broadcastRecipeAdds {
+		return
+	}
+	delivered := fe.cartUpdateClients.broadcast(Announcement{
+		Message:  fmt.Sprintf("Someone just added %s to their cart", title),
+		RecipeID: recipeID,
+	})
+	log.WithFields(logrus.Fields{"recipe_id": recipeID, "clients": delivered}).Debug("announced recipe add")
+}
+
+// mutateCart runs op, which changes userID's cart, and then pushes the
+// resulting cart to the user's update clients exactly once. Every cart change
+// goes through here, or through notifyCartUpdateWhenChanged for changes the
+// backends apply asynchronously, so that clients see them all. Ops for one
+// user run one at a time, so they don't interleave with removeFromCart's
+// read, empty and re-add.
+//
+// The push happens in the background so the request doesn't wait for it, and
+// is skipped if the user has no clients: one that connects later reads the
+// cart for itself.
+func (fe *frontendServer) mutateCart(ctx context.Context, userID string, op func(context.Context) error) error {
+	unlock := fe.cartLocks.lock(userID)
+	err := op(ctx)
+	unlock()
+	if err != nil {
+		return err
+	}
+	if !fe.cartUpdateClients.hasClients(userID) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cartPushTimeout)
+	go func() {
+		defer cancel()
+		fe.pushCart(ctx, userID)
+	}()
+	return nil
+}
+
+// notifyCartUpdateWhenChanged waits for an asynchronous change to userID's
+// cart, which held baseline items before it was requested, and pushes the
+// cart to the user's update clients once it has changed. The cart is read
+// every recipeCartPollInterval; if it is still unchanged after
+// recipeCartPollTimeout it is pushed anyway, so clients hear exactly once
+// either way. A negative baseline means it is unknown, and the cart is pushed
+// at the deadline.
+//
+// Only the first change is pushed. If the recipe service adds the items in
+// several steps, clients may show a partial cart until the next event.
+//
+// Each read may take until the deadline, or one poll interval if that is
+// sooner, so a hung cart service can't keep this running much past it.
+func (fe *frontendServer) notifyCartUpdateWhenChanged(userID string, baseline int) {
+	deadline := time.Now().Add(recipeCartPollTimeout)
+	for {
+		seq := fe.cartUpdateClients.nextSeq()
+		ctx, cancel := context.WithTimeout(context.Background(), max(time.Until(deadline), recipeCartPollInterval))
+		cart, err := fe.getCart(ctx, userID)
+		cancel()
+		expired := !time.Now().Before(deadline)
+		switch {
+		case err == nil && (cartSize(cart) != baseline || expired):
+			fe.notifyCartUpdate(userID, seq, cart)
+			return
+		case err != nil && expired:
+			log.WithError(err).WithField("user_id", userID).Error("failed to get cart for notification")
+			return
+		}
+		time.Sleep(min(recipeCartPollInterval, time.Until(deadline)))
+	}
+}
+
+// drainCartUpdates is the first step of shutting down. It asks every cart
+// update client to reconnect, which takes it to another replica, and then
+// gives them up to grace to go before ending the streams still open, which
+// would otherwise hold up srv.Shutdown.
+func (fe *frontendServer) drainCartUpdates(log logrus.FieldLogger, grace time.Duration) {
+	fe.cartUpdateClients.beginDrain()
+	defer fe.cartUpdateClients.closeStreams()
+
+	deadline := time.After(grace)
+	poll := time.NewTicker(100 * time.Millisecond)
+	defer poll.Stop()
+	for n := fe.cartUpdateClients.clientCount(); n > 0; n = fe.cartUpdateClients.clientCount() {
+		select {
+		case <-poll.C:
+		case <-deadline:
+			log.WithField("clients", n).Info("closing cart update streams that didn't reconnect")
+			return
+		}
+	}
+}
+
+// pushCart fetches userID's cart and sends it to the user's update clients.
+func (fe *frontendServer) pushCart(ctx context.Context, userID string) {
+	seq := fe.cartUpdateClients.nextSeq()
+	cart, err := fe.getCart(ctx, userID)
+	if err != nil {
+		log.WithError(err).WithField("user_id", userID).Error("failed to get cart for notification")
+		return
+	}
+	fe.notifyCartUpdate(userID, seq, cart)
+}
+
+type sseCartSender struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s sseCartSender) send(update CartUpdate) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	// The id lets a reconnecting EventSource report, in Last-Event-ID, the
+	// last update it saw.
+	if _, err := fmt.Fprintf(s.w, "id: %d\ndata: %s\n\n", update.Seq, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// announce sends a as an "announcement" event. EventSource only passes
+// unnamed events to onmessage, so clients that don't listen for it are
+// unaffected.
+func (s sseCartSender) announce(a Announcement) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: announcement\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s sseCartSender) keepalive() error {
+	// Lines starting with a colon are comments that EventSource ignores.
+	// Events are written whole, so this never lands inside one.
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// keepaliveWhenIdle is true: heartbeats only keep proxies from closing a
+// quiet stream, and any event does that as well.
+func (s sseCartSender) keepaliveWhenIdle() bool { return true }
+
+// cartUnavailable sends a "cart-error" event. It isn't named "error" so it
+// doesn't trip EventSource's reconnect handling.
+func (s sseCartSender) cartUnavailable() error {
+	if _, err := fmt.Fprint(s.w, "event: cart-error\ndata: {\"message\":\"could not load cart\"}\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// reconnect sends a "reconnect" event, which cart-sse.js answers by opening a
+// new EventSource.
+func (s sseCartSender) reconnect() error {
+	if _, err := fmt.Fprint(s.w, "event: reconnect\ndata: {\"reason\":\"shutdown\"}\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// cartUpdatesHandler streams the session's cart updates as server-sent
+// events, each with its Seq as the event id.
+//
+// Delivery is at least once. Every connection, including an EventSource
+// reconnecting with Last-Event-ID, starts with a snapshot of the whole cart,
+// so a client that missed updates while offline catches up, and one that
+// didn't may see its current cart again. The snapshot isn't skipped even when
+// Last-Event-ID looks current: sequence numbers are per frontend process, so
+// after a restart or on another replica they don't compare.
+func (fe *frontendServer) cartUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := sessionID(r) // Use sessionID as userID for cart updates
+
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	if !fe.throttleReconnects(w, log, userID) {
+		return
+	}
+	if l, ok := fe.cartUpdateLogs.sample(log, "connect:"+userID); ok {
+		l = l.WithField("user_id", userID)
+		if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+			l = l.WithField("last_event_id", lastID)
+		}
+		l.Info("Creating new session for path: /cart/updates")
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream is meant to outlive the server's WriteTimeout; keepalives
+	// detect clients that have gone away instead.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.WithError(err).Debug("could not clear write deadline for cart updates")
+	}
+
+	// Set SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	setCartUpdatesCORS(w.Header(), r.Header.Get("Origin"))
+
+	fe.streamCartUpdates(r.Context(), log, userID, currentCurrency(r), sseCartSender{w: w, flusher: flusher})
+}
+
+// setCartUpdatesCORS lets other origins read the cart update stream. With no
+// sseAllowedOrigins any origin may, but only without cookies, which the stream
+// needs for the session. Otherwise only the listed origins may, with cookies,
+// and other origins get no CORS headers at all.
+func setCartUpdatesCORS(h http.Header, origin string) {
+	if len(sseAllowedOrigins) == 0 {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Add("Vary", "Origin")
+	for _, allowed := range sseAllowedOrigins {
+		if origin != "" && strings.EqualFold(origin, allowed) {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+			return
+		}
+	}
+}
+
+var cartWebSocketUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type wsCartSender struct {
+	conn *websocket.Conn
+}
+
+func (s wsCartSender) send(update CartUpdate) error {
+	s.conn.SetWriteDeadline(time.Now().Add(cartUpdateWriteWait))
+	return s.conn.WriteJSON(update)
+}
+
+// announce sends a wrapped as {"announcement": a}, which clients can tell
+// apart from a cart update.
+func (s wsCartSender) announce(a Announcement) error {
+	s.conn.SetWriteDeadline(time.Now().Add(cartUpdateWriteWait))
+	return s.conn.WriteJSON(map[string]Announcement{"announcement": a})
+}
+
+func (s wsCartSender) keepalive() error {
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(cartUpdateWriteWait))
+}
+
+// keepaliveWhenIdle is false: the client's pongs are what extend the read
+// deadline, so pings have to keep coming however busy the stream is.
+func (s wsCartSender) keepaliveWhenIdle() bool { return false }
+
+// reconnect sends {"reconnect": true}, which clients can tell apart from a
+// cart update.
+func (s wsCartSender) reconnect() error {
+	s.conn.SetWriteDeadline(time.Now().Add(cartUpdateWriteWait))
+	return s.conn.WriteJSON(map[string]bool{"reconnect": true})
+}
+
+// cartUnavailable sends {"error": "..."}, which clients can tell apart from a
+// cart update.
+func (s wsCartSender) cartUnavailable() error {
+	s.conn.SetWriteDeadline(time.Now().Add(cartUpdateWriteWait))
+	return s.conn.WriteJSON(map[string]string{"error": "could not load cart"})
+}
+
+// cartWebSocketHandler streams the same updates as cartUpdatesHandler over a
+// WebSocket, for clients and proxies that handle it better than SSE.
+func (fe *frontendServer) cartWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	userID := sessionID(r)
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log = log.WithField("user_id", userID)
+	if !fe.throttleReconnects(w, log, userID) {
+		return
+	}
+
+	conn, err := cartWebSocketUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error.
+		log.WithError(err).Warn("failed to upgrade cart updates to WebSocket")
+		return
+	}
+	defer conn.Close()
+	if l, ok := fe.cartUpdateLogs.sample(log, "connect:"+userID); ok {
+		l.Info("WebSocket client connected for cart updates")
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// The client only sends control frames. Reading processes pongs and the
+	// close handshake, and fails once the client stops answering pings.
+	conn.SetReadDeadline(time.Now().Add(cartUpdatePongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(cartUpdatePongWait))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	fe.streamCartUpdates(ctx, log, userID, currentCurrency(r), wsCartSender{conn: conn})
+
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(cartUpdateWriteWait))
+	if l, ok := fe.cartUpdateLogs.sample(log, "disconnect:"+userID); ok {
+		l.Info("WebSocket client disconnected from cart updates")
+	}
+}
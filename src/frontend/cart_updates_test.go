@@ -0,0 +1,1103 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestCartWebSocketReceivesUpdates(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta"})
+	backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 1})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := newTestRequest(r.Method, r.URL.String(), nil)
+		req.Header = r.Header
+		fe.cartWebSocketHandler(w, req)
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/cart/ws", nil)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var initial CartUpdate
+	if err := conn.ReadJSON(&initial); err != nil {
+		t.Fatalf("reading initial cart: %v", err)
+	}
+	if initial.Count != 1 || initial.Items[0].ProductName != "Pasta" {
+		t.Errorf("got initial cart %+v, want 1 Pasta", initial)
+	}
+
+	fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 3}})
+	var update CartUpdate
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("reading cart update: %v", err)
+	}
+	if update.Count != 3 {
+		t.Errorf("got cart update with %d items, want 3", update.Count)
+	}
+
+	// Closing the socket unregisters the client.
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if clients, _ := fe.cartUpdateClients.publish(testSessionID, CartUpdate{}); clients == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client still subscribed after closing the WebSocket")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCartWebSocketStaysOpenWhileBusy(t *testing.T) {
+	defer func(keepalive, pongWait time.Duration) {
+		cartUpdateKeepalive, cartUpdatePongWait = keepalive, pongWait
+	}(cartUpdateKeepalive, cartUpdatePongWait)
+	cartUpdateKeepalive, cartUpdatePongWait = 50*time.Millisecond, 200*time.Millisecond
+
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta"})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := newTestRequest(r.Method, r.URL.String(), nil)
+		req.Header = r.Header
+		fe.cartWebSocketHandler(w, req)
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/cart/ws", nil)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var update CartUpdate
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("reading initial cart: %v", err)
+	}
+
+	// Updates arrive faster than the keepalive for twice the pong wait.
+	// Reading them answers the pings, which must keep coming regardless.
+	for start := time.Now(); time.Since(start) < 2*cartUpdatePongWait; {
+		fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 2}})
+		if err := conn.ReadJSON(&update); err != nil {
+			t.Fatalf("connection dropped while busy: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := fe.cartUpdateClients.clientCount(); n != 1 {
+		t.Errorf("got %d cart update clients, want the busy one still connected", n)
+	}
+}
+
+func TestCartUpdateHubFansOut(t *testing.T) {
+	var h cartUpdateHub
+	sse, unsubscribeSSE := h.subscribe("u1")
+	ws, unsubscribeWS := h.subscribe("u1")
+	defer unsubscribeWS()
+
+	if clients, coalesced := h.publish("u1", CartUpdate{Count: 2}); clients != 2 || coalesced != 0 {
+		t.Fatalf("publish reached %d clients with %d coalesced, want 2 and 0", clients, coalesced)
+	}
+	for _, ch := range []<-chan CartUpdate{sse, ws} {
+		if got := <-ch; got.Count != 2 {
+			t.Errorf("got update %+v, want count 2", got)
+		}
+	}
+
+	unsubscribeSSE()
+	if clients, _ := h.publish("u1", CartUpdate{}); clients != 1 {
+		t.Errorf("got %d clients after unsubscribing one, want 1", clients)
+	}
+}
+
+func TestCartUpdatesReachEveryTabOfASession(t *testing.T) {
+	fe, _ := newTestFrontend(t)
+	recv := func(t *testing.T, tab chanCartSender, want int) {
+		t.Helper()
+		for {
+			select {
+			case update := <-tab:
+				if update.Count == want {
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("tab never got the cart of %d", want)
+			}
+		}
+	}
+
+	ctx1, closeFirst := context.WithCancel(context.Background())
+	defer closeFirst()
+	ctx2, closeSecond := context.WithCancel(context.Background())
+	defer closeSecond()
+	first, second := make(chanCartSender, 10), make(chanCartSender, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fe.streamCartUpdates(ctx1, log, testSessionID, defaultCurrency, first)
+	}()
+	go fe.streamCartUpdates(ctx2, log, testSessionID, defaultCurrency, second)
+	recv(t, first, 0) // initial carts
+	recv(t, second, 0)
+
+	fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 2}})
+	recv(t, first, 2)
+	recv(t, second, 2)
+
+	// Closing one tab leaves the other subscribed.
+	closeFirst()
+	<-done
+	fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 3}})
+	recv(t, second, 3)
+}
+
+func TestRecipeAddBroadcastReachesEveryClient(t *testing.T) {
+	defer func(old time.Duration) { recipeCartPollTimeout = old }(recipeCartPollTimeout)
+	recipeCartPollTimeout = 0
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			defer func(old bool) { broadcastRecipeAdds = old }(broadcastRecipeAdds)
+			broadcastRecipeAdds = enabled
+
+			fe, backends := newTestFrontend(t)
+			backends.recipe.recipes = []*pb.Recipe{{RecipeId: "r1", Title: "Pasta", Ingredients: []*pb.Ingredient{{Name: "Tomatoes"}}}}
+			own, unsubscribeOwn := fe.cartUpdateClients.subscribe(testSessionID)
+			defer unsubscribeOwn()
+			other, unsubscribeOther := fe.cartUpdateClients.subscribe("other-user")
+			defer unsubscribeOther()
+			var announcements []<-chan Announcement
+			for i := 0; i < 2; i++ {
+				ch, unsubscribe := fe.cartUpdateClients.subscribeAnnouncements()
+				defer unsubscribe()
+				announcements = append(announcements, ch)
+			}
+
+			form := url.Values{"ingredient_list": {"Tomatoes"}}
+			req := newTestRequest(http.MethodPost, "/recipe/r1/add-to-cart", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = mux.SetURLVars(req, map[string]string{"id": "r1"})
+			rr := httptest.NewRecorder()
+			fe.addRecipeToCartHandler(rr, req)
+			if rr.Code != http.StatusFound {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusFound, rr.Body)
+			}
+
+			// The cart update may be pushed after the request returns.
+			select {
+			case <-own:
+			case <-time.After(5 * time.Second):
+				t.Error("cart owner got no cart update")
+			}
+			select {
+			case update := <-other:
+				t.Errorf("another user got cart update %+v", update)
+			default:
+			}
+			for i, ch := range announcements {
+				select {
+				case a := <-ch:
+					if !enabled {
+						t.Errorf("client %d got announcement %+v with broadcasting off", i, a)
+					} else if a.RecipeID != "r1" || !strings.Contains(a.Message, "Pasta") {
+						t.Errorf("client %d got announcement %+v, want one for r1 naming Pasta", i, a)
+					}
+				default:
+					if enabled {
+						t.Errorf("client %d got no announcement", i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCartUpdateHubCoalescesForSlowClients(t *testing.T) {
+	defer func(old int) { cartUpdateBufferSize = old }(cartUpdateBufferSize)
+	cartUpdateBufferSize = 2
+
+	var h cartUpdateHub
+	updates, unsubscribe := h.subscribe("u1")
+	defer unsubscribe()
+
+	// A slow consumer that reads a few updates while they are published.
+	const last = 50
+	received := make(chan int)
+	go func() {
+		var latest int
+		for update := range updates {
+			latest = update.Count
+			if latest == last {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		received <- latest
+	}()
+
+	coalesced := 0
+	for i := 1; i <= last; i++ {
+		_, n := h.publish("u1", CartUpdate{Count: i})
+		coalesced += n
+	}
+	if coalesced == 0 {
+		t.Error("no updates were coalesced, want the slow client to fall behind")
+	}
+	select {
+	case got := <-received:
+		if got != last {
+			t.Errorf("slow client ended with count %d, want %d", got, last)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow client never received the latest update")
+	}
+}
+
+func TestCartUpdateHubUsesConfiguredBufferSize(t *testing.T) {
+	defer func(old int) { cartUpdateBufferSize = old }(cartUpdateBufferSize)
+	cartUpdateBufferSize = 3
+
+	var h cartUpdateHub
+	updates, unsubscribe := h.subscribe("u1")
+	defer unsubscribe()
+	announcements, unsubscribeAnnouncements := h.subscribeAnnouncements()
+	defer unsubscribeAnnouncements()
+	if cap(updates) != 3 || cap(announcements) != 3 {
+		t.Fatalf("got buffers of %d updates and %d announcements, want 3", cap(updates), cap(announcements))
+	}
+
+	// A client that reads nothing takes a full buffer without losing any...
+	coalesced := 0
+	for i := 1; i <= 3; i++ {
+		_, n := h.publish("u1", CartUpdate{Count: i})
+		coalesced += n
+	}
+	if coalesced != 0 {
+		t.Errorf("coalesced %d updates filling the buffer, want 0", coalesced)
+	}
+	// ...and then loses its oldest for each new one.
+	for i := 4; i <= 5; i++ {
+		_, n := h.publish("u1", CartUpdate{Count: i})
+		coalesced += n
+	}
+	if coalesced != 2 {
+		t.Errorf("coalesced %d updates past the buffer, want 2", coalesced)
+	}
+	for want := 3; want <= 5; want++ {
+		if got := (<-updates).Count; got != want {
+			t.Errorf("got count %d, want %d", got, want)
+		}
+	}
+
+	for i := range 4 {
+		h.broadcast(Announcement{Message: strconv.Itoa(i)})
+	}
+	if len(announcements) != 3 {
+		t.Errorf("buffered %d announcements, want 3", len(announcements))
+	}
+	if got := (<-announcements).Message; got != "0" {
+		t.Errorf("first announcement is %q, want the oldest kept", got)
+	}
+}
+
+func TestNotifyCartUpdateCachesProductNames(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta"}, &pb.Product{Id: "P2", Name: "Pesto"})
+	updates, unsubscribe := fe.cartUpdateClients.subscribe(testSessionID)
+	defer unsubscribe()
+
+	cart := []*pb.CartItem{{ProductId: "P1", Quantity: 1}, {ProductId: "P2", Quantity: 2}}
+	for i := 0; i < 3; i++ {
+		fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), cart)
+		if got := <-updates; got.Items[1].ProductName != "Pesto" {
+			t.Fatalf("got items %+v, want P2 named Pesto", got.Items)
+		}
+	}
+
+	for _, id := range []string{"P1", "P2"} {
+		if got := backends.catalog.getCallCount(id); got != 1 {
+			t.Errorf("looked up %s %d times, want 1", id, got)
+		}
+	}
+}
+
+// chanCartSender hands sent updates to a channel.
+type chanCartSender chan CartUpdate
+
+func (s chanCartSender) send(update CartUpdate) error {
+	s <- update
+	return nil
+}
+
+func (s chanCartSender) announce(Announcement) error { return nil }
+
+func (s chanCartSender) keepalive() error { return nil }
+
+func (s chanCartSender) keepaliveWhenIdle() bool { return true }
+
+func (s chanCartSender) cartUnavailable() error { return nil }
+
+func (s chanCartSender) reconnect() error { return nil }
+
+func TestStreamCartUpdatesOrdersUpdatesAroundInitialCart(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta"})
+	backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 2})
+
+	// An update whose cart was read before the stream's initial read, and one
+	// read after it, are both published while the initial read is in flight.
+	staleSeq := fe.cartUpdateClients.nextSeq()
+	var once sync.Once
+	backends.cart.onGet = func(string) {
+		once.Do(func() {
+			fe.notifyCartUpdate(testSessionID, staleSeq, []*pb.CartItem{{ProductId: "P1", Quantity: 1}})
+			fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 3}})
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sent := make(chanCartSender, 10)
+	go fe.streamCartUpdates(ctx, log, testSessionID, defaultCurrency, sent)
+
+	var got []int
+	for len(got) < 2 {
+		select {
+		case update := <-sent:
+			got = append(got, update.Count)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got carts %v, want [2 3]", got)
+		}
+	}
+	select {
+	case update := <-sent:
+		got = append(got, update.Count)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("got carts %v, want the initial cart of 2 and then the update to 3", got)
+	}
+}
+
+func TestStreamCartUpdatesRetriesInitialCart(t *testing.T) {
+	defer func(old time.Duration) { cartUpdateInitialRetryDelay = old }(cartUpdateInitialRetryDelay)
+	cartUpdateInitialRetryDelay = time.Millisecond
+
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta"})
+	backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 2})
+	backends.cart.getErr = status.Error(codes.Unavailable, "cart is down")
+	backends.cart.onGet = func(string) {
+		backends.cart.mu.Lock()
+		defer backends.cart.mu.Unlock()
+		if backends.cart.getCalls == 1 {
+			backends.cart.getErr = nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sent := make(chanCartSender, 10)
+	go fe.streamCartUpdates(ctx, log, testSessionID, defaultCurrency, sent)
+
+	select {
+	case update := <-sent:
+		if update.Count != 2 {
+			t.Errorf("got initial cart of %d, want 2", update.Count)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("initial cart was never sent")
+	}
+}
+
+func TestCartUpdatesSSEReportsUnavailableCart(t *testing.T) {
+	defer func(old time.Duration) { cartUpdateInitialRetryDelay = old }(cartUpdateInitialRetryDelay)
+	cartUpdateInitialRetryDelay = time.Millisecond
+
+	fe, backends := newTestFrontend(t)
+	backends.cart.getErr = status.Error(codes.Unavailable, "cart is down")
+	srv := httptest.NewServer(&logHandler{log: log, next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fe.cartUpdatesHandler(w, r.WithContext(context.WithValue(r.Context(), ctxKeySessionID{}, testSessionID)))
+	})})
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/cart/updates")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer resp.Body.Close()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	if line != "event: cart-error\n" {
+		t.Errorf("got first line %q, want a cart-error event", line)
+	}
+	backends.cart.mu.Lock()
+	defer backends.cart.mu.Unlock()
+	if want := cartUpdateInitialRetries + 1; backends.cart.getCalls != want {
+		t.Errorf("read the cart %d times, want %d", backends.cart.getCalls, want)
+	}
+}
+
+func TestCartUpdatesCarryTotalPrice(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(
+		&pb.Product{Id: "P1", Name: "Pasta", PriceUsd: usd(2, 500000000)},
+		&pb.Product{Id: "P2", Name: "Basil", PriceUsd: usd(1, 0)},
+	)
+	backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 2}, &pb.CartItem{ProductId: "P2", Quantity: 1})
+	backends.currency.rates = map[string]float64{"EUR": 0.5}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sent := make(chanCartSender, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fe.streamCartUpdates(ctx, log, testSessionID, "EUR", sent)
+	}()
+	next := func() CartUpdate {
+		t.Helper()
+		select {
+		case update := <-sent:
+			return update
+		case <-time.After(5 * time.Second):
+			t.Fatal("no cart update sent")
+			return CartUpdate{}
+		}
+	}
+	wantTotal := func(update CartUpdate, units int64) {
+		t.Helper()
+		if got := update.TotalPrice; got.GetCurrencyCode() != "EUR" || got.GetUnits() != units || got.GetNanos() != 0 {
+			t.Errorf("got total %v, want %d EUR", got, units)
+		}
+	}
+
+	wantTotal(next(), 3) // initial cart of 6 USD
+	// Pushes have no request, so they use the currency the stream asked for.
+	fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 4}})
+	wantTotal(next(), 5)
+
+	backends.currency.mu.Lock()
+	backends.currency.convertErr = status.Error(codes.Unavailable, "currency is down")
+	backends.currency.mu.Unlock()
+	fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 5}})
+	if update := next(); update.Count != 5 || update.TotalPrice != nil {
+		t.Errorf("got count %d and total %v when conversion fails, want 5 and no total", update.Count, update.TotalPrice)
+	}
+
+	cancel()
+	<-done
+	if got := fe.cartUpdateClients.currency(testSessionID); got != defaultCurrency {
+		t.Errorf("got currency %q once the user's last client left, want %q", got, defaultCurrency)
+	}
+}
+
+func TestProductNameCacheExpires(t *testing.T) {
+	var c productNameCache
+	now := time.Now()
+	c.set(&pb.Product{Id: "P1", Name: "Pasta"}, now)
+
+	if name, ok := c.get("P1", now.Add(productNameCacheTTL-time.Second)); !ok || name != "Pasta" {
+		t.Errorf("got (%q, %v) within the TTL, want (Pasta, true)", name, ok)
+	}
+	if _, ok := c.get("P1", now.Add(productNameCacheTTL)); ok {
+		t.Error("entry still cached after the TTL")
+	}
+}
+
+func TestCartUpdatesThrottlesRapidReconnects(t *testing.T) {
+	defer func(max int) { cartUpdateMaxReconnects = max }(cartUpdateMaxReconnects)
+	cartUpdateMaxReconnects = 3
+
+	fe, _ := newTestFrontend(t)
+	connect := func(userID string) *httptest.ResponseRecorder {
+		// An already-cancelled client disconnects right after the initial send.
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKeySessionID{}, userID))
+		cancel()
+		req := newTestRequest(http.MethodGet, "/cart/updates", nil)
+		req = req.WithContext(context.WithValue(ctx, ctxKeyLog{}, req.Context().Value(ctxKeyLog{})))
+		rr := httptest.NewRecorder()
+		fe.cartUpdatesHandler(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 3; i++ {
+		if rr := connect("looping-user"); rr.Code != http.StatusOK {
+			t.Fatalf("connection %d: got status %d, want %d", i+1, rr.Code, http.StatusOK)
+		}
+	}
+
+	rr := connect("looping-user")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d for the 4th connection, want %d", rr.Code, http.StatusTooManyRequests)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("throttled response has no Retry-After")
+	}
+	if rr := connect("other-user"); rr.Code != http.StatusOK {
+		t.Errorf("other user got status %d, want %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestReconnectLimiterWindow(t *testing.T) {
+	defer func(max int, window time.Duration) {
+		cartUpdateMaxReconnects, cartUpdateReconnectWindow = max, window
+	}(cartUpdateMaxReconnects, cartUpdateReconnectWindow)
+	cartUpdateMaxReconnects, cartUpdateReconnectWindow = 2, time.Minute
+
+	var l reconnectLimiter
+	start := time.Now()
+	l.allow("u", start)
+	l.allow("u", start.Add(10*time.Second))
+
+	ok, retryAfter := l.allow("u", start.Add(20*time.Second))
+	if ok || retryAfter != 40*time.Second {
+		t.Errorf("got (%v, %v) for a 3rd connection, want (false, 40s)", ok, retryAfter)
+	}
+	if ok, _ := l.allow("u", start.Add(time.Minute+time.Second)); !ok {
+		t.Error("connection refused after the oldest one left the window")
+	}
+	if ok, _ := l.allow("u", start.Add(time.Minute+2*time.Second)); ok {
+		t.Error("connection allowed with 2 others in the window")
+	}
+}
+
+func TestCartUpdatesSSEOutlivesWriteTimeout(t *testing.T) {
+	defer func(old time.Duration) { httpWriteTimeout = old }(httpWriteTimeout)
+	httpWriteTimeout = 100 * time.Millisecond
+
+	fe, _ := newTestFrontend(t)
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fe.cartUpdatesHandler(w, r.WithContext(context.WithValue(r.Context(), ctxKeySessionID{}, testSessionID)))
+	})
+	handler = &logHandler{log: log, next: handler}
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = newHTTPServer("", handler)
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/cart/updates")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		t.Helper()
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading stream: %v", err)
+			}
+			if strings.HasPrefix(line, "data: ") {
+				return line
+			}
+		}
+	}
+	readEvent() // initial cart
+
+	time.Sleep(3 * httpWriteTimeout)
+	fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 4}})
+	if got := readEvent(); !strings.Contains(got, `"cart_items_count":4`) {
+		t.Errorf("got event %q, want the 4-item update", got)
+	}
+}
+
+func TestCartUpdatesSSEHeartbeat(t *testing.T) {
+	defer func(old time.Duration) { cartUpdateKeepalive = old }(cartUpdateKeepalive)
+	cartUpdateKeepalive = 50 * time.Millisecond
+
+	fe, _ := newTestFrontend(t)
+	srv := httptest.NewServer(&logHandler{log: log, next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fe.cartUpdatesHandler(w, r.WithContext(context.WithValue(r.Context(), ctxKeySessionID{}, testSessionID)))
+	})})
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/cart/updates")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	// readLine returns the next line other than a blank or an event id,
+	// checking that every event still carries whole JSON.
+	readLine := func() string {
+		t.Helper()
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading stream: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if data, ok := strings.CutPrefix(line, "data: "); ok && !json.Valid([]byte(data)) {
+				t.Fatalf("event data %q is not valid JSON", data)
+			}
+			if line != "" && !strings.HasPrefix(line, "id: ") {
+				return line
+			}
+		}
+	}
+	if got := readLine(); !strings.HasPrefix(got, "data: ") {
+		t.Fatalf("got %q, want the initial cart", got)
+	}
+	if got := readLine(); got != ": ping" {
+		t.Fatalf("got %q on an idle stream, want a heartbeat", got)
+	}
+
+	fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 2}})
+	for {
+		got := readLine()
+		if strings.HasPrefix(got, "data: ") {
+			if !strings.Contains(got, `"cart_items_count":2`) {
+				t.Errorf("got event %q, want the 2-item update", got)
+			}
+			break
+		}
+		if got != ": ping" {
+			t.Fatalf("got unexpected line %q", got)
+		}
+	}
+}
+
+func TestDrainCartUpdatesAsksClientsToReconnect(t *testing.T) {
+	fe, _ := newTestFrontend(t)
+	srv := httptest.NewServer(&logHandler{log: log, next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fe.cartUpdatesHandler(w, r.WithContext(context.WithValue(r.Context(), ctxKeySessionID{}, testSessionID)))
+	})})
+	defer srv.Close()
+
+	// Two tabs: the first reconnects when asked, the second ignores it.
+	var streams [2]*bufio.Reader
+	var bodies [2]io.ReadCloser
+	for i := range streams {
+		resp, err := http.Get(srv.URL + "/cart/updates")
+		if err != nil {
+			t.Fatalf("connecting: %v", err)
+		}
+		defer resp.Body.Close()
+		bodies[i], streams[i] = resp.Body, bufio.NewReader(resp.Body)
+		// The initial cart is sent once the client is subscribed.
+		if line, err := streams[i].ReadString('\n'); err != nil || !strings.HasPrefix(line, "id: ") {
+			t.Fatalf("got %q, %v, want the initial cart", line, err)
+		}
+	}
+
+	const grace = 300 * time.Millisecond
+	drained := make(chan time.Duration)
+	go func() {
+		start := time.Now()
+		fe.drainCartUpdates(log, grace)
+		drained <- time.Since(start)
+	}()
+
+	for i, events := range streams {
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("client %d: reading stream: %v", i, err)
+			}
+			if line == "event: reconnect\n" {
+				break
+			}
+		}
+	}
+	bodies[0].Close()
+
+	if took := <-drained; took < grace {
+		t.Errorf("drain took %v, want it to wait %v for the client that stayed", took, grace)
+	}
+	if _, err := io.ReadAll(streams[1]); err != nil {
+		t.Errorf("reading the rest of the stream: %v", err)
+	}
+	if n := fe.cartUpdateClients.clientCount(); n != 0 {
+		t.Errorf("%d clients still subscribed after draining", n)
+	}
+}
+
+func TestCartUpdatesSSEEventIDs(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta"})
+	backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 1})
+	srv := httptest.NewServer(&logHandler{log: log, next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fe.cartUpdatesHandler(w, r.WithContext(context.WithValue(r.Context(), ctxKeySessionID{}, testSessionID)))
+	})})
+	defer srv.Close()
+
+	connect := func(lastEventID string) (*bufio.Reader, func()) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/cart/updates", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("connecting: %v", err)
+		}
+		return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+	}
+	// readEvent returns the next event's id and update, checking the id is
+	// the update's Seq.
+	readEvent := func(events *bufio.Reader) (uint64, CartUpdate) {
+		t.Helper()
+		var id string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading stream: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if v, ok := strings.CutPrefix(line, "id: "); ok {
+				id = v
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var update CartUpdate
+				if err := json.Unmarshal([]byte(data), &update); err != nil {
+					t.Fatalf("decoding %q: %v", data, err)
+				}
+				if want := fmt.Sprint(update.Seq); id != want {
+					t.Fatalf("got event id %q, want the update's seq %s", id, want)
+				}
+				return update.Seq, update
+			}
+		}
+	}
+
+	events, disconnect := connect("")
+	readEvent(events) // initial cart
+	fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: 2}})
+	lastID, _ := readEvent(events)
+	disconnect()
+
+	// The cart changes while the client is offline; reconnecting gets it
+	// straight away.
+	backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 3})
+	events, disconnect = connect(fmt.Sprint(lastID))
+	defer disconnect()
+	id, update := readEvent(events)
+	if update.Count != 3 {
+		t.Errorf("got cart of %d on reconnect, want the current 3", update.Count)
+	}
+	if id <= lastID {
+		t.Errorf("got event id %d after %d, want a later one", id, lastID)
+	}
+}
+
+func TestSetCartUpdatesCORS(t *testing.T) {
+	defer func(old []string) { sseAllowedOrigins = old }(sseAllowedOrigins)
+
+	tests := []struct {
+		name            string
+		allowed         []string
+		origin          string
+		wantOrigin      string
+		wantCredentials bool
+	}{
+		{"no allow-list", nil, "https://elsewhere.example.com", "*", false},
+		{"matched", []string{"https://a.example.com", "https://b.example.com"}, "https://b.example.com", "https://b.example.com", true},
+		{"matched ignoring case", []string{"https://b.example.com"}, "https://B.example.com", "https://B.example.com", true},
+		{"unmatched", []string{"https://b.example.com"}, "https://evil.example.com", "", false},
+		{"same origin", []string{"https://b.example.com"}, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sseAllowedOrigins = tt.allowed
+			req := newTestRequest(http.MethodGet, "/cart/updates", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rr := httptest.NewRecorder()
+			setCartUpdatesCORS(rr.Header(), req.Header.Get("Origin"))
+
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("got Access-Control-Allow-Origin %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Errorf("got credentials allowed %v, want %v", got, tt.wantCredentials)
+			}
+			if wantVary := len(tt.allowed) > 0; (rr.Header().Get("Vary") == "Origin") != wantVary {
+				t.Errorf("got Vary %q, want Origin: %v", rr.Header().Get("Vary"), wantVary)
+			}
+		})
+	}
+}
+
+func TestEveryCartMutationNotifiesOnce(t *testing.T) {
+	defer func(old time.Duration) { recipeCartPollTimeout = old }(recipeCartPollTimeout)
+	recipeCartPollTimeout = 0
+
+	form := func(target string, values url.Values, id string) *http.Request {
+		req := newTestRequest(http.MethodPost, target, strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if id != "" {
+			req = mux.SetURLVars(req, map[string]string{"id": id})
+		}
+		return req
+	}
+	tests := []struct {
+		name    string
+		handler func(*frontendServer) http.HandlerFunc
+		req     *http.Request
+	}{
+		{"add to cart", func(fe *frontendServer) http.HandlerFunc { return fe.addToCartHandler },
+			form("/cart", url.Values{"product_id": {"P1"}, "quantity": {"2"}}, "")},
+		{"empty cart", func(fe *frontendServer) http.HandlerFunc { return fe.emptyCartHandler },
+			form("/cart/empty", nil, "")},
+		{"remove item", func(fe *frontendServer) http.HandlerFunc { return fe.removeFromCartHandler },
+			form("/cart/remove", url.Values{"product_id": {"P1"}}, "")},
+		{"checkout", func(fe *frontendServer) http.HandlerFunc { return fe.placeOrderHandler },
+			form("/cart/checkout", checkoutForm(), "")},
+		{"add recipe", func(fe *frontendServer) http.HandlerFunc { return fe.addRecipeToCartHandler },
+			form("/recipe/r1/add-to-cart", url.Values{"ingredient_list": {"Pasta"}}, "r1")},
+		{"complete cart", func(fe *frontendServer) http.HandlerFunc { return fe.completeCartHandler },
+			form("/recipe/r1/complete-cart", nil, "r1")},
+		{"add suggested recipe", func(fe *frontendServer) http.HandlerFunc { return fe.addSuggestedRecipeToCartHandler },
+			form("/suggested-recipe/s1/add-to-cart", url.Values{"ingredient_list": {"Pasta"}}, "s1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta", PriceUsd: usd(2, 0)})
+			backends.recipe.recipes = []*pb.Recipe{{RecipeId: "r1", Ingredients: []*pb.Ingredient{{Name: "Pasta"}}}}
+			fe.suggestedRecipesCache.Store(testSessionID, []CachedRecipe{{RecipeId: "s1"}})
+			updates, unsubscribe := fe.cartUpdateClients.subscribe(testSessionID)
+			defer unsubscribe()
+
+			rr := httptest.NewRecorder()
+			tt.handler(fe).ServeHTTP(rr, tt.req)
+			if rr.Code >= http.StatusBadRequest {
+				t.Fatalf("got status %d: %s", rr.Code, rr.Body)
+			}
+
+			select {
+			case <-updates:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no cart update was sent")
+			}
+			select {
+			case update := <-updates:
+				t.Errorf("got a second cart update %+v, want exactly one", update)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestMutateCartPushesInBackground(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	var reads atomic.Int32
+	release := make(chan struct{})
+	backends.cart.onGet = func(string) {
+		reads.Add(1)
+		<-release
+	}
+	noop := func(context.Context) error { return nil }
+
+	// Without clients there is no one to push to.
+	if err := fe.mutateCart(context.Background(), testSessionID, noop); err != nil {
+		t.Fatalf("mutating the cart: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := reads.Load(); n != 0 {
+		t.Errorf("read the cart %d times with no clients, want 0", n)
+	}
+
+	// With one, the mutation returns before the slow cart read finishes.
+	updates, unsubscribe := fe.cartUpdateClients.subscribe(testSessionID)
+	defer unsubscribe()
+	done := make(chan error)
+	go func() { done <- fe.mutateCart(context.Background(), testSessionID, noop) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("mutating the cart: %v", err)
+		}
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatal("mutateCart waited for the push")
+	}
+	close(release)
+	select {
+	case <-updates:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no cart update was sent")
+	}
+}
+
+func TestNotifyCartUpdateWhenChanged(t *testing.T) {
+	defer func(interval, timeout time.Duration) {
+		recipeCartPollInterval, recipeCartPollTimeout = interval, timeout
+	}(recipeCartPollInterval, recipeCartPollTimeout)
+	recipeCartPollInterval = 10 * time.Millisecond
+
+	t.Run("pushed once the cart changes", func(t *testing.T) {
+		recipeCartPollTimeout = 5 * time.Second
+		fe, backends := newTestFrontend(t)
+		backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta"})
+		backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 1})
+		// The recipe service gets round to the cart on the third read.
+		var reads atomic.Int32
+		backends.cart.onGet = func(string) {
+			if reads.Add(1) == 3 {
+				backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 3})
+			}
+		}
+		updates, unsubscribe := fe.cartUpdateClients.subscribe(testSessionID)
+		defer unsubscribe()
+
+		start := time.Now()
+		fe.notifyCartUpdateWhenChanged(testSessionID, 1)
+		if elapsed := time.Since(start); elapsed >= time.Second {
+			t.Errorf("took %v to notify, want well before the %v deadline", elapsed, recipeCartPollTimeout)
+		}
+		if update := <-updates; update.Count != 3 {
+			t.Errorf("got cart of %d, want the changed cart of 3", update.Count)
+		}
+		if n := reads.Load(); n != 3 {
+			t.Errorf("read the cart %d times, want 3", n)
+		}
+	})
+
+	t.Run("pushed at the deadline if unchanged", func(t *testing.T) {
+		recipeCartPollTimeout = 50 * time.Millisecond
+		fe, backends := newTestFrontend(t)
+		backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta"})
+		backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 1})
+		updates, unsubscribe := fe.cartUpdateClients.subscribe(testSessionID)
+		defer unsubscribe()
+
+		start := time.Now()
+		fe.notifyCartUpdateWhenChanged(testSessionID, 1)
+		if elapsed := time.Since(start); elapsed < recipeCartPollTimeout {
+			t.Errorf("notified after %v, want to wait for the %v deadline", elapsed, recipeCartPollTimeout)
+		}
+		if update := <-updates; update.Count != 1 {
+			t.Errorf("got cart of %d, want the unchanged cart of 1", update.Count)
+		}
+		select {
+		case update := <-updates:
+			t.Errorf("got a second cart update %+v, want exactly one", update)
+		default:
+		}
+	})
+
+	t.Run("gives up on a hung cart service", func(t *testing.T) {
+		recipeCartPollTimeout = 50 * time.Millisecond
+		fe, backends := newTestFrontend(t)
+		hung := make(chan struct{})
+		defer close(hung)
+		backends.cart.onGet = func(string) { <-hung }
+
+		done := make(chan struct{})
+		go func() {
+			fe.notifyCartUpdateWhenChanged(testSessionID, 1)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("still reading the cart long after the %v deadline", recipeCartPollTimeout)
+		}
+	})
+}
+
+func TestCartUpdateLogsAreRateLimited(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta"})
+	updates, unsubscribe := fe.cartUpdateClients.subscribe(testSessionID)
+	defer unsubscribe()
+
+	hook := test.NewLocal(log)
+	defer log.ReplaceHooks(make(logrus.LevelHooks))
+	count := func(level logrus.Level, msg string) int {
+		n := 0
+		for _, e := range hook.AllEntries() {
+			if e.Level == level && strings.Contains(e.Message, msg) {
+				n++
+			}
+		}
+		return n
+	}
+
+	for i := 0; i < 100; i++ {
+		fe.notifyCartUpdate(testSessionID, fe.cartUpdateClients.nextSeq(), []*pb.CartItem{{ProductId: "P1", Quantity: int32(i)}})
+		<-updates
+	}
+	if got := count(logrus.InfoLevel, "sent cart update"); got != 1 {
+		t.Errorf("logged %d of 100 rapid cart updates, want 1", got)
+	}
+
+	// Errors are never sampled.
+	backends.cart.getErr = errors.New("cart is down")
+	for i := 0; i < 5; i++ {
+		fe.pushCart(context.Background(), testSessionID)
+	}
+	if got := count(logrus.ErrorLevel, "failed to get cart for notification"); got != 5 {
+		t.Errorf("logged %d of 5 cart errors, want all of them", got)
+	}
+}
+
+func TestLogSamplerInterval(t *testing.T) {
+	var s logSampler
+	start := time.Now()
+
+	if ok, _ := s.allow("u1", start); !ok {
+		t.Fatal("first line held back")
+	}
+	for i := 1; i <= 3; i++ {
+		if ok, _ := s.allow("u1", start.Add(time.Duration(i)*time.Second)); ok {
+			t.Fatalf("line %d let through within the interval", i)
+		}
+	}
+	if ok, _ := s.allow("u2", start.Add(time.Second)); !ok {
+		t.Error("another key's first line held back")
+	}
+	ok, suppressed := s.allow("u1", start.Add(cartUpdateLogInterval))
+	if !ok || suppressed != 3 {
+		t.Errorf("got (%v, %d) after the interval, want (true, 3)", ok, suppressed)
+	}
+}
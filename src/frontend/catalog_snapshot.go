@@ -0,0 +1,76 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+const productSnapshotRefreshTimeout = 10 * time.Second
+
+// productSnapshot holds the last product list successfully fetched from the
+// catalog service. getProducts serves it when the live call fails.
+type productSnapshot struct {
+	mu        sync.RWMutex
+	products  []*pb.Product
+	updatedAt time.Time
+}
+
+func (s *productSnapshot) get() ([]*pb.Product, time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.products, s.updatedAt, !s.updatedAt.IsZero()
+}
+
+func (s *productSnapshot) set(products []*pb.Product, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.products = products
+	s.updatedAt = at
+}
+
+// runProductSnapshotRefresher refreshes the product snapshot right away and
+// then every interval until ctx is cancelled. Each wait adds up to 10% random
+// jitter so replicas started together don't hit the catalog in lockstep.
+// A failed refresh keeps the previous snapshot.
+func (fe *frontendServer) runProductSnapshotRefresher(ctx context.Context, clk clock, interval time.Duration) {
+	for {
+		fe.refreshProductSnapshot(ctx, clk)
+		select {
+		case <-ctx.Done():
+			return
+		case <-clk.After(interval + time.Duration(rand.Int63n(int64(interval)/10+1))):
+		}
+	}
+}
+
+func (fe *frontendServer) refreshProductSnapshot(ctx context.Context, clk clock) error {
+	ctx, cancel := context.WithTimeout(ctx, productSnapshotRefreshTimeout)
+	defer cancel()
+
+	products, err := fe.fetchProducts(ctx)
+	if err != nil {
+		log.WithError(err).Warn("failed to refresh product catalog snapshot")
+		return err
+	}
+	fe.productSnapshot.set(products, clk.Now())
+	log.WithField("products", len(products)).Debug("refreshed product catalog snapshot")
+	return nil
+}
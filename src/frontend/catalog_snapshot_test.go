@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// fakeClock hands every After duration to the test and only fires when the
+// test sends on fire.
+type fakeClock struct {
+	now   time.Time
+	waits chan time.Duration
+	fire  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		waits: make(chan time.Duration),
+		fire:  make(chan time.Time),
+	}
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits <- d
+	return c.fire
+}
+
+func snapshotIDs(fe *frontendServer) []string {
+	products, _, _ := fe.productSnapshot.get()
+	var ids []string
+	for _, p := range products {
+		ids = append(ids, p.GetId())
+	}
+	return ids
+}
+
+func TestProductSnapshotRefresher(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "a"})
+
+	const interval = time.Minute
+	clk := newFakeClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		fe.runProductSnapshotRefresher(ctx, clk, interval)
+		close(done)
+	}()
+
+	// The first refresh happens immediately, then the loop waits.
+	d := <-clk.waits
+	if d < interval || d > interval+interval/10 {
+		t.Errorf("got wait %v, want between %v and %v", d, interval, interval+interval/10)
+	}
+	if got := snapshotIDs(fe); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("after first refresh got snapshot %v, want [a]", got)
+	}
+
+	// A failed refresh keeps the previous snapshot and the loop carries on.
+	backends.catalog.setListErr(errors.New("catalog down"))
+	clk.fire <- clk.now
+	<-clk.waits
+	if got := snapshotIDs(fe); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("after failed refresh got snapshot %v, want [a]", got)
+	}
+
+	backends.catalog.setListErr(nil)
+	backends.catalog.setProducts(&pb.Product{Id: "a"}, &pb.Product{Id: "b"})
+	clk.fire <- clk.now
+	<-clk.waits
+	if got := snapshotIDs(fe); len(got) != 2 {
+		t.Fatalf("after recovery got snapshot %v, want [a b]", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresher did not stop after cancel")
+	}
+}
+
+func TestGetProductsServesSnapshotWhenCatalogDown(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	ctx := context.Background()
+
+	backends.catalog.setListErr(errors.New("catalog down"))
+	if _, err := fe.getProducts(ctx); err == nil {
+		t.Fatal("expected an error with no snapshot available")
+	}
+
+	fe.productSnapshot.set([]*pb.Product{{Id: "cached"}}, time.Now())
+	products, err := fe.getProducts(ctx)
+	if err != nil {
+		t.Fatalf("getProducts returned error with snapshot available: %v", err)
+	}
+	if len(products) != 1 || products[0].GetId() != "cached" {
+		t.Errorf("got %v, want the snapshot", products)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// lazyClient holds a typed gRPC client, created on first use so that it
+// picks up the connection however the server was set up. It is safe for
+// concurrent use. The zero value is ready to use.
+type lazyClient[T any] struct {
+	once   sync.Once
+	client T
+}
+
+func (c *lazyClient[T]) get(newClient func() T) T {
+	c.once.Do(func() { c.client = newClient() })
+	return c.client
+}
+
+// set makes client the one get returns, for injecting fakes in tests. It has
+// no effect once the client has been used.
+func (c *lazyClient[T]) set(client T) {
+	c.once.Do(func() { c.client = client })
+}
+
+func (fe *frontendServer) productCatalogService() pb.ProductCatalogServiceClient {
+	return fe.productCatalogClient.get(func() pb.ProductCatalogServiceClient {
+		return pb.NewProductCatalogServiceClient(fe.productCatalogSvcConn)
+	})
+}
+
+func (fe *frontendServer) currencyService() pb.CurrencyServiceClient {
+	return fe.currencyClient.get(func() pb.CurrencyServiceClient {
+		return pb.NewCurrencyServiceClient(fe.currencySvcConn)
+	})
+}
+
+func (fe *frontendServer) cartService() pb.CartServiceClient {
+	return fe.cartClient.get(func() pb.CartServiceClient {
+		return pb.NewCartServiceClient(fe.cartSvcConn)
+	})
+}
+
+func (fe *frontendServer) recommendationService() pb.RecommendationServiceClient {
+	return fe.recommendationClient.get(func() pb.RecommendationServiceClient {
+		return pb.NewRecommendationServiceClient(fe.recommendationSvcConn)
+	})
+}
+
+func (fe *frontendServer) checkoutService() pb.CheckoutServiceClient {
+	return fe.checkoutClient.get(func() pb.CheckoutServiceClient {
+		return pb.NewCheckoutServiceClient(fe.checkoutSvcConn)
+	})
+}
+
+func (fe *frontendServer) shippingService() pb.ShippingServiceClient {
+	return fe.shippingClient.get(func() pb.ShippingServiceClient {
+		return pb.NewShippingServiceClient(fe.shippingSvcConn)
+	})
+}
+
+func (fe *frontendServer) adService() pb.AdServiceClient {
+	return fe.adClient.get(func() pb.AdServiceClient {
+		return pb.NewAdServiceClient(fe.adSvcConn)
+	})
+}
+
+func (fe *frontendServer) recipeService() pb.RecipeServiceClient {
+	return fe.recipeClient.get(func() pb.RecipeServiceClient {
+		return pb.NewRecipeServiceClient(fe.recipeSvcConn)
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// stubRecipeClient answers GetRecipe itself, failing with err if set, and
+// panics on anything else.
+type stubRecipeClient struct {
+	pb.RecipeServiceClient
+	recipe *pb.Recipe
+	err    error
+}
+
+func (c stubRecipeClient) GetRecipe(context.Context, *pb.GetRecipeRequest, ...grpc.CallOption) (*pb.GetRecipeResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &pb.GetRecipeResponse{Recipe: c.recipe}, nil
+}
+
+func TestRecipeHandlersUseInjectedRecipeClient(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.recipe.recipes = []*pb.Recipe{{RecipeId: "r1", Instructions: []string{"From the recipe service."}}}
+	fe.recipeClient.set(stubRecipeClient{recipe: &pb.Recipe{RecipeId: "r1", Instructions: []string{"From the stub."}}})
+
+	req := mux.SetURLVars(newTestRequest(http.MethodGet, "/api/recipe/r1/instructions", nil), map[string]string{"id": "r1"})
+	rr := httptest.NewRecorder()
+	fe.recipeInstructionsAPIHandler(rr, req)
+
+	var resp struct {
+		Instructions []string `json:"instructions"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %q: %v", rr.Body, err)
+	}
+	if len(resp.Instructions) != 1 || resp.Instructions[0] != "From the stub." {
+		t.Errorf("got instructions %q, want the stub's", resp.Instructions)
+	}
+	if fe.recipeService() != fe.recipeService() {
+		t.Error("recipeService returned a different client on each call")
+	}
+}
@@ -0,0 +1,610 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/validator"
+)
+
+// Tunables read from the environment by loadConfig. The declared values are
+// the defaults, so tests (which never call loadConfig) see the same behavior
+// as an unconfigured deployment.
+var (
+	// How often the product catalog snapshot is refreshed. Zero disables the
+	// background refresher.
+	productSnapshotRefreshInterval = 5 * time.Minute
+
+	// Upper bound for the page_size query parameter on paginated lists.
+	maxPageSize = 100
+
+	// Symbol shown for currencies without a known logo (e.g. "¤"). Empty
+	// means the ISO code itself is shown.
+	unknownCurrencySymbol = ""
+
+	// Decimal places prices are rounded to and shown with, by currency code.
+	// Currencies not listed use defaultPricePrecision.
+	defaultPricePrecision = 2
+	pricePrecision        = map[string]int{}
+
+	// How long a session's order history is kept after its last order.
+	orderHistoryTTL = 48 * time.Hour
+
+	// Deadline for the ingredient availability check on suggested recipe
+	// pages. When it expires the page falls back to the static catalog check.
+	availabilityCheckTimeout = 3 * time.Second
+
+	// Ingredients per availability check call on suggested recipe pages, so
+	// a problem ingredient fails only its own batch. Zero checks them all in
+	// one call. Batches run at most availabilityCheckConcurrency at a time.
+	availabilityCheckBatchSize   = 0
+	availabilityCheckConcurrency = 4
+
+	// Most products shown on the home page. Zero shows them all.
+	homeProductLimit = 50
+
+	// Most ingredients listed up front on a recipe page; the rest are behind
+	// "show more". Zero lists them all.
+	maxDisplayedIngredients = 15
+
+	// Staples users are assumed to have at home. Recipe ingredients naming
+	// one, as a whole word, aren't added to the cart and are shown as not
+	// sold. Lowercase.
+	pantryIngredients = []string{
+		"herbs", "salt", "black pepper", "white pepper", "seasoning", "spice", "spices",
+		"garlic powder", "onion powder", "extract", "essence", "flavoring", "vanilla",
+		"baking powder", "baking soda", "yeast", "water", "ice", "stock", "broth",
+	}
+
+	// Most servings a recipe can be scaled to or added to the cart for.
+	maxRecipeServings = 50
+
+	// Number of credit card expiration years offered at checkout, starting
+	// with the current year.
+	expirationYearCount = 5
+
+	// Deadline for GetSuggestedRecipes, which generates images and is slow.
+	suggestedRecipesTimeout = 30 * time.Second
+
+	// Time zone suggested recipe requests are placed in to derive the meal
+	// time when the client doesn't give one, e.g. "America/New_York".
+	mealTimeLocation = time.UTC
+
+	// Cart updates, and separately announcements, buffered per connected
+	// client. Every update carries the whole cart, so when a slow client's
+	// buffer is full its oldest pending update is discarded for the new one:
+	// a smaller buffer costs such a client intermediate carts, never the
+	// latest. Announcements aren't snapshots, so a full buffer drops new ones.
+	// Each buffered update holds a cart, so a larger buffer costs memory per
+	// connection.
+	cartUpdateBufferSize = 10
+
+	// Most cart update connections a user may open per
+	// cartUpdateReconnectWindow before getting a 429. Zero disables the limit.
+	cartUpdateMaxReconnects   = 20
+	cartUpdateReconnectWindow = time.Minute
+
+	// Routine cart update logs (updates sent, clients connecting) are written
+	// at most once per interval per user, with a count of the ones skipped.
+	// Warnings and errors are always written. Zero logs every event.
+	cartUpdateLogInterval = 10 * time.Second
+
+	// Requests with a method a route doesn't allow are logged at most once
+	// per interval per session, with a count of the ones skipped. Zero logs
+	// every one.
+	methodNotAllowedLogInterval = time.Minute
+
+	// How long product names looked up for cart updates are cached. Zero
+	// disables the cache.
+	productNameCacheTTL = 30 * time.Second
+
+	// How long the rendered recipe list grid is cached per currency, page and
+	// filters. Zero disables the cache.
+	recipeListCacheTTL = 30 * time.Second
+
+	// How long exchange rates used to convert whole pages of prices are
+	// cached. Zero disables the cache; the last rate is still kept for
+	// staleCurrencyFallback.
+	currencyRateCacheTTL = time.Minute
+
+	// Whether prices are shown with the last known exchange rate, or in USD
+	// when there is none, while the currency service is down, instead of
+	// failing the page.
+	staleCurrencyFallback = false
+
+	// Attempts made at a shopping assistant request that fails to connect
+	// or gets a 502/503, at most maxAssistantRetryAttempts, and the wait
+	// before the first retry, which doubles after each one.
+	assistantRetryAttempts = 3
+	assistantRetryBackoff  = 200 * time.Millisecond
+
+	// Level at which each exchange with the shopping assistant is logged, and
+	// how many bytes of each body the log line keeps. Bodies are shoppers'
+	// chat messages, so the default keeps them out of production logs. A
+	// limit of zero leaves bodies out at any level.
+	assistantLogLevel     = logrus.DebugLevel
+	assistantLogBodyLimit = 512
+
+	// Largest base64 recipe image, in bytes, that is cached and returned with
+	// suggested recipes. Larger images are dropped. Zero disables the limit.
+	maxRecipeImageSize = 2 << 20
+
+	// Most units of a product that can be added to the cart at once.
+	maxAddToCartQuantity = validator.DefaultMaxQuantity
+
+	// Longest a request may take before it is cut off with a 503, except for
+	// the cart update streams. Keep it below httpWriteTimeout so the 503 can
+	// still be written, and above suggestedRecipesTimeout. Zero disables it.
+	handlerTimeout = 45 * time.Second
+
+	// How long each attempt at connecting to a backend at startup may wait
+	// for the connection to be ready. Only criticalBackends are waited for.
+	// Each can override it with <NAME>_DIAL_TIMEOUT, named after its address
+	// variable: CART_SERVICE_DIAL_TIMEOUT for CART_SERVICE_ADDR. Zero means
+	// startup doesn't wait.
+	grpcDialTimeout = 3 * time.Second
+
+	// Attempts made at connecting to each backend at startup before giving
+	// up, and the wait before the first retry, which doubles after each one.
+	grpcDialAttempts = 5
+	grpcDialBackoff  = time.Second
+
+	// http.Server timeouts. The cart update streams clear their write
+	// deadline, so httpWriteTimeout only bounds ordinary requests.
+	httpReadHeaderTimeout = 10 * time.Second
+	httpReadTimeout       = 30 * time.Second
+	httpWriteTimeout      = 60 * time.Second
+	httpIdleTimeout       = 120 * time.Second
+
+	// Ad slots templates can request, mapped to the ad context keys used to
+	// fill them. A slot without keys uses the page's own context, such as the
+	// product's categories.
+	adSlots = map[string][]string{"banner": nil}
+
+	// Whether the no_ads and no_recs query parameters may turn off ads and
+	// recommendations for a single page, for demos and screenshots.
+	demoTogglesEnabled = false
+
+	// Lowest matchIngredientToCart confidence at which a recipe ingredient
+	// counts as already in the cart. Raise it to avoid false positives such
+	// as "chicken breast" matching chicken thighs (0.5); lower it to catch
+	// more loosely named products. Between 0 and 1.
+	ingredientMatchThreshold = 0.5
+
+	// Platform shown when ENV_PLATFORM is unset or not one of validEnvs, and
+	// the frontend isn't on GCP.
+	fallbackPlatform = "local"
+
+	// How recommendations are post-processed before they are shown; one of
+	// recommendationStrategies: "default" shows them as the recommendation
+	// service returns them, "cart-dedup" drops products already in the cart,
+	// and "same-category" moves products related to the viewed ones first.
+	recommendationStrategyName = "default"
+
+	// Prepended to every cookie name. Instances sharing a domain need
+	// different prefixes so they don't read each other's cookies.
+	cookiePrefix = "shop_"
+
+	// Most recipes listed as similar to a recipe.
+	similarRecipesCount = 6
+
+	// Order recipe ingredients are listed in unless the page asks for
+	// another with ?sort: original, availability or name.
+	ingredientOrder = ingredientOrderOriginal
+
+	// Products with this many or fewer left in stock are marked as running
+	// out. Products whose stock isn't known never are.
+	lowStockThreshold = 5
+
+	// Origins allowed to read the cart update stream cross-origin. Empty
+	// allows any origin, without cookies.
+	sseAllowedOrigins []string
+
+	// With SELF_TEST set the frontend checks its backends, prints a report
+	// and exits instead of serving. Each check gets selfTestTimeout.
+	selfTestMode    = false
+	selfTestTimeout = 5 * time.Second
+
+	// On SIGTERM or SIGINT, how long cart update clients get to reconnect
+	// elsewhere before their streams are closed, and then how long in-flight
+	// requests get to finish. Together they should fit in the pod's
+	// termination grace period.
+	cartUpdateDrainGrace = 5 * time.Second
+	shutdownTimeout      = 15 * time.Second
+
+	// Whether adding a recipe to a cart is announced to every connected
+	// cart update client, for shared demos. Cart contents are never shared.
+	broadcastRecipeAdds = false
+
+	// Whether securityHeaders sets the CSP, X-Content-Type-Options,
+	// X-Frame-Options and Referrer-Policy headers.
+	securityHeadersEnabled = true
+
+	// Content-Security-Policy sent with every response; empty sends none.
+	// The templates use inline scripts and event handlers and load Bootstrap,
+	// jQuery and fonts from CDNs. Suggested recipe images are data: URLs and
+	// cart updates stream from this origin over EventSource or WebSocket,
+	// which connect-src 'self' covers.
+	contentSecurityPolicy = strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'unsafe-inline' https://code.jquery.com https://cdnjs.cloudflare.com https://stackpath.bootstrapcdn.com",
+		"style-src 'self' 'unsafe-inline' https://stackpath.bootstrapcdn.com https://fonts.googleapis.com",
+		"font-src 'self' https://fonts.gstatic.com",
+		"img-src 'self' data: https://via.placeholder.com",
+		"connect-src 'self'",
+		"frame-ancestors 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+	}, "; ")
+
+	// A/B experiments sessions are bucketed into. None by default.
+	experiments []experiment
+)
+
+// loadConfig overrides the tunables above from the environment. Invalid values
+// are logged and the default is kept.
+func loadConfig(log logrus.FieldLogger) {
+	productSnapshotRefreshInterval = envDuration(log, "PRODUCT_SNAPSHOT_REFRESH_INTERVAL", productSnapshotRefreshInterval)
+	maxPageSize = envInt(log, "MAX_PAGE_SIZE", maxPageSize, 1)
+	unknownCurrencySymbol = os.Getenv("UNKNOWN_CURRENCY_SYMBOL")
+	orderHistoryTTL = envDuration(log, "ORDER_HISTORY_TTL", orderHistoryTTL)
+	availabilityCheckTimeout = envDuration(log, "AVAILABILITY_CHECK_TIMEOUT", availabilityCheckTimeout)
+	availabilityCheckBatchSize = envInt(log, "AVAILABILITY_CHECK_BATCH_SIZE", availabilityCheckBatchSize, 0)
+	availabilityCheckConcurrency = envInt(log, "AVAILABILITY_CHECK_CONCURRENCY", availabilityCheckConcurrency, 1)
+	homeProductLimit = envInt(log, "HOME_PRODUCT_LIMIT", homeProductLimit, 0)
+	maxDisplayedIngredients = envInt(log, "MAX_DISPLAYED_INGREDIENTS", maxDisplayedIngredients, 0)
+	expirationYearCount = envInt(log, "EXPIRATION_YEAR_COUNT", expirationYearCount, 1)
+	if maxRecipeServings = envInt(log, "MAX_RECIPE_SERVINGS", maxRecipeServings, 1); maxRecipeServings > math.MaxInt32 {
+		log.Warnf("MAX_RECIPE_SERVINGS %d is too large, using %d", maxRecipeServings, math.MaxInt32)
+		maxRecipeServings = math.MaxInt32
+	}
+	suggestedRecipesTimeout = envDuration(log, "SUGGESTED_RECIPES_TIMEOUT", suggestedRecipesTimeout)
+	cartUpdateLogInterval = envDuration(log, "CART_UPDATE_LOG_INTERVAL", cartUpdateLogInterval)
+	// WebSocket clients are dropped if a ping doesn't arrive within
+	// cartUpdatePongWait, so the interval has to be shorter.
+	if d := envDuration(log, "SSE_HEARTBEAT_INTERVAL", cartUpdateKeepalive); d > 0 && d < cartUpdatePongWait {
+		cartUpdateKeepalive = d
+	} else {
+		log.Warnf("invalid SSE_HEARTBEAT_INTERVAL %v, want between 0 and %v; using %v", d, cartUpdatePongWait, cartUpdateKeepalive)
+	}
+	methodNotAllowedLogInterval = envDuration(log, "METHOD_NOT_ALLOWED_LOG_INTERVAL", methodNotAllowedLogInterval)
+	productNameCacheTTL = envDuration(log, "PRODUCT_NAME_CACHE_TTL", productNameCacheTTL)
+	recipeListCacheTTL = envDuration(log, "RECIPE_LIST_CACHE_TTL", recipeListCacheTTL)
+	currencyRateCacheTTL = envDuration(log, "CURRENCY_RATE_CACHE_TTL", currencyRateCacheTTL)
+	staleCurrencyFallback = envBool(log, "STALE_CURRENCY_FALLBACK", staleCurrencyFallback)
+	cartUpdateBufferSize = envInt(log, "CART_UPDATE_BUFFER_SIZE", cartUpdateBufferSize, 1)
+	cartUpdateMaxReconnects = envInt(log, "CART_UPDATE_MAX_RECONNECTS", cartUpdateMaxReconnects, 0)
+	cartUpdateReconnectWindow = envDuration(log, "CART_UPDATE_RECONNECT_WINDOW", cartUpdateReconnectWindow)
+	maxRecipeImageSize = envInt(log, "MAX_RECIPE_IMAGE_SIZE", maxRecipeImageSize, 0)
+	if assistantRetryAttempts = envInt(log, "ASSISTANT_RETRY_ATTEMPTS", assistantRetryAttempts, 1); assistantRetryAttempts > maxAssistantRetryAttempts {
+		log.Warnf("ASSISTANT_RETRY_ATTEMPTS %d is too many, using %d", assistantRetryAttempts, maxAssistantRetryAttempts)
+		assistantRetryAttempts = maxAssistantRetryAttempts
+	}
+	assistantRetryBackoff = envDuration(log, "ASSISTANT_RETRY_BACKOFF", assistantRetryBackoff)
+	if v := os.Getenv("ASSISTANT_LOG_LEVEL"); v != "" {
+		if level, err := logrus.ParseLevel(v); err == nil {
+			assistantLogLevel = level
+		} else {
+			log.Warnf("invalid ASSISTANT_LOG_LEVEL %q, using %v", v, assistantLogLevel)
+		}
+	}
+	assistantLogBodyLimit = envInt(log, "ASSISTANT_LOG_BODY_LIMIT", assistantLogBodyLimit, 0)
+	handlerTimeout = envDuration(log, "HANDLER_TIMEOUT", handlerTimeout)
+	demoTogglesEnabled = envBool(log, "DEMO_TOGGLES_ENABLED", demoTogglesEnabled)
+	grpcDialTimeout = envDuration(log, "GRPC_DIAL_TIMEOUT", grpcDialTimeout)
+	grpcDialAttempts = envInt(log, "GRPC_DIAL_ATTEMPTS", grpcDialAttempts, 1)
+	grpcDialBackoff = envDuration(log, "GRPC_DIAL_BACKOFF", grpcDialBackoff)
+	httpReadHeaderTimeout = envDuration(log, "HTTP_READ_HEADER_TIMEOUT", httpReadHeaderTimeout)
+	httpReadTimeout = envDuration(log, "HTTP_READ_TIMEOUT", httpReadTimeout)
+	httpWriteTimeout = envDuration(log, "HTTP_WRITE_TIMEOUT", httpWriteTimeout)
+	httpIdleTimeout = envDuration(log, "HTTP_IDLE_TIMEOUT", httpIdleTimeout)
+	ingredientMatchThreshold = envFraction(log, "INGREDIENT_MATCH_THRESHOLD", ingredientMatchThreshold)
+	if v := os.Getenv("AD_SLOTS"); v != "" {
+		if slots, err := parseAdSlots(v); err != nil {
+			log.WithError(err).Warn("invalid AD_SLOTS, using default ad slots")
+		} else {
+			adSlots = slots
+		}
+	}
+	defaultPricePrecision = envInt(log, "DEFAULT_PRICE_PRECISION", defaultPricePrecision, 0)
+	if defaultPricePrecision > maxPricePrecision {
+		log.Warnf("DEFAULT_PRICE_PRECISION %d is finer than a nano, using %d", defaultPricePrecision, maxPricePrecision)
+		defaultPricePrecision = maxPricePrecision
+	}
+	if v := os.Getenv("PRICE_PRECISION"); v != "" {
+		if precision, err := parsePricePrecision(v); err != nil {
+			log.WithError(err).Warn("invalid PRICE_PRECISION, using the default precision for every currency")
+		} else {
+			pricePrecision = precision
+		}
+	}
+	if v := os.Getenv("FALLBACK_PLATFORM"); v != "" {
+		if stringinSlice(validEnvs, v) {
+			fallbackPlatform = v
+		} else {
+			log.Warnf("invalid FALLBACK_PLATFORM %q, want one of %v; using %q", v, validEnvs, fallbackPlatform)
+		}
+	}
+	if v := os.Getenv("MEAL_TIME_TIMEZONE"); v != "" {
+		if loc, err := time.LoadLocation(v); err != nil {
+			log.WithError(err).Warnf("invalid MEAL_TIME_TIMEZONE %q, using %v", v, mealTimeLocation)
+		} else {
+			mealTimeLocation = loc
+		}
+	}
+	if v := os.Getenv("RECOMMENDATION_STRATEGY"); v != "" {
+		if _, ok := recommendationStrategies[v]; ok {
+			recommendationStrategyName = v
+		} else {
+			log.Warnf("invalid RECOMMENDATION_STRATEGY %q, using %q", v, recommendationStrategyName)
+		}
+	}
+	// An empty COOKIE_PREFIX is allowed and leaves the names unprefixed.
+	if v, ok := os.LookupEnv("COOKIE_PREFIX"); ok {
+		if err := validateCookiePrefix(v); err != nil {
+			log.WithError(err).Warnf("using cookie prefix %q", cookiePrefix)
+		} else {
+			setCookiePrefix(v)
+		}
+	}
+	similarRecipesCount = envInt(log, "SIMILAR_RECIPES_COUNT", similarRecipesCount, 1)
+	if v := os.Getenv("INGREDIENT_ORDER"); v != "" {
+		if v = strings.ToLower(v); validIngredientOrder(v) {
+			ingredientOrder = v
+		} else {
+			log.Warnf("invalid INGREDIENT_ORDER %q, using %q", v, ingredientOrder)
+		}
+	}
+	lowStockThreshold = envInt(log, "LOW_STOCK_THRESHOLD", lowStockThreshold, 0)
+	if v := os.Getenv("SSE_ALLOWED_ORIGINS"); v != "" {
+		sseAllowedOrigins = parseAllowedOrigins(v)
+	}
+	selfTestMode = envBool(log, "SELF_TEST", selfTestMode)
+	selfTestTimeout = envDuration(log, "SELF_TEST_TIMEOUT", selfTestTimeout)
+	cartUpdateDrainGrace = envDuration(log, "SSE_DRAIN_GRACE_PERIOD", cartUpdateDrainGrace)
+	shutdownTimeout = envDuration(log, "SHUTDOWN_TIMEOUT", shutdownTimeout)
+	broadcastRecipeAdds = envBool(log, "BROADCAST_RECIPE_ADDS", broadcastRecipeAdds)
+	securityHeadersEnabled = envBool(log, "SECURITY_HEADERS_ENABLED", securityHeadersEnabled)
+	// An empty CONTENT_SECURITY_POLICY turns the CSP off.
+	if v, ok := os.LookupEnv("CONTENT_SECURITY_POLICY"); ok {
+		contentSecurityPolicy = strings.TrimSpace(v)
+	}
+	// Unlike most settings an empty PANTRY_INGREDIENTS counts: it turns the
+	// filter off.
+	if v, ok := os.LookupEnv("PANTRY_INGREDIENTS"); ok {
+		pantryIngredients = parsePantryIngredients(v)
+	}
+	if v := os.Getenv("EXPERIMENTS"); v != "" {
+		if exps, err := parseExperiments(v); err != nil {
+			log.WithError(err).Warn("invalid EXPERIMENTS, running no experiments")
+		} else {
+			experiments = exps
+		}
+	}
+	if maxAddToCartQuantity = envInt(log, "MAX_ADD_TO_CART_QUANTITY", maxAddToCartQuantity, 1); maxAddToCartQuantity > math.MaxInt32 {
+		log.Warnf("MAX_ADD_TO_CART_QUANTITY %d does not fit the cart service, using default %d", maxAddToCartQuantity, validator.DefaultMaxQuantity)
+		maxAddToCartQuantity = validator.DefaultMaxQuantity
+	}
+}
+
+// backendDialTimeout returns the dial timeout for the backend whose address
+// is in addrKey, such as CART_SERVICE_ADDR: CART_SERVICE_DIAL_TIMEOUT if set,
+// otherwise grpcDialTimeout.
+func backendDialTimeout(log logrus.FieldLogger, addrKey string) time.Duration {
+	return envDuration(log, strings.TrimSuffix(addrKey, "_ADDR")+"_DIAL_TIMEOUT", grpcDialTimeout)
+}
+
+// criticalBackends are the address variables of the backends every page
+// needs, the ones selfTest treats as critical.
+var criticalBackends = map[string]bool{
+	"PRODUCT_CATALOG_SERVICE_ADDR": true,
+	"CURRENCY_SERVICE_ADDR":        true,
+	"CART_SERVICE_ADDR":            true,
+	"CHECKOUT_SERVICE_ADDR":        true,
+}
+
+// startupDialTimeout is how long startup waits for the backend whose address
+// is in addrKey to be ready: backendDialTimeout for criticalBackends, and
+// zero, meaning not at all, for the rest. A frontend without ads or
+// recommendations is still useful, so those connect in the background. Self-
+// test mode waits for none, since it reports unreachable backends itself.
+func startupDialTimeout(log logrus.FieldLogger, addrKey string) time.Duration {
+	if selfTestMode || !criticalBackends[addrKey] {
+		return 0
+	}
+	return backendDialTimeout(log, addrKey)
+}
+
+// normalizeBaseURL turns BASE_URL into the form routes are registered with:
+// either empty or a leading slash and no trailing slash ("/shop").
+func normalizeBaseURL(raw string) (string, error) {
+	p := strings.Trim(strings.TrimSpace(raw), "/")
+	if p == "" {
+		return "", nil
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid BASE_URL %q: empty or relative path segment", raw)
+		}
+		for _, c := range segment {
+			if !isBaseURLChar(c) {
+				return "", fmt.Errorf("invalid BASE_URL %q: character %q not allowed", raw, c)
+			}
+		}
+	}
+	return "/" + p, nil
+}
+
+// validateCookiePrefix checks that prefix only has characters that are safe
+// in a cookie name everywhere. Prefixes starting with "__" are refused
+// because browsers reject __Host- and __Secure- cookies that don't meet extra
+// requirements.
+func validateCookiePrefix(prefix string) error {
+	if strings.HasPrefix(prefix, "__") {
+		return fmt.Errorf("invalid COOKIE_PREFIX %q: must not start with \"__\"", prefix)
+	}
+	for _, c := range prefix {
+		if !isBaseURLChar(c) {
+			return fmt.Errorf("invalid COOKIE_PREFIX %q: character %q not allowed", prefix, c)
+		}
+	}
+	return nil
+}
+
+// parseAdSlots parses AD_SLOTS, a semicolon-separated list of slots with
+// optional comma-separated context keys: "banner;sidebar=kitchen,cookware".
+func parseAdSlots(raw string) (map[string][]string, error) {
+	slots := make(map[string][]string)
+	for _, entry := range strings.Split(raw, ";") {
+		name, keys, _ := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("ad slot %q has no name", entry)
+		}
+		if _, dup := slots[name]; dup {
+			return nil, fmt.Errorf("ad slot %q is listed twice", name)
+		}
+		var ctxKeys []string
+		for _, k := range strings.Split(keys, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				ctxKeys = append(ctxKeys, k)
+			}
+		}
+		slots[name] = ctxKeys
+	}
+	return slots, nil
+}
+
+// maxAssistantRetryAttempts bounds assistantRetryAttempts so the doubling
+// backoff stays far from overflowing.
+const maxAssistantRetryAttempts = 10
+
+// maxPricePrecision is the finest precision pb.Money can hold: nanos.
+const maxPricePrecision = 9
+
+// parsePricePrecision parses PRICE_PRECISION, a comma-separated list of
+// currency codes and decimal places: "JPY=0,BHD=3".
+func parsePricePrecision(raw string) (map[string]int, error) {
+	precision := make(map[string]int)
+	for _, entry := range strings.Split(raw, ",") {
+		code, digits, ok := strings.Cut(entry, "=")
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if !ok || code == "" {
+			return nil, fmt.Errorf("price precision %q is not CODE=digits", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(digits))
+		if err != nil || n < 0 || n > maxPricePrecision {
+			return nil, fmt.Errorf("price precision for %s must be 0 to %d decimal places, got %q", code, maxPricePrecision, digits)
+		}
+		if _, dup := precision[code]; dup {
+			return nil, fmt.Errorf("price precision for %s is listed twice", code)
+		}
+		precision[code] = n
+	}
+	return precision, nil
+}
+
+// parsePantryIngredients parses PANTRY_INGREDIENTS, a comma-separated list of
+// staples: "salt, black pepper, water".
+func parsePantryIngredients(raw string) []string {
+	var terms []string
+	for _, term := range strings.Split(raw, ",") {
+		if term = pantryWords(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// parseAllowedOrigins parses SSE_ALLOWED_ORIGINS, a comma-separated list of
+// origins: "https://shop.example.com, https://embed.example.com".
+func parseAllowedOrigins(raw string) []string {
+	var origins []string
+	for _, origin := range strings.Split(raw, ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// isBaseURLChar reports whether c is an unreserved URL character (RFC 3986).
+func isBaseURLChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func envDuration(log logrus.FieldLogger, key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Warnf("invalid duration %q for %s, using default %v", v, key, def)
+		return def
+	}
+	return d
+}
+
+// envInt reads an integer no smaller than min.
+func envInt(log logrus.FieldLogger, key string, def, min int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min {
+		log.Warnf("invalid value %q for %s, using default %d", v, key, def)
+		return def
+	}
+	return n
+}
+
+// envFraction reads a number between 0 and 1.
+func envFraction(log logrus.FieldLogger, key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		log.Warnf("invalid value %q for %s, using default %v", v, key, def)
+		return def
+	}
+	return f
+}
+
+// envBool reads a boolean such as "true", "1" or "false".
+func envBool(log logrus.FieldLogger, key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Warnf("invalid value %q for %s, using default %v", v, key, def)
+		return def
+	}
+	return b
+}
@@ -0,0 +1,150 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"empty", "", "", false},
+		{"root only", "/", "", false},
+		{"trailing slash", "/shop/", "/shop", false},
+		{"missing leading slash", "shop", "/shop", false},
+		{"nested", "store/shop", "/store/shop", false},
+		{"empty segment", "/store//shop", "", true},
+		{"parent segment", "/shop/..", "", true},
+		{"invalid character", "/shop?x=1", "", true},
+		{"space", "/my shop", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeBaseURL(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeBaseURL(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeBaseURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAdSlots(t *testing.T) {
+	got, err := parseAdSlots("banner; sidebar=kitchen, cookware ;footer=")
+	if err != nil {
+		t.Fatalf("parseAdSlots: %v", err)
+	}
+	if len(got) != 3 || got["banner"] != nil || got["footer"] != nil {
+		t.Errorf("got %v, want banner and footer without keys", got)
+	}
+	if keys := got["sidebar"]; len(keys) != 2 || keys[0] != "kitchen" || keys[1] != "cookware" {
+		t.Errorf("got sidebar keys %q, want [kitchen cookware]", keys)
+	}
+
+	for _, raw := range []string{"banner;;footer", "=kitchen", "banner;banner=kitchen"} {
+		if _, err := parseAdSlots(raw); err == nil {
+			t.Errorf("parseAdSlots(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestParsePricePrecision(t *testing.T) {
+	got, err := parsePricePrecision("JPY=0, bhd = 3")
+	if err != nil {
+		t.Fatalf("parsePricePrecision: %v", err)
+	}
+	if len(got) != 2 || got["JPY"] != 0 || got["BHD"] != 3 {
+		t.Errorf("got %v, want JPY=0 and BHD=3", got)
+	}
+
+	for _, raw := range []string{"JPY", "=2", "JPY=-1", "JPY=10", "JPY=two", "JPY=0,JPY=1"} {
+		if _, err := parsePricePrecision(raw); err == nil {
+			t.Errorf("parsePricePrecision(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestParseAllowedOrigins(t *testing.T) {
+	got := parseAllowedOrigins(" https://shop.example.com/, ,https://embed.example.com")
+	if len(got) != 2 || got[0] != "https://shop.example.com" || got[1] != "https://embed.example.com" {
+		t.Errorf("got %q, want the two origins without trailing slashes", got)
+	}
+}
+
+func TestValidateCookiePrefix(t *testing.T) {
+	for _, prefix := range []string{"", "shop_", "store-2.", "Kitchen~"} {
+		if err := validateCookiePrefix(prefix); err != nil {
+			t.Errorf("validateCookiePrefix(%q) = %v, want nil", prefix, err)
+		}
+	}
+	for _, prefix := range []string{"shop;", "my shop_", "shop=", "__Host-", "café_"} {
+		if err := validateCookiePrefix(prefix); err == nil {
+			t.Errorf("validateCookiePrefix(%q) succeeded, want an error", prefix)
+		}
+	}
+}
+
+func TestCookiesUseConfiguredPrefix(t *testing.T) {
+	defer setCookiePrefix(cookiePrefix)
+	setCookiePrefix("store2_")
+
+	rr := httptest.NewRecorder()
+	ensureSessionID(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).
+		ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if cookies := rr.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != "store2_session-id" {
+		t.Errorf("got cookies %v, want store2_session-id", cookies)
+	}
+
+	fe, _ := newTestFrontend(t)
+	form := "currency_code=EUR"
+	req := newTestRequest(http.MethodPost, "/setCurrency", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	fe.setCurrencyHandler(rr, req)
+	if cookies := rr.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != "store2_currency" {
+		t.Errorf("got cookies %v, want store2_currency", cookies)
+	}
+
+	req = newTestRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "store2_currency", Value: "EUR"})
+	if got := currentCurrency(req); got != "EUR" {
+		t.Errorf("currentCurrency read %q, want EUR from the prefixed cookie", got)
+	}
+}
+
+func TestLoadConfigCapsAssistantRetryAttempts(t *testing.T) {
+	defer func(old int) { assistantRetryAttempts = old }(assistantRetryAttempts)
+	l := logrus.New()
+	l.Out = io.Discard
+
+	t.Setenv("ASSISTANT_RETRY_ATTEMPTS", "1000")
+	loadConfig(l)
+	if assistantRetryAttempts != maxAssistantRetryAttempts {
+		t.Errorf("got %d retry attempts, want them capped at %d", assistantRetryAttempts, maxAssistantRetryAttempts)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/money"
+)
+
+// currencyRateCache remembers exchange rates for currencyRateCacheTTL so
+// pages listing many prices don't ask the currency service for each one. The
+// zero value is ready to use.
+type currencyRateCache struct {
+	mu      sync.Mutex
+	entries map[currencyPair]currencyRateEntry
+}
+
+type currencyPair struct{ from, to string }
+
+type currencyRateEntry struct {
+	rate    *pb.Money // value of one unit of from, in to
+	expires time.Time
+}
+
+func (c *currencyRateCache) get(pair currencyPair, now time.Time) (*pb.Money, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[pair]
+	if !ok || !now.Before(e.expires) {
+		return nil, false
+	}
+	return e.rate, true
+}
+
+// set remembers rate for pair. It is fresh for currencyRateCacheTTL, but
+// kept after that as the last known rate.
+func (c *currencyRateCache) set(pair currencyPair, rate *pb.Money, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[currencyPair]currencyRateEntry)
+	}
+	c.entries[pair] = currencyRateEntry{rate: rate, expires: now.Add(currencyRateCacheTTL)}
+}
+
+// lastKnown returns the most recent rate for pair, however old.
+func (c *currencyRateCache) lastKnown(pair currencyPair) (*pb.Money, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[pair]
+	return e.rate, ok
+}
+
+// currencyRate returns the value of one unit of from in to, asking the
+// currency service only when the rate isn't cached.
+func (fe *frontendServer) currencyRate(ctx context.Context, from, to string) (*pb.Money, error) {
+	pair := currencyPair{from, to}
+	if rate, ok := fe.currencyRates.get(pair, time.Now()); ok {
+		return rate, nil
+	}
+	rate, err := fe.requestConversion(ctx, &pb.Money{CurrencyCode: from, Units: 1}, to)
+	if err != nil {
+		if fallback, ok := fe.fallbackRate(ctx, pair, err); ok {
+			return fallback, nil
+		}
+		return nil, err
+	}
+	fe.currencyRates.set(pair, rate, time.Now())
+	return rate, nil
+}
+
+// fallbackRate returns the rate to use for pair when the currency service is
+// down and staleCurrencyFallback is on: the last known rate, or failing that
+// 1:1 in the source currency so prices are shown in it unconverted. The
+// request is marked as showing stale rates, and the fallback is logged with
+// the request's logger when ctx has one.
+func (fe *frontendServer) fallbackRate(ctx context.Context, pair currencyPair, err error) (*pb.Money, bool) {
+	if !staleCurrencyFallback {
+		return nil, false
+	}
+	if code := status.Code(err); code != codes.Unavailable && code != codes.DeadlineExceeded {
+		return nil, false
+	}
+	markStaleRates(ctx)
+	var l logrus.FieldLogger = log
+	if reqLog, ok := ctx.Value(ctxKeyLog{}).(logrus.FieldLogger); ok {
+		l = reqLog
+	}
+	l = l.WithError(err).WithFields(logrus.Fields{"from": pair.from, "to": pair.to})
+	if rate, ok := fe.currencyRates.lastKnown(pair); ok {
+		l.Warn("currency service unavailable, using last known rate")
+		return rate, true
+	}
+	l.Warn("currency service unavailable and no known rate, showing base price")
+	return &pb.Money{CurrencyCode: pair.from, Units: 1}, true
+}
+
+type ctxKeyStaleRates struct{}
+
+// withStaleRatesFlag gives ctx a flag that is raised when a price is
+// converted with a fallback rate, so the page can say prices may be off.
+func withStaleRatesFlag(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyStaleRates{}, new(atomic.Bool))
+}
+
+func markStaleRates(ctx context.Context) {
+	if flag, ok := ctx.Value(ctxKeyStaleRates{}).(*atomic.Bool); ok {
+		flag.Store(true)
+	}
+}
+
+// staleRates reports whether any price in this request used a fallback rate.
+func staleRates(ctx context.Context) bool {
+	flag, ok := ctx.Value(ctxKeyStaleRates{}).(*atomic.Bool)
+	return ok && flag.Load()
+}
+
+// convertPrices converts every price to currency using one rate per source
+// currency, so a page of prices costs at most one currency call per currency
+// rather than one per price. The result lines up with prices.
+func (fe *frontendServer) convertPrices(ctx context.Context, prices []*pb.Money, currency string) ([]*pb.Money, error) {
+	out := make([]*pb.Money, len(prices))
+	for i, p := range prices {
+		if avoidNoopCurrencyConversionRPC && p.GetCurrencyCode() == currency {
+			out[i] = p
+			continue
+		}
+		rate, err := fe.currencyRate(ctx, p.GetCurrencyCode(), currency)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get %s to %s rate", p.GetCurrencyCode(), currency)
+		}
+		converted := money.Convert(*p, *rate)
+		out[i] = &converted
+	}
+	return out, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestProductPriceFallsBackWhenCurrencyServiceIsDown(t *testing.T) {
+	defer func(old bool) { staleCurrencyFallback = old }(staleCurrencyFallback)
+
+	viewProduct := func(fe *frontendServer) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(newTestRequest(http.MethodGet, "/product/P1", nil), map[string]string{"id": "P1"})
+		req.AddCookie(&http.Cookie{Name: cookieCurrency, Value: "EUR"})
+		rr := httptest.NewRecorder()
+		fe.productHandler(rr, req)
+		return rr
+	}
+	tests := []struct {
+		name       string
+		fallback   bool
+		cachedRate bool
+		wantCode   int
+		wantPrice  string
+	}{
+		{"stale rate", true, true, http.StatusOK, "€2.70"},
+		{"no known rate shows base price", true, false, http.StatusOK, "$3.00"},
+		{"fallback off", false, true, http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			staleCurrencyFallback = tt.fallback
+			fe, backends := newTestFrontend(t)
+			backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta", PriceUsd: usd(3, 0)})
+			backends.currency.rates["EUR"] = 0.9
+			if tt.cachedRate {
+				if _, err := fe.currencyRate(newTestRequest(http.MethodGet, "/", nil).Context(), "USD", "EUR"); err != nil {
+					t.Fatalf("caching the rate: %v", err)
+				}
+			}
+			backends.currency.mu.Lock()
+			backends.currency.convertErr = status.Error(codes.Unavailable, "currency service is down")
+			backends.currency.mu.Unlock()
+
+			rr := viewProduct(fe)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d", rr.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			body := rr.Body.String()
+			if !strings.Contains(body, tt.wantPrice) {
+				t.Errorf("product page does not show the price as %s", tt.wantPrice)
+			}
+			if !strings.Contains(body, "Exchange rates may be out of date") {
+				t.Error("product page does not say the rates may be stale")
+			}
+		})
+	}
+}
+
+func TestProductPriceNotMarkedStaleWhenServiceIsUp(t *testing.T) {
+	defer func(old bool) { staleCurrencyFallback = old }(staleCurrencyFallback)
+	staleCurrencyFallback = true
+
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta", PriceUsd: usd(3, 0)})
+	req := mux.SetURLVars(newTestRequest(http.MethodGet, "/product/P1", nil), map[string]string{"id": "P1"})
+	rr := httptest.NewRecorder()
+	fe.productHandler(rr, req)
+
+	if strings.Contains(rr.Body.String(), "Exchange rates may be out of date") {
+		t.Error("product page says rates may be stale although the currency service is up")
+	}
+}
+
+func TestFallbackRateLogsWithRequestLogger(t *testing.T) {
+	defer func(old bool) { staleCurrencyFallback = old }(staleCurrencyFallback)
+	staleCurrencyFallback = true
+
+	fe, _ := newTestFrontend(t)
+	logger, hook := test.NewNullLogger()
+	ctx := context.WithValue(context.Background(), ctxKeyLog{}, logrus.FieldLogger(logger.WithField("session", testSessionID)))
+	if _, ok := fe.fallbackRate(ctx, currencyPair{"USD", "EUR"}, status.Error(codes.Unavailable, "down")); !ok {
+		t.Fatal("got no fallback rate")
+	}
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("fallback was not logged through the request's logger")
+	}
+	if got := entry.Data["session"]; got != testSessionID {
+		t.Errorf("got session %v in the log entry, want %q", got, testSessionID)
+	}
+}
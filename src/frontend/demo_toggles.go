@@ -0,0 +1,58 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// demoToggles are per-request switches, set with query parameters such as
+// ?no_ads=1&no_recs=1, that presenters use to tidy a page for a demo or a
+// screenshot. They are only honored when demoTogglesEnabled is set.
+type demoToggles struct {
+	noAds  bool
+	noRecs bool
+}
+
+type ctxKeyDemoToggles struct{}
+
+// withDemoToggles stores the toggles requested by r's query in ctx.
+func withDemoToggles(ctx context.Context, r *http.Request) context.Context {
+	if !demoTogglesEnabled {
+		return ctx
+	}
+	q := r.URL.Query()
+	noAds, _ := strconv.ParseBool(q.Get("no_ads"))
+	noRecs, _ := strconv.ParseBool(q.Get("no_recs"))
+	if !noAds && !noRecs {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKeyDemoToggles{}, demoToggles{noAds: noAds, noRecs: noRecs})
+}
+
+// adsDisabled reports whether the request asked for a page without ads.
+func adsDisabled(ctx context.Context) bool {
+	t, _ := ctx.Value(ctxKeyDemoToggles{}).(demoToggles)
+	return t.noAds
+}
+
+// recommendationsDisabled reports whether the request asked for a page
+// without recommendations.
+func recommendationsDisabled(ctx context.Context) bool {
+	t, _ := ctx.Value(ctxKeyDemoToggles{}).(demoToggles)
+	return t.noRecs
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestProductHandlerDemoToggles(t *testing.T) {
+	defer func(old bool) { demoTogglesEnabled = old }(demoTogglesEnabled)
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		query    string
+		wantAds  bool
+		wantRecs bool
+	}{
+		{"no params", true, "", true, true},
+		{"no ads", true, "?no_ads=1", false, true},
+		{"no recommendations", true, "?no_recs=1", true, false},
+		{"both", true, "?no_ads=1&no_recs=1", false, false},
+		{"params ignored when disabled", false, "?no_ads=1&no_recs=1", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			demoTogglesEnabled = tt.enabled
+			fe, backends := newTestFrontend(t)
+			backends.catalog.setProducts(
+				&pb.Product{Id: "P1", Name: "Pasta", PriceUsd: usd(3, 0), Categories: []string{"pasta"}},
+				&pb.Product{Id: "P2", Name: "Pesto", PriceUsd: usd(4, 0)},
+			)
+			backends.recommendation.productIDs = []string{"P2"}
+			backends.ad.ads = []*pb.Ad{{RedirectUrl: "/product/P1", Text: "Fresh pasta daily"}}
+
+			req := mux.SetURLVars(newTestRequest(http.MethodGet, "/product/P1"+tt.query, nil), map[string]string{"id": "P1"})
+			rr := httptest.NewRecorder()
+			fe.productHandler(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			body := rr.Body.String()
+			if got := strings.Contains(body, "Fresh pasta daily"); got != tt.wantAds {
+				t.Errorf("showing ad = %v, want %v", got, tt.wantAds)
+			}
+			if got := len(backends.ad.requests) > 0; got != tt.wantAds {
+				t.Errorf("requested ads = %v, want %v", got, tt.wantAds)
+			}
+			if got := strings.Contains(body, `class="recommendations"`); got != tt.wantRecs {
+				t.Errorf("showing recommendations = %v, want %v", got, tt.wantRecs)
+			}
+		})
+	}
+}
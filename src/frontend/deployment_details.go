@@ -3,13 +3,33 @@ package main
 import (
 	"net/http"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/compute/metadata"
 	"github.com/sirupsen/logrus"
 )
 
-var deploymentDetailsMap map[string]string
+// DeploymentDetails describes where this frontend instance runs. It is shown
+// in the page footer. Fields that couldn't be determined are empty.
+type DeploymentDetails struct {
+	Version  string `json:"version,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Cluster  string `json:"cluster,omitempty"`
+	Zone     string `json:"zone,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+// deploymentMetadata is the part of the GCP metadata server client that
+// DeploymentDetails are read from.
+type deploymentMetadata interface {
+	InstanceAttributeValue(attr string) (string, error)
+	Zone() (string, error)
+}
+
+// deploymentDetails is nil until loadDeploymentDetails has finished.
+var deploymentDetails atomic.Pointer[DeploymentDetails]
 var log *logrus.Logger
 
 func init() {
@@ -34,31 +54,47 @@ func initializeLogger() {
 }
 
 func loadDeploymentDetails() {
-	deploymentDetailsMap = make(map[string]string)
-	var metaServerClient = metadata.NewClient(&http.Client{})
+	d := newDeploymentDetails(os.Getenv, metadata.NewClient(&http.Client{}))
+	deploymentDetails.Store(&d)
 
-	podHostname, err := os.Hostname()
-	if err != nil {
-		log.Error("Failed to fetch the hostname for the Pod", err)
-	}
+	log.WithFields(logrus.Fields{
+		"version":  d.Version,
+		"cluster":  d.Cluster,
+		"zone":     d.Zone,
+		"region":   d.Region,
+		"hostname": d.Hostname,
+	}).Debug("Loaded deployment details")
+}
 
-	podCluster, err := metaServerClient.InstanceAttributeValue("cluster-name")
-	if err != nil {
-		log.Error("Failed to fetch the name of the cluster in which the pod is running", err)
+// newDeploymentDetails reads the deployment details from the VERSION,
+// CLUSTER_NAME, ZONE and REGION environment variables, asking the metadata
+// server for the cluster and zone when they aren't set. The region defaults
+// to the zone's region.
+func newDeploymentDetails(getenv func(string) string, md deploymentMetadata) DeploymentDetails {
+	d := DeploymentDetails{
+		Version: getenv("VERSION"),
+		Cluster: getenv("CLUSTER_NAME"),
+		Zone:    getenv("ZONE"),
+		Region:  getenv("REGION"),
 	}
 
-	podZone, err := metaServerClient.Zone()
-	if err != nil {
-		log.Error("Failed to fetch the Zone of the node where the pod is scheduled", err)
+	var err error
+	if d.Hostname, err = os.Hostname(); err != nil {
+		log.Error("Failed to fetch the hostname for the Pod", err)
 	}
-
-	deploymentDetailsMap["HOSTNAME"] = podHostname
-	deploymentDetailsMap["CLUSTERNAME"] = podCluster
-	deploymentDetailsMap["ZONE"] = podZone
-
-	log.WithFields(logrus.Fields{
-		"cluster":  podCluster,
-		"zone":     podZone,
-		"hostname": podHostname,
-	}).Debug("Loaded deployment details")
+	if d.Cluster == "" {
+		if d.Cluster, err = md.InstanceAttributeValue("cluster-name"); err != nil {
+			log.Error("Failed to fetch the name of the cluster in which the pod is running", err)
+		}
+	}
+	if d.Zone == "" {
+		if d.Zone, err = md.Zone(); err != nil {
+			log.Error("Failed to fetch the Zone of the node where the pod is scheduled", err)
+		}
+	}
+	// Zones are named after their region: us-central1-a is in us-central1.
+	if i := strings.LastIndex(d.Zone, "-"); d.Region == "" && i > 0 {
+		d.Region = d.Zone[:i]
+	}
+	return d
 }
@@ -0,0 +1,67 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeMetadata struct {
+	cluster, zone string
+	err           error
+}
+
+func (m fakeMetadata) InstanceAttributeValue(string) (string, error) { return m.cluster, m.err }
+func (m fakeMetadata) Zone() (string, error)                         { return m.zone, m.err }
+
+func TestNewDeploymentDetails(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		md   fakeMetadata
+		want DeploymentDetails
+	}{
+		{
+			name: "from metadata",
+			env:  map[string]string{"VERSION": "v1.2.3"},
+			md:   fakeMetadata{cluster: "online-boutique", zone: "us-central1-a"},
+			want: DeploymentDetails{Version: "v1.2.3", Cluster: "online-boutique", Zone: "us-central1-a", Region: "us-central1"},
+		},
+		{
+			name: "env overrides metadata",
+			env:  map[string]string{"CLUSTER_NAME": "local", "ZONE": "eu-west-1b", "REGION": "eu-west-1"},
+			md:   fakeMetadata{cluster: "online-boutique", zone: "us-central1-a"},
+			want: DeploymentDetails{Cluster: "local", Zone: "eu-west-1b", Region: "eu-west-1"},
+		},
+		{
+			name: "off GCP",
+			md:   fakeMetadata{err: errors.New("not on GCE")},
+			want: DeploymentDetails{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newDeploymentDetails(func(k string) string { return tt.env[k] }, tt.md)
+			if got.Hostname == "" {
+				t.Error("got no hostname")
+			}
+			got.Hostname = ""
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// experiment splits sessions between variants in proportion to their weights.
+type experiment struct {
+	name     string
+	variants []string
+	weights  []int
+	total    int
+}
+
+// variant returns the variant sessionID is bucketed into. The same session
+// always gets the same variant, and different experiments bucket
+// independently.
+func (e experiment) variant(sessionID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(e.name))
+	h.Write([]byte{0})
+	h.Write([]byte(sessionID))
+	bucket := int(h.Sum32() % uint32(e.total))
+	for i, w := range e.weights {
+		if bucket < w {
+			return e.variants[i]
+		}
+		bucket -= w
+	}
+	return e.variants[len(e.variants)-1]
+}
+
+// experimentAssignments returns the variant of every configured experiment
+// for sessionID, keyed by experiment name.
+func experimentAssignments(sessionID string) map[string]string {
+	if len(experiments) == 0 {
+		return nil
+	}
+	assigned := make(map[string]string, len(experiments))
+	for _, e := range experiments {
+		assigned[e.name] = e.variant(sessionID)
+	}
+	return assigned
+}
+
+// parseExperiments parses EXPERIMENTS, a semicolon-separated list of
+// experiments, each with comma-separated variant:weight pairs:
+// "suggested_recipes=show:50,hide:50;banner=blue:1,green:3".
+func parseExperiments(raw string) ([]experiment, error) {
+	var exps []experiment
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(raw, ";") {
+		name, spec, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("experiment %q must look like name=variant:weight,...", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("experiment %q is listed twice", name)
+		}
+		seen[name] = true
+
+		e := experiment{name: name}
+		for _, v := range strings.Split(spec, ",") {
+			variant, weight, ok := strings.Cut(v, ":")
+			variant = strings.TrimSpace(variant)
+			w, err := strconv.Atoi(strings.TrimSpace(weight))
+			if !ok || variant == "" || err != nil || w < 0 {
+				return nil, fmt.Errorf("experiment %q: invalid variant %q", name, v)
+			}
+			e.variants = append(e.variants, variant)
+			e.weights = append(e.weights, w)
+			e.total += w
+		}
+		if e.total == 0 {
+			return nil, fmt.Errorf("experiment %q has no weight", name)
+		}
+		exps = append(exps, e)
+	}
+	return exps, nil
+}
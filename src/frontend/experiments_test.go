@@ -0,0 +1,91 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestExperimentBucketingIsStable(t *testing.T) {
+	exps, err := parseExperiments("suggested_recipes=show:1,hide:1")
+	if err != nil {
+		t.Fatalf("parseExperiments: %v", err)
+	}
+	e := exps[0]
+	for i := 0; i < 100; i++ {
+		sid := fmt.Sprintf("session-%d", i)
+		first := e.variant(sid)
+		for j := 0; j < 5; j++ {
+			if got := e.variant(sid); got != first {
+				t.Fatalf("session %s moved from %q to %q", sid, first, got)
+			}
+		}
+	}
+}
+
+func TestExperimentSplitProportions(t *testing.T) {
+	exps, err := parseExperiments("banner=blue:1,green:3,red:0")
+	if err != nil {
+		t.Fatalf("parseExperiments: %v", err)
+	}
+	const sessions = 20000
+	counts := map[string]int{}
+	for i := 0; i < sessions; i++ {
+		counts[exps[0].variant(fmt.Sprintf("session-%d", i))]++
+	}
+
+	want := map[string]float64{"blue": 0.25, "green": 0.75, "red": 0}
+	for variant, share := range want {
+		got := float64(counts[variant]) / sessions
+		if math.Abs(got-share) > 0.02 {
+			t.Errorf("variant %q got %.3f of sessions, want %.2f", variant, got, share)
+		}
+	}
+}
+
+func TestInjectCommonTemplateDataExposesExperiments(t *testing.T) {
+	defer func(old []experiment) { experiments = old }(experiments)
+	var err error
+	if experiments, err = parseExperiments("suggested_recipes=show:1,hide:1;layout=grid:1"); err != nil {
+		t.Fatalf("parseExperiments: %v", err)
+	}
+
+	data := injectCommonTemplateData(newTestRequest(http.MethodGet, "/", nil), nil)
+	assigned, _ := data["experiments"].(map[string]string)
+	if v := assigned["suggested_recipes"]; v != experiments[0].variant(testSessionID) {
+		t.Errorf("got suggested_recipes variant %q, want the session's bucket", v)
+	}
+	if v := assigned["layout"]; v != "grid" {
+		t.Errorf("got layout variant %q, want grid", v)
+	}
+}
+
+func TestParseExperimentsRejectsInvalidSpecs(t *testing.T) {
+	for _, raw := range []string{
+		"noequals",
+		"=a:1",
+		"x=a",
+		"x=a:-1",
+		"x=a:0,b:0",
+		"x=a:1;x=b:1",
+	} {
+		if _, err := parseExperiments(raw); err == nil {
+			t.Errorf("parseExperiments(%q) succeeded, want an error", raw)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// fakeBackends are in-process stand-ins for every gRPC service the frontend
+// talks to. They are served from a single bufconn listener, and every
+// *grpc.ClientConn on the returned frontendServer points at it.
+type fakeBackends struct {
+	catalog        *fakeCatalog
+	currency       *fakeCurrency
+	cart           *fakeCart
+	recommendation *fakeRecommendation
+	shipping       *fakeShipping
+	checkout       *fakeCheckout
+	ad             *fakeAd
+	recipe         *fakeRecipe
+}
+
+func newTestFrontend(t *testing.T) (*frontendServer, *fakeBackends) {
+	t.Helper()
+	b := &fakeBackends{
+		catalog:        &fakeCatalog{getCalls: map[string]int{}},
+		currency:       &fakeCurrency{codes: []string{"USD", "EUR", "CAD", "JPY", "GBP", "TRY"}, rates: map[string]float64{}},
+		cart:           &fakeCart{carts: map[string][]*pb.CartItem{}},
+		recommendation: &fakeRecommendation{},
+		shipping:       &fakeShipping{cost: &pb.Money{CurrencyCode: "USD", Units: 8, Nanos: 990000000}},
+		checkout:       &fakeCheckout{},
+		ad:             &fakeAd{ads: []*pb.Ad{{RedirectUrl: "/product/1", Text: "test ad"}}},
+		recipe:         &fakeRecipe{},
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterProductCatalogServiceServer(srv, b.catalog)
+	pb.RegisterCurrencyServiceServer(srv, b.currency)
+	pb.RegisterCartServiceServer(srv, b.cart)
+	pb.RegisterRecommendationServiceServer(srv, b.recommendation)
+	pb.RegisterShippingServiceServer(srv, b.shipping)
+	pb.RegisterCheckoutServiceServer(srv, b.checkout)
+	pb.RegisterAdServiceServer(srv, b.ad)
+	pb.RegisterRecipeServiceServer(srv, b.recipe)
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial fake backends: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+	})
+
+	fe := &frontendServer{
+		productCatalogSvcConn: conn,
+		currencySvcConn:       conn,
+		cartSvcConn:           conn,
+		recommendationSvcConn: conn,
+		checkoutSvcConn:       conn,
+		shippingSvcConn:       conn,
+		adSvcConn:             conn,
+		recipeSvcConn:         conn,
+	}
+	return fe, b
+}
+
+func usd(units int64, nanos int32) *pb.Money {
+	return &pb.Money{CurrencyCode: "USD", Units: units, Nanos: nanos}
+}
+
+type fakeCatalog struct {
+	pb.UnimplementedProductCatalogServiceServer
+
+	mu        sync.Mutex
+	products  []*pb.Product
+	listErr   error
+	listCalls int
+	getCalls  map[string]int
+}
+
+func (f *fakeCatalog) setProducts(products ...*pb.Product) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.products = products
+}
+
+func (f *fakeCatalog) setListErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listErr = err
+}
+
+func (f *fakeCatalog) ListProducts(context.Context, *pb.Empty) (*pb.ListProductsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listCalls++
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return &pb.ListProductsResponse{Products: f.products}, nil
+}
+
+func (f *fakeCatalog) GetProduct(_ context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getCalls[req.GetId()]++
+	for _, p := range f.products {
+		if p.GetId() == req.GetId() {
+			return p, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no product with ID %s", req.GetId())
+}
+
+func (f *fakeCatalog) getCallCount(id string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.getCalls[id]
+}
+
+// fakeCurrency converts using rates[to] (1.0 when unset).
+type fakeCurrency struct {
+	pb.UnimplementedCurrencyServiceServer
+
+	mu           sync.Mutex
+	codes        []string
+	rates        map[string]float64
+	convertErr   error
+	convertCalls int
+}
+
+func (f *fakeCurrency) GetSupportedCurrencies(context.Context, *pb.Empty) (*pb.GetSupportedCurrenciesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &pb.GetSupportedCurrenciesResponse{CurrencyCodes: f.codes}, nil
+}
+
+func (f *fakeCurrency) Convert(_ context.Context, req *pb.CurrencyConversionRequest) (*pb.Money, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.convertCalls++
+	if f.convertErr != nil {
+		return nil, f.convertErr
+	}
+	rate, ok := f.rates[req.GetToCode()]
+	if !ok {
+		rate = 1
+	}
+	v := (float64(req.GetFrom().GetUnits()) + float64(req.GetFrom().GetNanos())/1e9) * rate
+	units, frac := math.Modf(v)
+	return &pb.Money{
+		CurrencyCode: req.GetToCode(),
+		Units:        int64(units),
+		Nanos:        int32(math.Round(frac * 1e9)),
+	}, nil
+}
+
+func (f *fakeCurrency) convertCallCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.convertCalls
+}
+
+type fakeCart struct {
+	pb.UnimplementedCartServiceServer
+
+	mu       sync.Mutex
+	carts    map[string][]*pb.CartItem
+	getErr   error
+	getCalls int
+	// onGet, if set, runs at the start of each GetCart, without mu held.
+	onGet func(userID string)
+	// onAdd, if set, runs at the start of each AddItem, without mu held. An
+	// error fails the call.
+	onAdd func(req *pb.AddItemRequest) error
+	// onEmpty, if set, runs at the start of each EmptyCart, without mu held.
+	onEmpty func(userID string)
+}
+
+func (f *fakeCart) setCart(userID string, items ...*pb.CartItem) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.carts[userID] = items
+}
+
+func (f *fakeCart) AddItem(_ context.Context, req *pb.AddItemRequest) (*pb.Empty, error) {
+	if f.onAdd != nil {
+		if err := f.onAdd(req); err != nil {
+			return nil, err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, item := range f.carts[req.GetUserId()] {
+		if item.GetProductId() == req.GetItem().GetProductId() {
+			item.Quantity += req.GetItem().GetQuantity()
+			return &pb.Empty{}, nil
+		}
+	}
+	f.carts[req.GetUserId()] = append(f.carts[req.GetUserId()], &pb.CartItem{
+		ProductId: req.GetItem().GetProductId(),
+		Quantity:  req.GetItem().GetQuantity(),
+	})
+	return &pb.Empty{}, nil
+}
+
+func (f *fakeCart) GetCart(_ context.Context, req *pb.GetCartRequest) (*pb.Cart, error) {
+	if f.onGet != nil {
+		f.onGet(req.GetUserId())
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getCalls++
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	var items []*pb.CartItem
+	for _, item := range f.carts[req.GetUserId()] {
+		items = append(items, &pb.CartItem{ProductId: item.GetProductId(), Quantity: item.GetQuantity()})
+	}
+	return &pb.Cart{UserId: req.GetUserId(), Items: items}, nil
+}
+
+func (f *fakeCart) EmptyCart(_ context.Context, req *pb.EmptyCartRequest) (*pb.Empty, error) {
+	if f.onEmpty != nil {
+		f.onEmpty(req.GetUserId())
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.carts, req.GetUserId())
+	return &pb.Empty{}, nil
+}
+
+type fakeRecommendation struct {
+	pb.UnimplementedRecommendationServiceServer
+
+	mu         sync.Mutex
+	productIDs []string
+	err        error
+}
+
+func (f *fakeRecommendation) ListRecommendations(context.Context, *pb.ListRecommendationsRequest) (*pb.ListRecommendationsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &pb.ListRecommendationsResponse{ProductIds: f.productIDs}, nil
+}
+
+type fakeShipping struct {
+	pb.UnimplementedShippingServiceServer
+
+	mu   sync.Mutex
+	cost *pb.Money
+	err  error
+}
+
+func (f *fakeShipping) GetQuote(context.Context, *pb.GetQuoteRequest) (*pb.GetQuoteResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &pb.GetQuoteResponse{CostUsd: f.cost}, nil
+}
+
+type fakeCheckout struct {
+	pb.UnimplementedCheckoutServiceServer
+
+	mu       sync.Mutex
+	calls    int
+	requests []*pb.PlaceOrderRequest
+	order    *pb.OrderResult
+	err      error
+}
+
+func (f *fakeCheckout) PlaceOrder(_ context.Context, req *pb.PlaceOrderRequest) (*pb.PlaceOrderResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.requests = append(f.requests, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	order := f.order
+	if order == nil {
+		order = &pb.OrderResult{
+			OrderId:            "order-1",
+			ShippingTrackingId: "tracking-1",
+			ShippingCost:       &pb.Money{CurrencyCode: req.GetUserCurrency(), Units: 5},
+			ShippingAddress:    req.GetAddress(),
+		}
+	}
+	return &pb.PlaceOrderResponse{Order: order}, nil
+}
+
+func (f *fakeCheckout) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+type fakeAd struct {
+	pb.UnimplementedAdServiceServer
+
+	mu       sync.Mutex
+	ads      []*pb.Ad
+	byKey    map[string][]*pb.Ad // if set, ads are served by context key
+	err      error
+	requests []*pb.AdRequest
+}
+
+func (f *fakeAd) GetAds(_ context.Context, req *pb.AdRequest) (*pb.AdResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.byKey != nil {
+		var ads []*pb.Ad
+		for _, k := range req.GetContextKeys() {
+			ads = append(ads, f.byKey[k]...)
+		}
+		return &pb.AdResponse{Ads: ads}, nil
+	}
+	return &pb.AdResponse{Ads: f.ads}, nil
+}
+
+// fakeRecipe serves recipes from a fixed list. The optional hooks override
+// the RPCs that tests need to script.
+type fakeRecipe struct {
+	pb.UnimplementedRecipeServiceServer
+
+	mu        sync.Mutex
+	recipes   []*pb.Recipe
+	listCalls int
+
+	suggest      func(context.Context, *pb.SuggestedRecipesRequest) (*pb.ListRecipesResponse, error)
+	suggestCalls []*pb.SuggestedRecipesRequest
+	process      func(context.Context, *pb.ProcessRecipeRequestMessage) (*pb.ProcessRecipeResponse, error)
+	processCalls []*pb.ProcessRecipeRequestMessage
+}
+
+func (f *fakeRecipe) ListRecipes(context.Context, *pb.ListRecipesRequest) (*pb.ListRecipesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listCalls++
+	return &pb.ListRecipesResponse{Recipes: f.recipes}, nil
+}
+
+func (f *fakeRecipe) GetRecipe(_ context.Context, req *pb.GetRecipeRequest) (*pb.GetRecipeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.recipes {
+		if r.GetRecipeId() == req.GetRecipeId() {
+			return &pb.GetRecipeResponse{Recipe: r}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no recipe with ID %s", req.GetRecipeId())
+}
+
+func (f *fakeRecipe) GetSuggestedRecipes(ctx context.Context, req *pb.SuggestedRecipesRequest) (*pb.ListRecipesResponse, error) {
+	f.mu.Lock()
+	f.suggestCalls = append(f.suggestCalls, req)
+	suggest := f.suggest
+	f.mu.Unlock()
+	if suggest != nil {
+		return suggest(ctx, req)
+	}
+	return &pb.ListRecipesResponse{}, nil
+}
+
+func (f *fakeRecipe) processRequests() []*pb.ProcessRecipeRequestMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*pb.ProcessRecipeRequestMessage(nil), f.processCalls...)
+}
+
+func (f *fakeRecipe) ProcessRecipeRequest(ctx context.Context, req *pb.ProcessRecipeRequestMessage) (*pb.ProcessRecipeResponse, error) {
+	f.mu.Lock()
+	f.processCalls = append(f.processCalls, req)
+	process := f.process
+	f.mu.Unlock()
+	if process != nil {
+		return process(ctx, req)
+	}
+	return &pb.ProcessRecipeResponse{Success: true}, nil
+}
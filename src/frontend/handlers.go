@@ -15,7 +15,10 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -23,7 +26,9 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +36,9 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
 	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/money"
@@ -46,14 +54,19 @@ var (
 	frontendMessage  = strings.TrimSpace(os.Getenv("FRONTEND_MESSAGE"))
 	isCymbalBrand    = "true" == strings.ToLower(os.Getenv("CYMBAL_BRANDING"))
 	assistantEnabled = "true" == strings.ToLower(os.Getenv("ENABLE_ASSISTANT"))
-	templates        = template.Must(template.New("").
-				Funcs(template.FuncMap{
-			"renderMoney":        renderMoney,
-			"renderCurrencyLogo": renderCurrencyLogo,
-		}).ParseGlob("templates/*.html"))
-	plat platformDetails
+	templateFuncs    = template.FuncMap{
+		"renderMoney":        renderMoney,
+		"renderCurrencyLogo": renderCurrencyLogo,
+		"recipeDifficulty":   recipeDifficulty,
+	}
+	templates = template.Must(template.New("").Funcs(templateFuncs).ParseGlob("templates/*.html"))
+	plat      platformDetails
 )
 
+// errAssistantDisabled is served by the assistant endpoints when
+// ENABLE_ASSISTANT is off, matching the UI, which hides the assistant.
+var errAssistantDisabled = errors.New("the shopping assistant is disabled")
+
 var validEnvs = []string{"local", "gcp", "azure", "aws", "onprem", "alibaba"}
 
 func (fe *frontendServer) homeHandler(w http.ResponseWriter, r *http.Request) {
@@ -69,6 +82,13 @@ func (fe *frontendServer) homeHandler(w http.ResponseWriter, r *http.Request) {
 		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve products"), http.StatusInternalServerError)
 		return
 	}
+	if len(products) == 0 {
+		log.Warn("product catalog is empty")
+	}
+	// Trim before converting prices so hidden products cost no currency RPCs.
+	if homeProductLimit > 0 && len(products) > homeProductLimit {
+		products = products[:homeProductLimit]
+	}
 	cart, err := fe.getCart(r.Context(), sessionID(r))
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
@@ -76,47 +96,71 @@ func (fe *frontendServer) homeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type productView struct {
-		Item  *pb.Product
-		Price *pb.Money
+		Item     *pb.Product
+		Price    *pb.Money
+		LowStock bool
+	}
+	usdPrices := make([]*pb.Money, len(products))
+	for i, p := range products {
+		usdPrices[i] = p.GetPriceUsd()
+	}
+	prices, err := fe.convertPrices(r.Context(), usdPrices, currentCurrency(r))
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to do currency conversion for products"), http.StatusInternalServerError)
+		return
 	}
 	ps := make([]productView, len(products))
 	for i, p := range products {
-		price, err := fe.convertCurrency(r.Context(), p.GetPriceUsd(), currentCurrency(r))
-		if err != nil {
-			renderHTTPError(log, r, w, errors.Wrapf(err, "failed to do currency conversion for product %s", p.GetId()), http.StatusInternalServerError)
-			return
-		}
-		ps[i] = productView{p, price}
+		ps[i] = productView{p, prices[i], isLowStock(p)}
+	}
+
+	if err := executeTemplate(w, "home", injectCommonTemplateData(r, map[string]interface{}{
+		"show_currency": true,
+		"currencies":    currencies,
+		"products":      ps,
+		"empty_catalog": len(ps) == 0,
+		"cart_size":     cartSize(cart),
+		"banner_color":  os.Getenv("BANNER_COLOR"), // illustrates canary deployments
+		"ad_slot":       fe.adSlotChooser(r.Context(), nil, log),
+	})); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
 	}
+}
 
-	// Set ENV_PLATFORM (default to local if not set; use env var if set; otherwise detect GCP, which overrides env)_
+// lookupMetadataServer resolves the GCP metadata server. Tests replace it.
+var lookupMetadataServer = func() ([]string, error) {
+	return net.LookupHost("metadata.google.internal.")
+}
+
+// detectPlatform works out which platform the frontend runs on. It looks up
+// the GCP metadata server, so it runs once at startup and plat is read-only
+// after that.
+func detectPlatform(log logrus.FieldLogger) platformDetails {
+	// Set ENV_PLATFORM (default to fallbackPlatform if not set; use env var if set; otherwise detect GCP, which overrides env)_
 	var env = os.Getenv("ENV_PLATFORM")
 	// Only override from env variable if set + valid env
-	if env == "" || stringinSlice(validEnvs, env) == false {
-		fmt.Println("env platform is either empty or invalid")
-		env = "local"
+	if env == "" {
+		log.WithField("fallback", fallbackPlatform).Debug("ENV_PLATFORM is not set, using the fallback platform")
+		env = fallbackPlatform
+	} else if !stringinSlice(validEnvs, env) {
+		log.WithFields(logrus.Fields{
+			"env_platform": env,
+			"valid":        validEnvs,
+			"fallback":     fallbackPlatform,
+		}).Warn("invalid ENV_PLATFORM, using the fallback platform")
+		env = fallbackPlatform
 	}
 	// Autodetect GCP
-	addrs, err := net.LookupHost("metadata.google.internal.")
+	addrs, err := lookupMetadataServer()
 	if err == nil && len(addrs) >= 0 {
 		log.Debugf("Detected Google metadata server: %v, setting ENV_PLATFORM to GCP.", addrs)
 		env = "gcp"
 	}
 
 	log.Debugf("ENV_PLATFORM is: %s", env)
-	plat = platformDetails{}
+	var plat platformDetails
 	plat.setPlatformDetails(strings.ToLower(env))
-
-	if err := templates.ExecuteTemplate(w, "home", injectCommonTemplateData(r, map[string]interface{}{
-		"show_currency": true,
-		"currencies":    currencies,
-		"products":      ps,
-		"cart_size":     cartSize(cart),
-		"banner_color":  os.Getenv("BANNER_COLOR"), // illustrates canary deployments
-		"ad":            fe.chooseAd(r.Context(), []string{}, log),
-	})); err != nil {
-		log.Error(err)
-	}
+	return plat
 }
 
 func (plat *platformDetails) setPlatformDetails(env string) {
@@ -181,9 +225,10 @@ func (fe *frontendServer) productHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	product := struct {
-		Item  *pb.Product
-		Price *pb.Money
-	}{p, price}
+		Item     *pb.Product
+		Price    *pb.Money
+		LowStock bool
+	}{p, price, isLowStock(p)}
 
 	// Fetch packaging info (weight/dimensions) of the product
 	// The packaging service is an optional microservice you can run as part of a Google Cloud demo.
@@ -195,8 +240,8 @@ func (fe *frontendServer) productHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	if err := templates.ExecuteTemplate(w, "product", injectCommonTemplateData(r, map[string]interface{}{
-		"ad":              fe.chooseAd(r.Context(), p.Categories, log),
+	if err := executeTemplate(w, "product", injectCommonTemplateData(r, map[string]interface{}{
+		"ad_slot":         fe.adSlotChooser(r.Context(), p.Categories, log),
 		"show_currency":   true,
 		"currencies":      currencies,
 		"product":         product,
@@ -204,17 +249,42 @@ func (fe *frontendServer) productHandler(w http.ResponseWriter, r *http.Request)
 		"cart_size":       cartSize(cart),
 		"packagingInfo":   packagingInfo,
 	})); err != nil {
-		log.Println(err)
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
+	}
+}
+
+// defaultAddToCartQuantity is added when a request names no quantity.
+const defaultAddToCartQuantity = 1
+
+// parseAddToCartQuantity parses the quantity form value. A missing quantity
+// means defaultAddToCartQuantity. Negative numbers parse as zero, leaving the
+// validator to reject them with the same message as zero.
+func parseAddToCartQuantity(raw string) (uint64, error) {
+	if raw == "" {
+		return defaultAddToCartQuantity, nil
+	}
+	quantity, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		if n, ierr := strconv.ParseInt(raw, 10, 64); ierr == nil && n < 0 {
+			return 0, nil
+		}
+		return 0, err
 	}
+	return quantity, nil
 }
 
 func (fe *frontendServer) addToCartHandler(w http.ResponseWriter, r *http.Request) {
 	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
-	quantity, _ := strconv.ParseUint(r.FormValue("quantity"), 10, 32)
+	quantity, err := parseAddToCartQuantity(r.FormValue("quantity"))
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "invalid quantity"), http.StatusUnprocessableEntity)
+		return
+	}
 	productID := r.FormValue("product_id")
 	payload := validator.AddToCartPayload{
-		Quantity:  quantity,
-		ProductID: productID,
+		Quantity:    quantity,
+		ProductID:   productID,
+		MaxQuantity: uint64(maxAddToCartQuantity),
 	}
 	if err := payload.Validate(); err != nil {
 		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
@@ -228,7 +298,9 @@ func (fe *frontendServer) addToCartHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := fe.insertCart(r.Context(), sessionID(r), p.GetId(), int32(payload.Quantity)); err != nil {
+	if err := fe.mutateCart(r.Context(), sessionID(r), func(ctx context.Context) error {
+		return fe.insertCart(ctx, sessionID(r), p.GetId(), int32(payload.Quantity))
+	}); err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "failed to add to cart"), http.StatusInternalServerError)
 		return
 	}
@@ -240,7 +312,9 @@ func (fe *frontendServer) emptyCartHandler(w http.ResponseWriter, r *http.Reques
 	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
 	log.Debug("emptying cart")
 
-	if err := fe.emptyCart(r.Context(), sessionID(r)); err != nil {
+	if err := fe.mutateCart(r.Context(), sessionID(r), func(ctx context.Context) error {
+		return fe.emptyCart(ctx, sessionID(r))
+	}); err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "failed to empty cart"), http.StatusInternalServerError)
 		return
 	}
@@ -248,6 +322,48 @@ func (fe *frontendServer) emptyCartHandler(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusFound)
 }
 
+func (fe *frontendServer) removeFromCartHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	productID := r.FormValue("product_id")
+	if productID == "" {
+		renderHTTPError(log, r, w, errors.New("product_id is required"), http.StatusUnprocessableEntity)
+		return
+	}
+	log.WithField("product", productID).Debug("removing from cart")
+
+	if err := fe.mutateCart(r.Context(), sessionID(r), func(ctx context.Context) error {
+		return fe.removeFromCart(ctx, sessionID(r), productID)
+	}); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to remove from cart"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("location", baseUrl+"/cart")
+	w.WriteHeader(http.StatusFound)
+}
+
+// priceCartItem looks up a cart item's product and unit price in currency.
+// It returns a nil product if the product is no longer in the catalog.
+func (fe *frontendServer) priceCartItem(ctx context.Context, item *pb.CartItem, currency string) (_ *pb.Product, _ *pb.Money, err error) {
+	ctx, span := startSpan(ctx, "frontend.cartItem",
+		attribute.String("product_id", item.GetProductId()),
+		attribute.Int("quantity", int(item.GetQuantity())))
+	defer func() { endSpan(span, err) }()
+
+	p, err := fe.getProduct(ctx, item.GetProductId())
+	if status.Code(err) == codes.NotFound {
+		span.SetAttributes(attribute.Bool("product.unavailable", true))
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not retrieve product #%s", item.GetProductId())
+	}
+	price, err := fe.convertCurrency(ctx, p.GetPriceUsd(), currency)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not convert currency for product #%s", item.GetProductId())
+	}
+	return p, price, nil
+}
+
 func (fe *frontendServer) viewCartHandler(w http.ResponseWriter, r *http.Request) {
 	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
 	log.Debug("view user cart")
@@ -268,52 +384,65 @@ func (fe *frontendServer) viewCartHandler(w http.ResponseWriter, r *http.Request
 		log.WithField("error", err).Warn("failed to get product recommendations")
 	}
 
-	shippingCost, err := fe.getShippingQuote(r.Context(), cart, currentCurrency(r))
-	if err != nil {
-		renderHTTPError(log, r, w, errors.Wrap(err, "failed to get shipping quote"), http.StatusInternalServerError)
-		return
-	}
-
 	type cartItemView struct {
-		Item     *pb.Product
-		Quantity int32
-		Price    *pb.Money
+		Item        *pb.Product
+		Quantity    int32
+		Price       *pb.Money
+		ProductID   string
+		Unavailable bool // the product is no longer in the catalog
 	}
 	items := make([]cartItemView, len(cart))
+	available := make([]*pb.CartItem, 0, len(cart))
 	totalPrice := pb.Money{CurrencyCode: currentCurrency(r)}
+	itemsCtx, itemsSpan := startSpan(r.Context(), "frontend.cartItems", attribute.Int("cart.item_count", len(cart)))
 	for i, item := range cart {
-		p, err := fe.getProduct(r.Context(), item.GetProductId())
+		p, price, err := fe.priceCartItem(itemsCtx, item, currentCurrency(r))
 		if err != nil {
-			renderHTTPError(log, r, w, errors.Wrapf(err, "could not retrieve product #%s", item.GetProductId()), http.StatusInternalServerError)
+			endSpan(itemsSpan, err)
+			renderHTTPError(log, r, w, err, http.StatusInternalServerError)
 			return
 		}
-		price, err := fe.convertCurrency(r.Context(), p.GetPriceUsd(), currentCurrency(r))
-		if err != nil {
-			renderHTTPError(log, r, w, errors.Wrapf(err, "could not convert currency for product #%s", item.GetProductId()), http.StatusInternalServerError)
-			return
+		if p == nil {
+			// Show the item so it can be removed, but leave it out of the totals.
+			log.WithField("product", item.GetProductId()).Warn("cart item is no longer in the catalog")
+			items[i] = cartItemView{ProductID: item.GetProductId(), Quantity: item.GetQuantity(), Unavailable: true}
+			continue
 		}
 
 		multPrice := money.MultiplySlow(*price, uint32(item.GetQuantity()))
 		items[i] = cartItemView{
-			Item:     p,
-			Quantity: item.GetQuantity(),
-			Price:    &multPrice}
+			Item:      p,
+			Quantity:  item.GetQuantity(),
+			Price:     &multPrice,
+			ProductID: p.GetId()}
 		totalPrice = money.Must(money.Sum(totalPrice, multPrice))
+		available = append(available, item)
+	}
+	itemsSpan.End()
+
+	// The cart is still worth showing without a shipping quote.
+	shippingCost, err := fe.getShippingQuote(r.Context(), available, currentCurrency(r))
+	shippingUnavailable := err != nil
+	if err != nil {
+		log.WithField("error", err).Warn("failed to get shipping quote")
+		shippingCost = &pb.Money{CurrencyCode: currentCurrency(r)}
+	} else {
+		shippingCost = shippingCostOrZero(log, shippingCost, currentCurrency(r))
 	}
+
 	totalPrice = money.Must(money.Sum(totalPrice, *shippingCost))
-	year := time.Now().Year()
-
-	if err := templates.ExecuteTemplate(w, "cart", injectCommonTemplateData(r, map[string]interface{}{
-		"currencies":       currencies,
-		"recommendations":  recommendations,
-		"cart_size":        cartSize(cart),
-		"shipping_cost":    shippingCost,
-		"show_currency":    true,
-		"total_cost":       totalPrice,
-		"items":            items,
-		"expiration_years": []int{year, year + 1, year + 2, year + 3, year + 4},
+	if err := renderPage(w, r, "cart", "cart_summary", injectCommonTemplateData(r, map[string]interface{}{
+		"currencies":           currencies,
+		"recommendations":      recommendations,
+		"cart_size":            cartSize(cart),
+		"shipping_cost":        shippingCost,
+		"shipping_unavailable": shippingUnavailable,
+		"show_currency":        true,
+		"total_cost":           totalPrice,
+		"items":                items,
+		"expiration_years":     expirationYears(time.Now().Year(), expirationYearCount),
 	})); err != nil {
-		log.Println(err)
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
 	}
 }
 
@@ -328,11 +457,16 @@ func (fe *frontendServer) placeOrderHandler(w http.ResponseWriter, r *http.Reque
 		city          = r.FormValue("city")
 		state         = r.FormValue("state")
 		country       = r.FormValue("country")
-		ccNumber      = r.FormValue("credit_card_number")
-		ccMonth, _    = strconv.ParseInt(r.FormValue("credit_card_expiration_month"), 10, 32)
-		ccYear, _     = strconv.ParseInt(r.FormValue("credit_card_expiration_year"), 10, 32)
-		ccCVV, _      = strconv.ParseInt(r.FormValue("credit_card_cvv"), 10, 32)
 	)
+	card, err := validator.ParseCreditCardInput(
+		r.FormValue("credit_card_number"),
+		r.FormValue("credit_card_expiration_month"),
+		r.FormValue("credit_card_expiration_year"),
+		r.FormValue("credit_card_cvv"))
+	if err != nil {
+		renderHTTPError(log, r, w, err, http.StatusUnprocessableEntity)
+		return
+	}
 
 	payload := validator.PlaceOrderPayload{
 		Email:         email,
@@ -341,33 +475,44 @@ func (fe *frontendServer) placeOrderHandler(w http.ResponseWriter, r *http.Reque
 		City:          city,
 		State:         state,
 		Country:       country,
-		CcNumber:      ccNumber,
-		CcMonth:       ccMonth,
-		CcYear:        ccYear,
-		CcCVV:         ccCVV,
+		CcNumber:      card.Number,
+		CcMonth:       int64(card.Month),
+		CcYear:        int64(card.Year),
+		CcCVV:         int64(card.CVV),
 	}
 	if err := payload.Validate(); err != nil {
 		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
 		return
 	}
 
-	order, err := pb.NewCheckoutServiceClient(fe.checkoutSvcConn).
-		PlaceOrder(r.Context(), &pb.PlaceOrderRequest{
-			Email: payload.Email,
-			CreditCard: &pb.CreditCardInfo{
-				CreditCardNumber:          payload.CcNumber,
-				CreditCardExpirationMonth: int32(payload.CcMonth),
-				CreditCardExpirationYear:  int32(payload.CcYear),
-				CreditCardCvv:             int32(payload.CcCVV)},
-			UserId:       sessionID(r),
-			UserCurrency: currentCurrency(r),
-			Address: &pb.Address{
-				StreetAddress: payload.StreetAddress,
-				City:          payload.City,
-				State:         payload.State,
-				ZipCode:       int32(payload.ZipCode),
-				Country:       payload.Country},
-		})
+	if isDryRun(r) {
+		fe.dryRunOrder(w, r, log)
+		return
+	}
+
+	// Checkout empties the cart.
+	var order *pb.PlaceOrderResponse
+	err = fe.mutateCart(r.Context(), sessionID(r), func(ctx context.Context) error {
+		var err error
+		order, err = fe.checkoutService().
+			PlaceOrder(ctx, &pb.PlaceOrderRequest{
+				Email: payload.Email,
+				CreditCard: &pb.CreditCardInfo{
+					CreditCardNumber:          payload.CcNumber,
+					CreditCardExpirationMonth: int32(payload.CcMonth),
+					CreditCardExpirationYear:  int32(payload.CcYear),
+					CreditCardCvv:             int32(payload.CcCVV)},
+				UserId:       sessionID(r),
+				UserCurrency: currentCurrency(r),
+				Address: &pb.Address{
+					StreetAddress: payload.StreetAddress,
+					City:          payload.City,
+					State:         payload.State,
+					ZipCode:       int32(payload.ZipCode),
+					Country:       payload.Country},
+			})
+		return err
+	})
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "failed to complete the order"), http.StatusInternalServerError)
 		return
@@ -382,6 +527,8 @@ func (fe *frontendServer) placeOrderHandler(w http.ResponseWriter, r *http.Reque
 		multPrice := money.MultiplySlow(*v.GetCost(), uint32(v.GetItem().GetQuantity()))
 		totalPaid = money.Must(money.Sum(totalPaid, multPrice))
 	}
+	placedAt := time.Now()
+	fe.orderHistory.add(sessionID(r), newOrderRecord(order.GetOrder(), &totalPaid, placedAt), placedAt)
 
 	currencies, err := fe.getCurrencies(r.Context())
 	if err != nil {
@@ -389,29 +536,116 @@ func (fe *frontendServer) placeOrderHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := templates.ExecuteTemplate(w, "order", injectCommonTemplateData(r, map[string]interface{}{
+	if err := executeTemplate(w, "order", injectCommonTemplateData(r, map[string]interface{}{
 		"show_currency":   false,
 		"currencies":      currencies,
 		"order":           order.GetOrder(),
 		"total_paid":      &totalPaid,
 		"recommendations": recommendations,
 	})); err != nil {
-		log.Println(err)
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
+	}
+}
+
+// isDryRun reports whether a checkout asked to be validated and priced
+// without placing the order, via a dry_run form field or X-Dry-Run header.
+func isDryRun(r *http.Request) bool {
+	for _, v := range []string{r.FormValue("dry_run"), r.Header.Get("X-Dry-Run")} {
+		if dryRun, _ := strconv.ParseBool(v); dryRun {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRunOrder prices the user's cart the way checkout would, including
+// shipping, and returns the summary as JSON instead of placing the order.
+// Products no longer in the catalog are left out of the total and listed as
+// unavailable_products, with a 422 since the order couldn't be placed as is.
+func (fe *frontendServer) dryRunOrder(w http.ResponseWriter, r *http.Request, log logrus.FieldLogger) {
+	currency := currentCurrency(r)
+	cart, err := fe.getCart(r.Context(), sessionID(r))
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
+		return
+	}
+
+	type itemSummary struct {
+		ProductID string    `json:"product_id"`
+		Quantity  int32     `json:"quantity"`
+		Cost      *pb.Money `json:"cost"`
+	}
+	items := []itemSummary{}
+	unavailable := []string{}
+	var available []*pb.CartItem
+	var prices []*pb.Money
+	for _, item := range cart {
+		p, err := fe.getProduct(r.Context(), item.GetProductId())
+		if status.Code(err) == codes.NotFound {
+			unavailable = append(unavailable, item.GetProductId())
+			continue
+		}
+		if err != nil {
+			renderAPIError(log, r, w, errors.Wrapf(err, "could not retrieve product #%s", item.GetProductId()), http.StatusInternalServerError)
+			return
+		}
+		items = append(items, itemSummary{ProductID: item.GetProductId(), Quantity: item.GetQuantity()})
+		available = append(available, item)
+		prices = append(prices, p.GetPriceUsd())
+	}
+	prices, err = fe.convertPrices(r.Context(), prices, currency)
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "could not convert prices"), http.StatusInternalServerError)
+		return
+	}
+	shippingCost, err := fe.getShippingQuote(r.Context(), available, currency)
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "failed to get shipping quote"), http.StatusInternalServerError)
+		return
+	}
+	shippingCost = shippingCostOrZero(log, shippingCost, currency)
+
+	total := pb.Money{CurrencyCode: currency}
+	for i, item := range available {
+		items[i].Cost = prices[i]
+		total = money.Must(money.Sum(total, money.MultiplySlow(*prices[i], uint32(item.GetQuantity()))))
+	}
+	total = money.Must(money.Sum(total, *shippingCost))
+	log.WithFields(logrus.Fields{"items": len(items), "unavailable": len(unavailable)}).Info("dry run order, not placing it")
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(unavailable) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"dry_run":              true,
+		"currency":             currency,
+		"items":                items,
+		"unavailable_products": unavailable,
+		"shipping_cost":        shippingCost,
+		"total":                &total,
+	}); err != nil {
+		log.WithError(err).Error("failed to encode dry run summary")
 	}
 }
 
 func (fe *frontendServer) assistantHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	if !assistantEnabled {
+		renderHTTPError(log, r, w, errAssistantDisabled, http.StatusNotFound)
+		return
+	}
 	currencies, err := fe.getCurrencies(r.Context())
 	if err != nil {
 		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
 		return
 	}
 
-	if err := templates.ExecuteTemplate(w, "assistant", injectCommonTemplateData(r, map[string]interface{}{
+	if err := executeTemplate(w, "assistant", injectCommonTemplateData(r, map[string]interface{}{
 		"show_currency": false,
 		"currencies":    currencies,
 	})); err != nil {
-		log.Println(err)
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
 	}
 }
 
@@ -450,6 +684,10 @@ func (fe *frontendServer) getProductByID(w http.ResponseWriter, r *http.Request)
 
 func (fe *frontendServer) chatBotHandler(w http.ResponseWriter, r *http.Request) {
 	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	if !assistantEnabled {
+		renderAPIError(log, r, w, errAssistantDisabled, http.StatusNotFound)
+		return
+	}
 	type Response struct {
 		Message string `json:"message"`
 	}
@@ -461,32 +699,39 @@ func (fe *frontendServer) chatBotHandler(w http.ResponseWriter, r *http.Request)
 
 	var response LLMResponse
 
-	url := "http://" + fe.shoppingAssistantSvcAddr
-	req, err := http.NewRequest(http.MethodPost, url, r.Body)
+	// Buffered so the request can be sent again if the assistant is flaky.
+	reqBody, err := io.ReadAll(r.Body)
 	if err != nil {
-		renderHTTPError(log, r, w, errors.Wrap(err, "failed to create request"), http.StatusInternalServerError)
+		renderAPIError(log, r, w, errors.Wrap(err, "failed to read request"), http.StatusBadRequest)
 		return
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	res, err := http.DefaultClient.Do(req)
+	res, err := fe.postToAssistant(r.Context(), log, reqBody)
 	if err != nil {
-		renderHTTPError(log, r, w, errors.Wrap(err, "failed to send request"), http.StatusInternalServerError)
+		renderAPIError(log, r, w, errors.Wrap(err, "failed to send request"), http.StatusInternalServerError)
 		return
 	}
+	defer res.Body.Close()
+
+	// Pass upstream back-pressure through so clients know when to retry.
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable {
+		if retryAfter := res.Header.Get("Retry-After"); retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+			renderAPIError(log, r, w, errors.Errorf("shopping assistant unavailable (status %d)", res.StatusCode), res.StatusCode)
+			return
+		}
+	}
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		renderHTTPError(log, r, w, errors.Wrap(err, "failed to read response"), http.StatusInternalServerError)
+		renderAPIError(log, r, w, errors.Wrap(err, "failed to read response"), http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Printf("%+v\n", body)
-	fmt.Printf("%+v\n", res)
+	logAssistantExchange(log, reqBody, res, body)
 
 	err = json.Unmarshal(body, &response)
 	if err != nil {
-		renderHTTPError(log, r, w, errors.Wrap(err, "failed to unmarshal body"), http.StatusInternalServerError)
+		renderAPIError(log, r, w, errors.Wrap(err, "failed to unmarshal body"), http.StatusInternalServerError)
 		return
 	}
 
@@ -496,9 +741,206 @@ func (fe *frontendServer) chatBotHandler(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 
+// logAssistantExchange logs a request to the shopping assistant and its
+// response at assistantLogLevel, with each body cut to assistantLogBodyLimit
+// bytes. Response headers are left out since they may carry credentials set
+// by a proxy in front of the assistant.
+func logAssistantExchange(log logrus.FieldLogger, reqBody []byte, res *http.Response, resBody []byte) {
+	fields := logrus.Fields{
+		"assistant.status":        res.StatusCode,
+		"assistant.request_size":  len(reqBody),
+		"assistant.response_size": len(resBody),
+	}
+	if assistantLogBodyLimit > 0 {
+		fields["assistant.request"] = truncateForLog(reqBody, assistantLogBodyLimit)
+		fields["assistant.response"] = truncateForLog(resBody, assistantLogBodyLimit)
+	}
+	log.WithFields(fields).Log(assistantLogLevel, "shopping assistant responded")
+}
+
+// truncateForLog returns b as a string of at most limit bytes, marking where
+// it was cut. A multi-byte character split by the cut is dropped.
+func truncateForLog(b []byte, limit int) string {
+	if len(b) <= limit {
+		return string(b)
+	}
+	return strings.ToValidUTF8(string(b[:limit]), "") + "…(truncated)"
+}
+
+// postToAssistant sends body to the shopping assistant. Connection errors and
+// 502/503 responses are retried up to assistantRetryAttempts times in all,
+// waiting assistantRetryBackoff and then twice as long each time. A 503's
+// Retry-After is honored, but capped at what is left of the time all the
+// backoffs would have taken together. No retry starts that couldn't finish
+// before ctx's deadline; the last response is returned then, Retry-After
+// and all, for the client to honor.
+func (fe *frontendServer) postToAssistant(ctx context.Context, log logrus.FieldLogger, body []byte) (*http.Response, error) {
+	url := "http://" + fe.shoppingAssistantSvcAddr
+	wait := assistantRetryBackoff
+	budget := assistantRetryBackoff * (1<<(assistantRetryAttempts-1) - 1)
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		res, err := http.DefaultClient.Do(req)
+
+		retry := ctx.Err() == nil && attempt < assistantRetryAttempts
+		switch {
+		case err != nil:
+		case res.StatusCode == http.StatusBadGateway:
+		case res.StatusCode == http.StatusServiceUnavailable:
+		default:
+			retry = false
+		}
+		delay := wait
+		if err == nil && res.StatusCode == http.StatusServiceUnavailable {
+			if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+				delay = max(delay, min(retryAfter, budget))
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			retry = false
+		}
+		if !retry {
+			return res, err
+		}
+
+		l := log.WithField("attempt", attempt)
+		if err != nil {
+			l = l.WithError(err)
+		} else {
+			l = l.WithField("status", res.StatusCode)
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		l.WithField("delay", delay).Warn("shopping assistant request failed, retrying")
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		budget -= delay
+		wait *= 2
+	}
+}
+
+// parseRetryAfter returns how long a Retry-After header value asks to wait.
+// It may be a number of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// recommendationsAPIHandler returns recommended products with prices in the
+// user's currency. Product IDs for context may be passed as a comma-separated
+// product_ids parameter. Recommendations aren't critical, so a backend failure
+// yields an empty list.
+func (fe *frontendServer) recommendationsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+
+	var productIDs []string
+	for _, id := range strings.Split(r.URL.Query().Get("product_ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			productIDs = append(productIDs, id)
+		}
+	}
+
+	type recommendationView struct {
+		ID      string    `json:"id"`
+		Name    string    `json:"name"`
+		Picture string    `json:"picture"`
+		Price   *pb.Money `json:"price"`
+	}
+	out := []recommendationView{}
+	recommendations, err := fe.getRecommendations(r.Context(), sessionID(r), productIDs)
+	if err != nil {
+		log.WithField("error", err).Warn("failed to get product recommendations")
+	}
+	for _, p := range recommendations {
+		price, err := fe.convertCurrency(r.Context(), p.GetPriceUsd(), currentCurrency(r))
+		if err != nil {
+			log.WithField("error", err).WithField("product_id", p.GetId()).Warn("failed to convert recommendation price")
+			continue
+		}
+		out = append(out, recommendationView{ID: p.GetId(), Name: p.GetName(), Picture: p.GetPicture(), Price: price})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"recommendations": out}); err != nil {
+		log.WithError(err).Error("failed to encode recommendations")
+	}
+}
+
+// convertAPIHandler converts an amount between two supported currencies:
+// GET /api/convert?from=USD&to=EUR&units=12&nanos=500000000.
+func (fe *frontendServer) convertAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	q := r.URL.Query()
+
+	from, to := strings.ToUpper(q.Get("from")), strings.ToUpper(q.Get("to"))
+	for _, code := range []string{from, to} {
+		if !whitelistedCurrencies[code] {
+			renderAPIError(log, r, w, errors.Errorf("unsupported currency %q", code), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	units, err := strconv.ParseInt(q.Get("units"), 10, 64)
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "invalid units"), http.StatusUnprocessableEntity)
+		return
+	}
+	var nanos int64
+	if v := q.Get("nanos"); v != "" {
+		if nanos, err = strconv.ParseInt(v, 10, 32); err != nil {
+			renderAPIError(log, r, w, errors.Wrap(err, "invalid nanos"), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	amount := &pb.Money{CurrencyCode: from, Units: units, Nanos: int32(nanos)}
+	if !money.IsValid(*amount) {
+		renderAPIError(log, r, w, errors.New("invalid amount: nanos must be within ±999999999 and have the same sign as units"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	converted, err := fe.convertCurrency(r.Context(), amount, to)
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "failed to convert currency"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(converted); err != nil {
+		log.WithError(err).Error("failed to encode conversion")
+	}
+}
+
 func (fe *frontendServer) setCurrencyHandler(w http.ResponseWriter, r *http.Request) {
 	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
-	cur := r.FormValue("currency_code")
+	if err := r.ParseForm(); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not parse form"), http.StatusBadRequest)
+		return
+	}
+	// FormValue would silently pick the first of several values.
+	var cur string
+	switch values := r.Form["currency_code"]; len(values) {
+	case 0:
+	case 1:
+		cur = values[0]
+	default:
+		renderHTTPError(log, r, w, errors.Errorf("expected one currency_code, got %d", len(values)), http.StatusUnprocessableEntity)
+		return
+	}
 	payload := validator.SetCurrencyPayload{Currency: cur}
 	if err := payload.Validate(); err != nil {
 		renderHTTPError(log, r, w, validator.ValidationErrorResponse(err), http.StatusUnprocessableEntity)
@@ -522,17 +964,51 @@ func (fe *frontendServer) setCurrencyHandler(w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusFound)
 }
 
-// chooseAd queries for advertisements available and randomly chooses one, if
-// available. It ignores the error retrieving the ad since it is not critical.
-func (fe *frontendServer) chooseAd(ctx context.Context, ctxKeys []string, log logrus.FieldLogger) *pb.Ad {
+// chooseAd queries for advertisements eligible for the given slot and
+// randomly chooses one. It returns nil if the slot is not configured, no ad
+// is eligible, the ad service fails or the request turned ads off. pageKeys
+// are used for slots that have no context keys of their own.
+func (fe *frontendServer) chooseAd(ctx context.Context, slot string, pageKeys []string, log logrus.FieldLogger) *pb.Ad {
+	if adsDisabled(ctx) {
+		return nil
+	}
+	ctxKeys, ok := adSlots[slot]
+	if !ok {
+		log.WithField("slot", slot).Debug("unknown ad slot")
+		return nil
+	}
+	if len(ctxKeys) == 0 {
+		ctxKeys = pageKeys
+	}
 	ads, err := fe.getAd(ctx, ctxKeys)
 	if err != nil {
 		log.WithField("error", err).Warn("failed to retrieve ads")
 		return nil
 	}
+	if len(ads) == 0 {
+		return nil
+	}
 	return ads[rand.Intn(len(ads))]
 }
 
+// adSlotChooser returns the template function behind {{ call $.ad_slot "name" }}.
+// Each slot is looked up once per page, and only if the template asks for it.
+// The result is the data for the "text_ad" template, or nil.
+func (fe *frontendServer) adSlotChooser(ctx context.Context, pageKeys []string, log logrus.FieldLogger) func(string) map[string]interface{} {
+	chosen := make(map[string]map[string]interface{})
+	return func(slot string) map[string]interface{} {
+		if data, ok := chosen[slot]; ok {
+			return data
+		}
+		var data map[string]interface{}
+		if ad := fe.chooseAd(ctx, slot, pageKeys, log); ad != nil {
+			data = map[string]interface{}{"ad": ad, "slot": slot, "baseUrl": baseUrl}
+		}
+		chosen[slot] = data
+		return data
+	}
+}
+
 func renderHTTPError(log logrus.FieldLogger, r *http.Request, w http.ResponseWriter, err error, code int) {
 	log.WithField("error", err).Error("request error")
 	errMsg := fmt.Sprintf("%+v", err)
@@ -548,7 +1024,100 @@ func renderHTTPError(log logrus.FieldLogger, r *http.Request, w http.ResponseWri
 	}
 }
 
+// renderAPIError replies with a JSON error when the client accepts JSON, for
+// pages that scripts also fetch, and with the HTML error page otherwise.
+func renderAPIError(log logrus.FieldLogger, r *http.Request, w http.ResponseWriter, err error, code int) {
+	if !acceptsJSON(r) {
+		renderHTTPError(log, r, w, err, code)
+		return
+	}
+	log.WithField("error", err).Error("request error")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       err.Error(),
+		"status_code": code,
+		"status":      http.StatusText(code),
+	}); err != nil {
+		log.WithError(err).Error("failed to encode error response")
+	}
+}
+
+// acceptsJSON reports whether the Accept header lists application/json.
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			if strings.EqualFold(strings.TrimSpace(mediaType), "application/json") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// executeTemplate renders the named template into a buffer and copies it to
+// w only once it has succeeded. A template that fails part way through then
+// leaves nothing written, so the caller can still send a clean error page.
+// Errors writing to w mean the client went away and are not reported.
+func executeTemplate(w http.ResponseWriter, name string, data interface{}) error {
+	var b bytes.Buffer
+	if err := templates.ExecuteTemplate(&b, name, data); err != nil {
+		return err
+	}
+	b.WriteTo(w)
+	return nil
+}
+
+// renderPage executes the page template, or only its fragment template when
+// the client wants a fragment to swap into a page it already has.
+func renderPage(w http.ResponseWriter, r *http.Request, page, fragment string, data map[string]interface{}) error {
+	w.Header().Add("Vary", "HX-Request")
+	if wantsFragment(r) {
+		return executeTemplate(w, fragment, data)
+	}
+	return executeTemplate(w, page, data)
+}
+
+// wantsFragment reports whether the request comes from HTMX or asks for a
+// fragment with ?fragment=true. Boosted HTMX navigation still gets the full
+// page.
+func wantsFragment(r *http.Request) bool {
+	if boosted, _ := strconv.ParseBool(r.Header.Get("HX-Boosted")); boosted {
+		return false
+	}
+	for _, v := range []string{r.Header.Get("HX-Request"), r.URL.Query().Get("fragment")} {
+		if fragment, _ := strconv.ParseBool(v); fragment {
+			return true
+		}
+	}
+	return false
+}
+
+// timeoutPage renders the error page served by withHandlerTimeout. It is
+// rendered once, so it carries no session details.
+func timeoutPage() string {
+	r, _ := http.NewRequest(http.MethodGet, baseUrl+"/", nil)
+	var b strings.Builder
+	if err := templates.ExecuteTemplate(&b, "error", injectCommonTemplateData(r, map[string]interface{}{
+		"error":       fmt.Sprintf("The request did not complete within %v. Please try again.", handlerTimeout),
+		"status_code": http.StatusServiceUnavailable,
+		"status":      http.StatusText(http.StatusServiceUnavailable),
+	})); err != nil {
+		log.WithError(err).Warn("failed to render the timeout page")
+		return http.StatusText(http.StatusServiceUnavailable)
+	}
+	return b.String()
+}
+
 func injectCommonTemplateData(r *http.Request, payload map[string]interface{}) map[string]interface{} {
+	assigned := experimentAssignments(sessionID(r))
+	if assigned != nil {
+		if log, ok := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger); ok {
+			log.WithField("experiments", assigned).Debug("experiment assignment")
+		}
+	}
+
 	data := map[string]interface{}{
 		"session_id":        sessionID(r),
 		"request_id":        r.Context().Value(ctxKeyRequestID{}),
@@ -557,10 +1126,12 @@ func injectCommonTemplateData(r *http.Request, payload map[string]interface{}) m
 		"platform_name":     plat.provider,
 		"is_cymbal_brand":   isCymbalBrand,
 		"assistant_enabled": assistantEnabled,
-		"deploymentDetails": deploymentDetailsMap,
+		"deploymentDetails": deploymentDetails.Load(),
 		"frontendMessage":   frontendMessage,
 		"currentYear":       time.Now().Year(),
 		"baseUrl":           baseUrl,
+		"experiments":       assigned, // e.g. {{ if eq (index $.experiments "suggested_recipes") "hide" }}
+		"rates_stale":       staleRates(r.Context()),
 	}
 
 	for k, v := range payload {
@@ -570,10 +1141,33 @@ func injectCommonTemplateData(r *http.Request, payload map[string]interface{}) m
 	return data
 }
 
-func currentCurrency(r *http.Request) string {
-	c, _ := r.Cookie(cookieCurrency)
-	if c != nil {
-		return c.Value
+// shippingCostOrZero guards against a shipping quote without a cost, treating
+// it as free shipping in the given currency.
+func shippingCostOrZero(log logrus.FieldLogger, cost *pb.Money, currency string) *pb.Money {
+	if cost == nil {
+		log.Warn("shipping quote has no cost, treating it as zero")
+		return &pb.Money{CurrencyCode: currency}
+	}
+	return cost
+}
+
+// expirationYears lists count years starting with year. The current year is
+// always offered, even if count is misconfigured.
+func expirationYears(year, count int) []int {
+	if count < 1 {
+		count = 1
+	}
+	years := make([]int, count)
+	for i := range years {
+		years[i] = year + i
+	}
+	return years
+}
+
+func currentCurrency(r *http.Request) string {
+	c, _ := r.Cookie(cookieCurrency)
+	if c != nil {
+		return c.Value
 	}
 	return defaultCurrency
 }
@@ -603,9 +1197,28 @@ func cartSize(c []*pb.CartItem) int {
 	return cartSize
 }
 
-func renderMoney(money pb.Money) string {
-	currencyLogo := renderCurrencyLogo(money.GetCurrencyCode())
-	return fmt.Sprintf("%s%d.%02d", currencyLogo, money.GetUnits(), money.GetNanos()/10000000)
+// renderMoney formats m rounded to the nearest unit of its currency's
+// display precision (see pricePrecision), e.g. "$19.99" or "-€0.50".
+func renderMoney(m pb.Money) string {
+	digits, ok := pricePrecision[m.GetCurrencyCode()]
+	if !ok {
+		digits = defaultPricePrecision
+	}
+	m = money.Round(m, digits)
+	units, nanos, sign := m.GetUnits(), m.GetNanos(), ""
+	if money.IsNegative(m) {
+		units, nanos, sign = -units, -nanos, "-"
+	}
+
+	s := fmt.Sprintf("%s%s%d", sign, renderCurrencyLogo(m.GetCurrencyCode()), units)
+	if digits > 0 {
+		scale := int32(1)
+		for i := digits; i < maxPricePrecision; i++ {
+			scale *= 10
+		}
+		s += fmt.Sprintf(".%0*d", digits, nanos/scale)
+	}
+	return s
 }
 
 func renderCurrencyLogo(currencyCode string) string {
@@ -618,11 +1231,14 @@ func renderCurrencyLogo(currencyCode string) string {
 		"GBP": "£",
 	}
 
-	logo := "$" //default
 	if val, ok := logos[currencyCode]; ok {
-		logo = val
+		return val
 	}
-	return logo
+	// Don't pass an unexpected currency off as dollars.
+	if unknownCurrencySymbol != "" {
+		return unknownCurrencySymbol
+	}
+	return currencyCode
 }
 
 func stringinSlice(slice []string, val string) bool {
@@ -651,23 +1267,46 @@ func (fe *frontendServer) recipesHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Call RecipeService to get list of recipes
-	client := pb.NewRecipeServiceClient(fe.recipeSvcConn)
-	resp, err := client.ListRecipes(r.Context(), &pb.ListRecipesRequest{})
-	if err != nil {
-		log.WithError(err).Error("failed to list recipes")
-		renderHTTPError(log, r, w, errors.Wrap(err, "could not list recipes"), http.StatusInternalServerError)
-		return
+	filters := parseRecipeFilters(r)
+	page := parsePagination(r)
+	key := recipeListKey{currency: currentCurrency(r), page: page, filters: filters}
+	grid, ok := fe.recipeLists.get(key, time.Now())
+	if !ok {
+		// Call RecipeService to get list of recipes
+		client := fe.recipeService()
+		resp, err := client.ListRecipes(r.Context(), &pb.ListRecipesRequest{})
+		if err != nil {
+			log.WithError(err).Error("failed to list recipes")
+			renderHTTPError(log, r, w, errors.Wrap(err, "could not list recipes"), http.StatusInternalServerError)
+			return
+		}
+
+		recipes := filters.apply(resp.Recipes)
+		start, end := page.bounds(len(recipes))
+		// The grid is shared across sessions, so it gets no session data.
+		var b strings.Builder
+		if err := templates.ExecuteTemplate(&b, "recipe_grid", map[string]interface{}{
+			"baseUrl":    baseUrl,
+			"recipes":    recipes[start:end],
+			"pagination": page.templateData(len(recipes)),
+			"filters":    filters,
+		}); err != nil {
+			renderHTTPError(log, r, w, errors.Wrap(err, "could not render recipe list"), http.StatusInternalServerError)
+			return
+		}
+		grid = template.HTML(b.String())
+		fe.recipeLists.set(key, grid, time.Now())
 	}
 
-	if err := templates.ExecuteTemplate(w, "recipe-list", injectCommonTemplateData(r, map[string]interface{}{
+	if err := renderPage(w, r, "recipe-list", "cached_recipe_grid", injectCommonTemplateData(r, map[string]interface{}{
 		"show_currency": true,
 		"currencies":    currencies,
 		"cart_size":     cartSize(cart),
-		"recipes":       resp.Recipes,
+		"recipe_grid":   grid,
+		"filters":       filters,
+		"difficulties":  recipeDifficulties,
 	})); err != nil {
-		log.WithError(err).Error("failed to render recipe list")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
 	}
 }
 
@@ -694,58 +1333,171 @@ func (fe *frontendServer) recipeDetailHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	// Call RecipeService to get recipe details
-	client := pb.NewRecipeServiceClient(fe.recipeSvcConn)
+	client := fe.recipeService()
 	resp, err := client.GetRecipe(r.Context(), &pb.GetRecipeRequest{RecipeId: id})
+	if status.Code(err) == codes.NotFound {
+		renderAPIError(log, r, w, errors.Errorf("recipe %q not found", id), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.WithError(err).Error("failed to get recipe")
-		renderHTTPError(log, r, w, errors.Wrap(err, "could not get recipe"), http.StatusInternalServerError)
+		renderAPIError(log, r, w, errors.Wrap(err, "could not get recipe"), http.StatusInternalServerError)
 		return
 	}
 
-	// Create a map of cart product IDs to quantities for easy lookup
-	cartProductMap := make(map[string]int32)
-	cartProductNames := make(map[string]string) // productId -> productName
+	ingredientCartStatus := fe.recipeCartCoverage(r.Context(), log, resp.Recipe.Ingredients, cart)
+	// Carried into the add-to-cart form so the user lands back where they were.
+	returnTo, _ := sameOriginPath(r, r.URL.Query().Get("return_to"))
+	shown, more := ingredientDisplay(len(resp.Recipe.GetIngredients()))
+	ingredients := orderIngredients(resp.Recipe.GetIngredients(), parseIngredientOrder(r), ingredientCartStatus)
+
+	if err := executeTemplate(w, "recipe-detail", injectCommonTemplateData(r, map[string]interface{}{
+		"show_currency":           true,
+		"currencies":              currencies,
+		"cart_size":               cartSize(cart),
+		"recipe":                  resp.Recipe,
+		"added":                   r.URL.Query().Get("added") == "true",
+		"skipped_pantry":          r.URL.Query()["skipped"],
+		"ingredient_cart_status":  ingredientCartStatus,
+		"ingredients":             ingredients,
+		"return_to":               returnTo,
+		"ingredients_shown":       shown,
+		"more_ingredients":        more,
+		"structured_instructions": structureInstructions(resp.Recipe.GetInstructions()),
+	})); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
+	}
+}
+
+// ingredientDisplay splits a recipe's n ingredients into those listed up front
+// and those behind "show more". Hidden ingredients are still part of the form,
+// so adding everything to the cart uses the full list.
+func ingredientDisplay(n int) (shown, more int) {
+	if maxDisplayedIngredients <= 0 || n <= maxDisplayedIngredients {
+		return n, 0
+	}
+	return maxDisplayedIngredients, n - maxDisplayedIngredients
+}
+
+// recipeInstructionsAPIHandler returns a recipe's instructions as JSON, both
+// as written and as structured steps.
+func (fe *frontendServer) recipeInstructionsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	id := mux.Vars(r)["id"]
+	resp, err := fe.recipeService().GetRecipe(r.Context(), &pb.GetRecipeRequest{RecipeId: id})
+	if status.Code(err) == codes.NotFound {
+		renderAPIError(log, r, w, errors.Errorf("recipe %q not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "could not get recipe"), http.StatusInternalServerError)
+		return
+	}
+
+	instructions := resp.Recipe.GetInstructions()
+	if instructions == nil {
+		instructions = []string{}
+	}
+	steps := structureInstructions(instructions)
+	if steps == nil {
+		steps = []InstructionStep{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"recipe_id":    id,
+		"instructions": instructions,
+		"steps":        steps,
+	}); err != nil {
+		log.WithError(err).Error("failed to encode recipe instructions")
+	}
+}
+
+// recipeCartCoverage maps the name of each recipe ingredient already in the
+// cart to its cart info for template use. Ingredients missing from the cart
+// have no entry.
+func (fe *frontendServer) recipeCartCoverage(ctx context.Context, log logrus.FieldLogger, ingredients []*pb.Ingredient, cart []*pb.CartItem) map[string]map[string]interface{} {
+	cartProductMap, cartProductNames := fe.cartProductNames(ctx, log, cart)
+	ingredientCartStatus := make(map[string]map[string]interface{})
+	for _, ingredient := range ingredients {
+		if productId, confidence := matchIngredientToCart(ingredient.Name, cartProductNames); productId != "" && confidence >= ingredientMatchThreshold {
+			ingredientCartStatus[ingredient.Name] = map[string]interface{}{
+				"in_cart":    true,
+				"quantity":   cartProductMap[productId],
+				"product_id": productId,
+			}
+		}
+	}
+	return ingredientCartStatus
+}
+
+// cartProductNames maps the cart's product IDs to their quantities and to
+// their lowercased names, for matchIngredientToCart. Products that can't be
+// looked up have a quantity but no name, so nothing matches them.
+func (fe *frontendServer) cartProductNames(ctx context.Context, log logrus.FieldLogger, cart []*pb.CartItem) (quantities map[string]int32, names map[string]string) {
+	quantities = make(map[string]int32)
+	names = make(map[string]string)
 	for _, item := range cart {
-		cartProductMap[item.ProductId] = item.Quantity
+		quantities[item.ProductId] = item.Quantity
 
 		// Get product details to get the name
-		product, err := fe.getProduct(r.Context(), item.ProductId)
+		product, err := fe.getProduct(ctx, item.ProductId)
 		if err != nil {
 			log.WithError(err).WithField("product_id", item.ProductId).Warn("could not get product details for cart item")
 			continue
 		}
-		cartProductNames[item.ProductId] = strings.ToLower(product.Name)
+		names[item.ProductId] = strings.ToLower(product.Name)
 	}
+	return quantities, names
+}
 
-	// Create a map of ingredient names to cart info for template use
-	ingredientCartStatus := make(map[string]map[string]interface{})
-	for _, ingredient := range resp.Recipe.Ingredients {
-		ingredientName := strings.ToLower(ingredient.Name)
-
-		// Check if this ingredient matches any product in the cart
-		for productId, productName := range cartProductNames {
-			if strings.Contains(productName, ingredientName) || strings.Contains(ingredientName, strings.Fields(productName)[0]) {
-				ingredientCartStatus[ingredient.Name] = map[string]interface{}{
-					"in_cart":    true,
-					"quantity":   cartProductMap[productId],
-					"product_id": productId,
-				}
-				break
-			}
-		}
+// addIngredientsToCart asks the recipe service to match the comma-separated
+// ingredients to products and add them to the user's cart. The service
+// updates the cart asynchronously, so update clients are notified in the
+// background once the cart changes.
+func (fe *frontendServer) addIngredientsToCart(ctx context.Context, userID string, servings int32, ingredients string) (*pb.ProcessRecipeResponse, error) {
+	// Build recipe text with selected ingredients for processing
+	recipeText := fmt.Sprintf("Add selected ingredients to cart (serves %d): %s",
+		servings, ingredients)
+
+	baseline := -1
+	if cart, err := fe.getCart(ctx, userID); err == nil {
+		baseline = cartSize(cart)
 	}
+	// Call RecipeService to process ONLY the selected ingredients
+	// Don't pass RecipeId to avoid the service using the full recipe
+	resp, err := fe.recipeService().ProcessRecipeRequest(ctx, &pb.ProcessRecipeRequestMessage{
+		Message:  recipeText, // Use the message field for the ingredient list
+		Servings: servings,
+		UserId:   userID,
+		// Deliberately NOT setting RecipeId so it only processes the selected ingredients
+	})
+	if err != nil {
+		return nil, err
+	}
+	recipeCartConversions.Inc()
+	go fe.notifyCartUpdateWhenChanged(userID, baseline)
+	return resp, nil
+}
 
-	if err := templates.ExecuteTemplate(w, "recipe-detail", injectCommonTemplateData(r, map[string]interface{}{
-		"show_currency":          true,
-		"currencies":             currencies,
-		"cart_size":              cartSize(cart),
-		"recipe":                 resp.Recipe,
-		"added":                  r.URL.Query().Get("added") == "true",
-		"ingredient_cart_status": ingredientCartStatus,
-	})); err != nil {
-		log.WithError(err).Error("failed to render recipe detail")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// unknownIngredients returns the selected names that aren't among the recipe's
+// ingredients, compared case-insensitively. The comma-separated
+// ingredient_list field splits names that contain commas, so each
+// comma-separated part of an ingredient name is accepted as well.
+func unknownIngredients(selected, ingredients []string) []string {
+	known := make(map[string]bool)
+	for _, name := range ingredients {
+		known[strings.ToLower(strings.TrimSpace(name))] = true
+		for _, part := range strings.Split(name, ",") {
+			known[strings.ToLower(strings.TrimSpace(part))] = true
+		}
 	}
+	var unknown []string
+	for _, name := range selected {
+		if !known[strings.ToLower(strings.TrimSpace(name))] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
 }
 
 func (fe *frontendServer) addRecipeToCartHandler(w http.ResponseWriter, r *http.Request) {
@@ -756,35 +1508,24 @@ func (fe *frontendServer) addRecipeToCartHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Parse servings from form data
-	servings := int32(4) // default
-	if servingsStr := r.FormValue("servings"); servingsStr != "" {
-		if parsedServings, err := strconv.ParseInt(servingsStr, 10, 32); err == nil {
-			servings = int32(parsedServings)
-		}
-	}
-
 	// Get selected ingredients from form data
-	selectedIngredients := r.FormValue("ingredient_list")
-
-	// If ingredient_list is empty, try to get individual checkbox values as fallback
-	// Note: Only checked checkboxes will have values in the form
-	if selectedIngredients == "" {
-		selectedCheckboxes := r.Form["selected_ingredients"]
-		log.WithField("raw_checkboxes", selectedCheckboxes).Debug("[Recipe] fallback checkbox processing")
-		if len(selectedCheckboxes) > 0 {
-			// Filter out empty values (unchecked checkboxes don't send values)
-			var validIngredients []string
-			for _, ingredient := range selectedCheckboxes {
-				if strings.TrimSpace(ingredient) != "" {
-					validIngredients = append(validIngredients, ingredient)
-				}
-			}
-			if len(validIngredients) > 0 {
-				selectedIngredients = strings.Join(validIngredients, ", ")
-			}
+	var selected []string
+	if list := r.FormValue("ingredient_list"); list != "" {
+		selected = strings.Split(list, ",")
+	} else {
+		// If ingredient_list is empty, try to get individual checkbox values as fallback
+		// Note: Only checked checkboxes will have values in the form
+		selected = r.Form["selected_ingredients"]
+		log.WithField("raw_checkboxes", selected).Debug("[Recipe] fallback checkbox processing")
+	}
+	// Filter out empty values (unchecked checkboxes don't send values)
+	var validIngredients []string
+	for _, ingredient := range selected {
+		if ingredient = strings.TrimSpace(ingredient); ingredient != "" {
+			validIngredients = append(validIngredients, ingredient)
 		}
 	}
+	selectedIngredients := strings.Join(validIngredients, ", ")
 
 	// Debug logging to see what was received
 	log.WithFields(logrus.Fields{
@@ -798,46 +1539,271 @@ func (fe *frontendServer) addRecipeToCartHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// Only forward text that actually comes from the recipe.
+	resp, err := fe.recipeService().GetRecipe(r.Context(), &pb.GetRecipeRequest{RecipeId: id})
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not get recipe"), http.StatusInternalServerError)
+		return
+	}
+	recipeIngredients := make([]string, len(resp.Recipe.GetIngredients()))
+	for i, ingredient := range resp.Recipe.GetIngredients() {
+		recipeIngredients[i] = ingredient.GetName()
+	}
+	if unknown := unknownIngredients(validIngredients, recipeIngredients); len(unknown) > 0 {
+		renderHTTPError(log, r, w, errors.Errorf("not ingredients of recipe %s: %s", id, strings.Join(unknown, ", ")), http.StatusBadRequest)
+		return
+	}
+	servings, err := parseServings(r, resp.Recipe.GetDefaultServings())
+	if err != nil {
+		renderHTTPError(log, r, w, err, http.StatusBadRequest)
+		return
+	}
+
+	toBuy, pantry := splitPantryIngredients(validIngredients)
 	log.WithFields(logrus.Fields{
 		"recipe_id":            id,
 		"servings":             servings,
 		"user":                 sessionID(r),
 		"selected_ingredients": selectedIngredients,
+		"skipped_pantry":       pantry,
 	}).Info("[Recipe] adding selected recipe ingredients to cart")
 
-	// Build recipe text with selected ingredients for processing
-	recipeText := fmt.Sprintf("Add selected ingredients to cart (serves %d): %s",
-		servings, selectedIngredients)
+	if len(toBuy) > 0 {
+		if _, err := fe.addIngredientsToCart(r.Context(), sessionID(r), servings, strings.Join(toBuy, ", ")); err != nil {
+			log.WithError(err).Error("failed to add recipe to cart")
+			renderHTTPError(log, r, w, errors.Wrap(err, "could not add recipe to cart"), http.StatusInternalServerError)
+			return
+		}
+		fe.announceRecipeAdded(id, resp.Recipe.GetTitle())
+	}
 
-	// Call RecipeService to process ONLY the selected ingredients
-	// Don't pass RecipeId to avoid the service using the full recipe
-	recipeClient := pb.NewRecipeServiceClient(fe.recipeSvcConn)
-	_, err := recipeClient.ProcessRecipeRequest(r.Context(), &pb.ProcessRecipeRequestMessage{
-		Message:  recipeText, // Use the message field for the ingredient list
-		Servings: servings,
-		UserId:   sessionID(r),
-		// Deliberately NOT setting RecipeId so it only processes the selected ingredients
-	})
+	// Send the user back where they came from if the form says so, otherwise
+	// to the recipe detail page with a success message.
+	target := baseUrl + "/recipe/" + id + addedQuery(len(toBuy) > 0, pantry)
+	if returnTo := r.FormValue("return_to"); returnTo != "" {
+		if local, ok := sameOriginPath(r, returnTo); ok {
+			target = local
+		} else {
+			log.WithField("return_to", returnTo).Warn("ignoring return_to outside this site")
+		}
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// sameOriginPath returns raw as a path on this site, or false if it points
+// anywhere else. raw may be a path or an absolute URL on the request's host.
+// Protocol-relative and backslash forms that browsers resolve to other hosts
+// are refused.
+func sameOriginPath(r *http.Request, raw string) (string, bool) {
+	if strings.ContainsAny(raw, "\\\r\n") {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if u.IsAbs() || u.Host != "" {
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host != r.Host {
+			return "", false
+		}
+	}
+	if !strings.HasPrefix(u.Path, baseUrl+"/") || strings.HasPrefix(u.Path, "//") {
+		return "", false
+	}
+	local := url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery, Fragment: u.Fragment}
+	return local.String(), true
+}
+
+// completeCartHandler adds every recipe ingredient that isn't already in the
+// cart in one go and reports what was added and what the catalog can't supply.
+func (fe *frontendServer) completeCartHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		renderHTTPError(log, r, w, errors.New("recipe id not specified"), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := fe.recipeService().GetRecipe(r.Context(), &pb.GetRecipeRequest{RecipeId: id})
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not get recipe"), http.StatusInternalServerError)
+		return
+	}
+	cart, err := fe.getCart(r.Context(), sessionID(r))
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
+		return
+	}
+
+	coverage := fe.recipeCartCoverage(r.Context(), log, resp.Recipe.GetIngredients(), cart)
+	inCart, missing := []string{}, []string{}
+	for _, ingredient := range resp.Recipe.GetIngredients() {
+		if _, ok := coverage[ingredient.Name]; ok {
+			inCart = append(inCart, ingredient.Name)
+		} else {
+			missing = append(missing, ingredient.Name)
+		}
+	}
+
+	missing, pantry := splitPantryIngredients(missing)
+	if pantry == nil {
+		pantry = []string{}
+	}
+	added, unavailable := []string{}, []string{}
+	if len(missing) > 0 {
+		servings, err := parseServings(r, resp.Recipe.GetDefaultServings())
+		if err != nil {
+			renderHTTPError(log, r, w, err, http.StatusBadRequest)
+			return
+		}
+		result, err := fe.addIngredientsToCart(r.Context(), sessionID(r), servings, strings.Join(missing, ", "))
+		if err != nil {
+			renderHTTPError(log, r, w, errors.Wrap(err, "could not add recipe to cart"), http.StatusInternalServerError)
+			return
+		}
+		added = append(added, result.GetMatchedProducts()...)
+		unavailable = append(unavailable, result.GetUnmatchedIngredients()...)
+	}
+
+	log.WithFields(logrus.Fields{
+		"recipe_id":   id,
+		"in_cart":     len(inCart),
+		"added":       len(added),
+		"unavailable": len(unavailable),
+		"pantry":      len(pantry),
+	}).Info("[Recipe] completed cart for recipe")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"recipe_id":   id,
+		"in_cart":     inCart,
+		"added":       added,
+		"unavailable": unavailable,
+		"pantry":      pantry,
+	}); err != nil {
+		log.WithError(err).Error("failed to encode response")
+	}
+}
+
+// cartIngredientsAPIHandler returns the names of the products in the cart as
+// a JSON array of strings, ready to send as suggestedRecipesHandler's
+// cart_items. Names are lowercased with whitespace collapsed, and products
+// sharing a name are listed once. An empty cart yields an empty array.
+func (fe *frontendServer) cartIngredientsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	cart, err := fe.getCart(r.Context(), sessionID(r))
 	if err != nil {
-		log.WithError(err).Error("failed to add recipe to cart")
-		renderHTTPError(log, r, w, errors.Wrap(err, "could not add recipe to cart"), http.StatusInternalServerError)
+		renderAPIError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
 		return
 	}
 
-	// Wait for cart to be updated and then notify SSE clients
-	go func() {
-		userID := sessionID(r)
-		// Wait a moment for the async cart operations to complete
-		time.Sleep(2 * time.Second)
+	ingredients := []string{}
+	seen := make(map[string]bool)
+	for _, item := range cart {
+		name := normalizeIngredientName(fe.getProductName(item.GetProductId()))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		ingredients = append(ingredients, name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ingredients); err != nil {
+		log.WithError(err).Error("failed to encode cart ingredients")
+	}
+}
+
+// cartSummaryAPIHandler returns the cart's item count and total in the
+// user's currency, for badges that don't need the items. Prices are added up
+// in USD and converted once, so the cost is one catalog lookup per item and
+// at most one currency call. Products gone from the catalog are left out of
+// the total, as on the cart page.
+func (fe *frontendServer) cartSummaryAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	currency := currentCurrency(r)
+	cart, err := fe.getCart(r.Context(), sessionID(r))
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
+		return
+	}
 
-		// Get updated cart and notify SSE clients
-		if updatedCart, err := fe.getCart(context.Background(), userID); err == nil {
-			fe.notifyCartUpdate(userID, updatedCart)
+	totalUSD := pb.Money{CurrencyCode: "USD"}
+	for _, item := range cart {
+		p, err := fe.getProduct(r.Context(), item.GetProductId())
+		if status.Code(err) == codes.NotFound {
+			continue
 		}
-	}()
+		if err != nil {
+			renderAPIError(log, r, w, errors.Wrapf(err, "could not retrieve product #%s", item.GetProductId()), http.StatusInternalServerError)
+			return
+		}
+		totalUSD = money.Must(money.Sum(totalUSD, money.MultiplySlow(*p.GetPriceUsd(), uint32(item.GetQuantity()))))
+	}
+	total, err := fe.convertCurrency(r.Context(), &totalUSD, currency)
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "failed to convert currency"), http.StatusInternalServerError)
+		return
+	}
 
-	// Redirect back to recipe detail page with success message
-	http.Redirect(w, r, fmt.Sprintf("%s/recipe/%s?added=true", baseUrl, id), http.StatusFound)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":           cartSize(cart),
+		"total_formatted": renderMoney(*total),
+		"currency":        currency,
+	}); err != nil {
+		log.WithError(err).Error("failed to encode cart summary")
+	}
+}
+
+// ingredientInCart is one ingredient's entry in cartContainsAPIHandler's
+// response.
+type ingredientInCart struct {
+	Name      string `json:"name"`
+	InCart    bool   `json:"in_cart"`
+	ProductID string `json:"product_id,omitempty"`
+	Quantity  int32  `json:"quantity,omitempty"`
+}
+
+// cartContainsAPIHandler reports, for each ingredient name posted as
+// {"ingredients": [...]}, whether a product in the cart matches it, using the
+// same matching as the recipe pages. When several cart products match
+// equally well, the one with the smallest ID is reported.
+func (fe *frontendServer) cartContainsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	var req struct {
+		Ingredients []string `json:"ingredients"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "invalid request"), http.StatusBadRequest)
+		return
+	}
+	cart, err := fe.getCart(r.Context(), sessionID(r))
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
+		return
+	}
+
+	quantities, names := fe.cartProductNames(r.Context(), log, cart)
+	results := make([]ingredientInCart, len(req.Ingredients))
+	for i, name := range req.Ingredients {
+		results[i] = ingredientInCart{Name: name}
+		if productID, confidence := matchIngredientToCart(name, names); productID != "" && confidence >= ingredientMatchThreshold {
+			results[i].InCart = true
+			results[i].ProductID = productID
+			results[i].Quantity = quantities[productID]
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"ingredients": results}); err != nil {
+		log.WithError(err).Error("failed to encode cart contents check")
+	}
+}
+
+// normalizeIngredientName lowercases name and collapses its whitespace.
+func normalizeIngredientName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
 }
 
 func (fe *frontendServer) suggestedRecipesHandler(w http.ResponseWriter, r *http.Request) {
@@ -853,6 +1819,7 @@ func (fe *frontendServer) suggestedRecipesHandler(w http.ResponseWriter, r *http
 	var req struct {
 		CartItems []string `json:"cart_items"`
 		SessionID string   `json:"session_id"`
+		MealTime  string   `json:"meal_time"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -863,11 +1830,13 @@ func (fe *frontendServer) suggestedRecipesHandler(w http.ResponseWriter, r *http
 	}
 
 	// Validate request
-	if len(req.CartItems) < 2 {
-		// Return empty result for insufficient ingredients
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode([]interface{}{})
+	mealTime, err := resolveMealTime(req.MealTime, time.Now())
+	if err != nil {
+		renderHTTPError(log, r, w, err, http.StatusBadRequest)
+		return
+	}
+	if len(req.CartItems) < minSuggestionIngredients {
+		writeSuggestions(w, log, nil, suggestionsInsufficientIngredients)
 		return
 	}
 
@@ -875,24 +1844,93 @@ func (fe *frontendServer) suggestedRecipesHandler(w http.ResponseWriter, r *http
 		"cart_items_count": len(req.CartItems),
 		"session_id":       req.SessionID,
 		"ingredients":      req.CartItems,
+		"meal_time":        mealTime,
 	}).Info("requesting suggested recipes")
 
+	fe.writeSuggestedRecipes(w, r, log, req.CartItems, req.SessionID, mealTime, sessionID(r))
+}
+
+// whatCanIMakeHandler suggests recipes for ingredients the user already has
+// at home. Unlike suggestedRecipesHandler it ignores the cart, and its results
+// are cached under a separate key so they don't replace the cart suggestions.
+func (fe *frontendServer) whatCanIMakeHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	log = log.WithField("handler", "what-can-i-make")
+
+	var req struct {
+		Ingredients []string `json:"ingredients"`
+		SessionID   string   `json:"session_id"`
+		MealTime    string   `json:"meal_time"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.WithError(err).Error("failed to decode request")
+		renderHTTPError(log, r, w, errors.Wrap(err, "invalid request"), http.StatusBadRequest)
+		return
+	}
+	mealTime, err := resolveMealTime(req.MealTime, time.Now())
+	if err != nil {
+		renderHTTPError(log, r, w, err, http.StatusBadRequest)
+		return
+	}
+
+	var ingredients []string
+	for _, ingredient := range req.Ingredients {
+		if ingredient = strings.TrimSpace(ingredient); ingredient != "" {
+			ingredients = append(ingredients, ingredient)
+		}
+	}
+	if len(ingredients) < minSuggestionIngredients {
+		writeSuggestions(w, log, nil, suggestionsInsufficientIngredients)
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"ingredients_count": len(ingredients),
+		"session_id":        req.SessionID,
+		"ingredients":       ingredients,
+		"meal_time":         mealTime,
+	}).Info("requesting recipes for ingredients")
+
+	fe.writeSuggestedRecipes(w, r, log, ingredients, req.SessionID, mealTime, pantryRecipesCacheKey(sessionID(r)))
+}
+
+// pantryRecipesCacheKey is the suggestedRecipesCache key for recipes
+// suggested from a user-supplied ingredient list rather than the cart.
+func pantryRecipesCacheKey(sessionID string) string {
+	return sessionID + ":pantry"
+}
+
+// writeSuggestedRecipes asks the recipe service for recipes using the given
+// ingredients, suited to mealTime, caches them under cacheKey and writes them
+// as JSON. Failures degrade to an empty list with reason service_error.
+func (fe *frontendServer) writeSuggestedRecipes(w http.ResponseWriter, r *http.Request, log logrus.FieldLogger, ingredients []string, rpcSessionID, mealTime, cacheKey string) {
+	gen := fe.suggestedRecipeWrites.begin()
 	// Call RecipeService for suggested recipes with extended timeout for image generation
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(withMealTime(r.Context(), mealTime), suggestedRecipesTimeout)
 	defer cancel()
 
-	recipeClient := pb.NewRecipeServiceClient(fe.recipeSvcConn)
+	recipeClient := fe.recipeService()
 	recipeResp, err := recipeClient.GetSuggestedRecipes(ctx, &pb.SuggestedRecipesRequest{
-		CartItems: req.CartItems,
-		SessionId: req.SessionID,
+		CartItems: ingredients,
+		SessionId: rpcSessionID,
 	})
 
 	if err != nil {
-		log.WithError(err).Error("failed to get suggested recipes")
+		switch {
+		case r.Context().Err() != nil:
+			// The client went away, so there is no one to write to.
+			suggestedRecipeRequests.WithLabelValues("canceled").Inc()
+			log.WithError(err).Debug("client canceled suggested recipes request")
+			return
+		case status.Code(err) == codes.DeadlineExceeded:
+			suggestedRecipeRequests.WithLabelValues("timeout").Inc()
+			log.WithError(err).WithField("timeout", suggestedRecipesTimeout).Error("suggested recipes timed out")
+		default:
+			suggestedRecipeRequests.WithLabelValues("error").Inc()
+			log.WithError(err).Error("failed to get suggested recipes")
+		}
 		// Return empty result instead of error to gracefully degrade
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode([]interface{}{})
+		writeSuggestions(w, log, nil, suggestionsServiceError)
 		return
 	}
 
@@ -901,22 +1939,61 @@ func (fe *frontendServer) suggestedRecipesHandler(w http.ResponseWriter, r *http
 	var cachedRecipes []CachedRecipe
 	sessionId := sessionID(r)
 
+	withImage, dropped := 0, 0
+	// Every cached recipe must be reachable by both of its IDs, so an exact
+	// repeat is dropped and any other collision gets a suffixed ID.
+	seen := make(map[[2]string]bool)
+	recipeIDs, stableIDs := make(map[string]int), make(map[string]int)
 	for _, recipe := range recipeResp.Recipes {
+		stableID := stableRecipeID(recipe.Title, recipe.Ingredients)
+		key := [2]string{recipe.RecipeId, stableID}
+		if seen[key] {
+			log.WithFields(logrus.Fields{"recipe_id": recipe.RecipeId, "stable_id": stableID}).Warn("dropping duplicate suggested recipe")
+			continue
+		}
+		seen[key] = true
+		recipeID := uniqueID(recipe.RecipeId, recipeIDs)
+		if recipeID != recipe.RecipeId {
+			log.WithFields(logrus.Fields{"recipe_id": recipe.RecipeId, "renamed_to": recipeID}).Warn("suggested recipe ID collides with another recipe")
+		}
+		stableID = uniqueID(stableID, stableIDs)
+
+		imageData := recipe.ImageData
+		if maxRecipeImageSize > 0 && len(imageData) > maxRecipeImageSize {
+			log.WithFields(logrus.Fields{
+				"recipe_id":  recipe.RecipeId,
+				"image_size": len(imageData),
+				"max_size":   maxRecipeImageSize,
+			}).Warn("dropping oversized recipe image")
+			imageData = ""
+			dropped++
+		} else if imageData != "" {
+			withImage++
+		}
+
 		jsonRecipe := map[string]interface{}{
-			"recipe_id":        recipe.RecipeId,
-			"title":            recipe.Title,
-			"description":      recipe.Description,
-			"cook_time":        recipe.CookTime,
-			"default_servings": recipe.DefaultServings,
-			"ingredients":      recipe.Ingredients,
-			"instructions":     recipe.Instructions,
-			"image_data":       recipe.ImageData, // Include image data in JSON response
+			"recipe_id":               recipeID,
+			"stable_id":               stableID,
+			"title":                   recipe.Title,
+			"description":             recipe.Description,
+			"cook_time":               recipe.CookTime,
+			"default_servings":        recipe.DefaultServings,
+			"ingredients":             recipe.Ingredients,
+			"instructions":            recipe.Instructions,
+			"structured_instructions": structureInstructions(recipe.Instructions),
+			"image_data":              imageData, // Include image data in JSON response
+		}
+		if imageData == "" {
+			// Clients poll for images that are still being generated and fall
+			// back to this once they give up.
+			jsonRecipe["placeholder_image"] = baseUrl + recipePlaceholderImage
 		}
 		jsonRecipes = append(jsonRecipes, jsonRecipe)
 
 		// Create cached recipe for storage
 		cachedRecipe := CachedRecipe{
-			RecipeId:        recipe.RecipeId,
+			RecipeId:        recipeID,
+			StableID:        stableID,
 			Title:           recipe.Title,
 			Description:     recipe.Description,
 			CookTime:        recipe.CookTime,
@@ -925,24 +2002,106 @@ func (fe *frontendServer) suggestedRecipesHandler(w http.ResponseWriter, r *http
 			Instructions:    recipe.Instructions,
 			SessionID:       sessionId,
 			CreatedAt:       time.Now(),
-			ImageData:       recipe.ImageData, // Include image data in cached recipe
+			ImageData:       imageData, // Include image data in cached recipe
 		}
 		cachedRecipes = append(cachedRecipes, cachedRecipe)
 	}
 
-	// Cache the suggested recipes for this session
-	fe.suggestedRecipesCache.Store(sessionId, cachedRecipes)
+	// Cache the suggested recipes for this session, unless a request that
+	// started later already has. This response still gets its own set.
+	if !fe.suggestedRecipeWrites.store(&fe.suggestedRecipesCache, cacheKey, gen, cachedRecipes) {
+		log.Debug("not caching suggested recipes older than the ones already cached")
+	}
+
+	missing := len(cachedRecipes) - withImage - dropped
+	suggestedRecipeRequests.WithLabelValues("ok").Inc()
+	suggestedRecipeImages.WithLabelValues("present").Add(float64(withImage))
+	suggestedRecipeImages.WithLabelValues("missing").Add(float64(missing))
+	suggestedRecipeImages.WithLabelValues("dropped").Add(float64(dropped))
+
+	log.WithFields(logrus.Fields{
+		"suggested_recipes_count": len(jsonRecipes),
+		"images_present":          withImage,
+		"images_missing":          missing,
+		"images_dropped":          dropped,
+	}).Info("returning suggested recipes")
+
+	writeSuggestions(w, log, jsonRecipes, suggestionsOK)
+}
 
-	log.WithField("suggested_recipes_count", len(jsonRecipes)).Info("returning suggested recipes")
+// Reasons given with suggested recipes, so clients can tell "add more
+// ingredients" from "try again later" when there are none.
+const (
+	suggestionsOK                      = "ok"
+	suggestionsInsufficientIngredients = "insufficient_ingredients"
+	suggestionsServiceError            = "service_error"
+)
 
-	// Return JSON response
+// writeSuggestions writes suggested recipes as {"recipes": [...], "reason": ...}.
+// It always answers 200: an empty list is a normal outcome for the page.
+func writeSuggestions(w http.ResponseWriter, log logrus.FieldLogger, recipes []map[string]interface{}, reason string) {
+	if recipes == nil {
+		recipes = []map[string]interface{}{}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(jsonRecipes); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"recipes": recipes,
+		"reason":  reason,
+	}); err != nil {
 		log.WithError(err).Error("failed to encode response")
 	}
 }
 
+// stableRecipeID derives an ID for a suggested recipe from its title and
+// ingredient names. The recipe service may assign new IDs each time it
+// regenerates suggestions; this one stays the same for the same recipe, so
+// links keep working.
+func stableRecipeID(title string, ingredients []*pb.Ingredient) string {
+	names := make([]string, len(ingredients))
+	for i, ing := range ingredients {
+		names[i] = strings.ToLower(strings.TrimSpace(ing.GetName()))
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(title))))
+	for _, name := range names {
+		h.Write([]byte{0})
+		h.Write([]byte(name))
+	}
+	return "sr-" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// uniqueID returns id, or id with a numeric suffix if seen already has it.
+// seen counts the IDs handed out so far and is updated. The first id keeps no
+// suffix and later ones are numbered from 2, so "a", "a", "a" become "a",
+// "a-2", "a-3"; a number already handed out as an ID of its own is skipped.
+func uniqueID(id string, seen map[string]int) string {
+	unique := id
+	for seen[unique] > 0 {
+		seen[id]++
+		unique = fmt.Sprintf("%s-%d", id, seen[id])
+	}
+	seen[unique]++
+	return unique
+}
+
+// findCachedRecipe looks a suggested recipe up by its stable ID, or by the
+// recipe service's ID for links made before stable IDs existed.
+func findCachedRecipe(recipes []CachedRecipe, id string) *CachedRecipe {
+	for i := range recipes {
+		if recipes[i].StableID == id {
+			return &recipes[i]
+		}
+	}
+	for i := range recipes {
+		if recipes[i].RecipeId == id {
+			return &recipes[i]
+		}
+	}
+	return nil
+}
+
 // Helper function to convert protobuf ingredients to cached ingredient format
 func convertToCachedIngredients(ingredients []*pb.Ingredient) []*CachedIngredient {
 	var result []*CachedIngredient
@@ -957,6 +2116,11 @@ func convertToCachedIngredients(ingredients []*pb.Ingredient) []*CachedIngredien
 	return result
 }
 
+// recipePlaceholderImage, relative to baseUrl, is shown for suggested recipes
+// without an image: the recipe service didn't generate one, or it was dropped
+// for being larger than maxRecipeImageSize.
+const recipePlaceholderImage = "/static/images/recipe-placeholder.svg"
+
 // Handler for individual suggested recipe details
 func (fe *frontendServer) suggestedRecipeDetailHandler(w http.ResponseWriter, r *http.Request) {
 	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
@@ -964,7 +2128,7 @@ func (fe *frontendServer) suggestedRecipeDetailHandler(w http.ResponseWriter, r
 	sessionId := sessionID(r)
 
 	if id == "" {
-		renderHTTPError(log, r, w, errors.New("recipe id not specified"), http.StatusBadRequest)
+		renderAPIError(log, r, w, errors.New("recipe id not specified"), http.StatusBadRequest)
 		return
 	}
 
@@ -976,40 +2140,37 @@ func (fe *frontendServer) suggestedRecipeDetailHandler(w http.ResponseWriter, r
 	// Get cached suggested recipes for this session
 	cached, ok := fe.suggestedRecipesCache.Load(sessionId)
 	if !ok {
-		renderHTTPError(log, r, w, errors.New("no suggested recipes found for session"), http.StatusNotFound)
+		renderAPIError(log, r, w, errors.New("no suggested recipes found for session"), http.StatusNotFound)
 		return
 	}
 
 	cachedRecipes, ok := cached.([]CachedRecipe)
 	if !ok {
-		renderHTTPError(log, r, w, errors.New("invalid cached recipes format"), http.StatusInternalServerError)
+		renderAPIError(log, r, w, errors.New("invalid cached recipes format"), http.StatusInternalServerError)
 		return
 	}
 
-	// Find the specific recipe
-	var recipe *CachedRecipe
-	for i := range cachedRecipes {
-		if cachedRecipes[i].RecipeId == id {
-			recipe = &cachedRecipes[i]
-			break
-		}
-	}
-
+	recipe := findCachedRecipe(cachedRecipes, id)
 	if recipe == nil {
-		renderHTTPError(log, r, w, errors.New("suggested recipe not found"), http.StatusNotFound)
+		renderAPIError(log, r, w, errors.New("suggested recipe not found"), http.StatusNotFound)
 		return
 	}
+	var placeholderImage string
+	if recipe.ImageData == "" {
+		log.WithField("recipe_id", recipe.RecipeId).Info("suggested recipe has no image, showing placeholder")
+		placeholderImage = recipePlaceholderImage
+	}
 
 	// Get currencies and cart (same as regular recipe handler)
 	currencies, err := fe.getCurrencies(r.Context())
 	if err != nil {
-		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
+		renderAPIError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
 		return
 	}
 
 	cart, err := fe.getCart(r.Context(), sessionId)
 	if err != nil {
-		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
+		renderAPIError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
 		return
 	}
 
@@ -1037,69 +2198,46 @@ func (fe *frontendServer) suggestedRecipeDetailHandler(w http.ResponseWriter, r
 		ingredientNames[i] = ingredient.Name
 	}
 
-	// Call recipe service to check ingredient availability
-	recipeClient := pb.NewRecipeServiceClient(fe.recipeSvcConn)
-	ingredientList := strings.Join(ingredientNames, ", ")
-	checkMessage := fmt.Sprintf("Check ingredient availability: %s", ingredientList)
-	
-	checkResp, err := recipeClient.ProcessRecipeRequest(r.Context(), &pb.ProcessRecipeRequestMessage{
-		Message: checkMessage,
-		UserId:  sessionId,
-	})
+	unmatched, unchecked := fe.checkIngredientAvailability(r.Context(), log, sessionId, ingredientNames)
+	log.WithFields(logrus.Fields{
+		"unmatched_ingredients": unmatched,
+		"unchecked_ingredients": unchecked,
+	}).Info("[Suggested Recipe Detail] ingredient availability check completed")
 
 	var unavailableIngredients map[string]bool = make(map[string]bool)
-	if err == nil && checkResp != nil {
-		// Use the unmatched_ingredients field from the response
-		log.WithFields(logrus.Fields{
-			"matched_products":     checkResp.MatchedProducts,
-			"ingredients":          checkResp.Ingredients,
-			"unmatched_ingredients": checkResp.UnmatchedIngredients,
-		}).Info("[Suggested Recipe Detail] ingredient availability check completed")
-		
-		// Mark unmatched ingredients as unavailable
-		for _, unmatchedIngredient := range checkResp.UnmatchedIngredients {
-			// Find the original recipe ingredient that corresponds to this unmatched ingredient
-			for _, recipeIngredient := range recipe.Ingredients {
-				ingredientLower := strings.ToLower(recipeIngredient.Name)
-				unmatchedLower := strings.ToLower(unmatchedIngredient)
-				
-				// Check if the cleaned ingredient name is contained in the original ingredient name
-				// For example: "Ginger" (unmatched) should match "Grated Fresh Ginger" (original)
-				if strings.Contains(ingredientLower, unmatchedLower) || strings.Contains(unmatchedLower, ingredientLower) {
-					unavailableIngredients[recipeIngredient.Name] = true
-					break
-				}
+	// Mark unmatched ingredients as unavailable
+	for _, unmatchedIngredient := range unmatched {
+		// Find the original recipe ingredient that corresponds to this unmatched ingredient
+		for _, recipeIngredient := range recipe.Ingredients {
+			ingredientLower := strings.ToLower(recipeIngredient.Name)
+			unmatchedLower := strings.ToLower(unmatchedIngredient)
+
+			// Check if the cleaned ingredient name is contained in the original ingredient name
+			// For example: "Ginger" (unmatched) should match "Grated Fresh Ginger" (original)
+			if strings.Contains(ingredientLower, unmatchedLower) || strings.Contains(unmatchedLower, ingredientLower) {
+				unavailableIngredients[recipeIngredient.Name] = true
+				break
 			}
 		}
-	} else {
-		log.WithError(err).Warn("[Suggested Recipe Detail] failed to check ingredient availability, using fallback")
+	}
+	if len(unchecked) > 0 {
+		log.WithField("ingredients", unchecked).Warn("[Suggested Recipe Detail] failed to check ingredient availability, using fallback")
 		// Fallback to static logic
-		for _, recipeIngredient := range recipe.Ingredients {
-			if !fe.isIngredientAvailableInCatalog(strings.ToLower(recipeIngredient.Name)) {
-				unavailableIngredients[recipeIngredient.Name] = true
+		for _, name := range unchecked {
+			if !fe.isIngredientAvailableInCatalog(strings.ToLower(name)) {
+				unavailableIngredients[name] = true
 			}
 		}
 	}
 
 	// Now match ingredients to cart status
 	for _, recipeIngredient := range recipe.Ingredients {
-		ingredientNameLower := strings.ToLower(recipeIngredient.Name)
-
 		// Find matching products in cart by name similarity
-		var matchedProductId string
-		var matchedQuantity int32
-		for productId, productName := range cartProductNames {
-			if strings.Contains(productName, ingredientNameLower) || strings.Contains(ingredientNameLower, productName) {
-				matchedProductId = productId
-				matchedQuantity = cartProductMap[productId]
-				break
-			}
-		}
-
-		if matchedProductId != "" {
+		matchedProductId, confidence := matchIngredientToCart(recipeIngredient.Name, cartProductNames)
+		if matchedProductId != "" && confidence >= ingredientMatchThreshold {
 			ingredientCartStatus[recipeIngredient.Name] = map[string]interface{}{
 				"in_cart":    true,
-				"quantity":   matchedQuantity,
+				"quantity":   cartProductMap[matchedProductId],
 				"product_id": matchedProductId,
 			}
 		} else if unavailableIngredients[recipeIngredient.Name] {
@@ -1116,44 +2254,32 @@ func (fe *frontendServer) suggestedRecipeDetailHandler(w http.ResponseWriter, r
 		"unavailable_ingredients": unavailableIngredients,
 	}).Info("[Suggested Recipe Detail] final ingredient status before template")
 
+	shown, more := ingredientDisplay(len(recipe.Ingredients))
+	ingredients := orderIngredients(recipe.Ingredients, parseIngredientOrder(r), ingredientCartStatus)
+
 	// Render the recipe detail template
-	if err := templates.ExecuteTemplate(w, "recipe-detail", injectCommonTemplateData(r, map[string]interface{}{
-		"show_currency":          true,
-		"currencies":             currencies,
-		"cart_size":              len(cart),
-		"recipe":                 recipe,
-		"suggested":              true, // Flag to indicate this is a suggested recipe
-		"ingredient_cart_status": ingredientCartStatus,
+	if err := executeTemplate(w, "recipe-detail", injectCommonTemplateData(r, map[string]interface{}{
+		"show_currency":           true,
+		"currencies":              currencies,
+		"cart_size":               len(cart),
+		"recipe":                  recipe,
+		"suggested":               true, // Flag to indicate this is a suggested recipe
+		"placeholder_image":       placeholderImage,
+		"structured_instructions": structureInstructions(recipe.Instructions),
+		"ingredient_cart_status":  ingredientCartStatus,
+		"ingredients":             ingredients,
+		"ingredients_shown":       shown,
+		"more_ingredients":        more,
+		"skipped_pantry":          r.URL.Query()["skipped"],
 	})); err != nil {
-		log.WithError(err).Error("failed to render suggested recipe template")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
 	}
 }
 
 // Check if an ingredient is likely available in the product catalog
 func (fe *frontendServer) isIngredientAvailableInCatalog(ingredientName string) bool {
-	// List of ingredients that are commonly not available in grocery catalogs
-	// Based on the ingredient matcher's mapping, these are typically not stocked
-	unavailableIngredients := []string{
-		"dried herbs", "fresh herbs", "mixed herbs", "herbs",
-		"salt", "pepper", "seasoning", "spice", "spices",
-		"garlic powder", "onion powder", "dried", "fresh",
-		"chopped", "minced", "ground", "extract", "essence",
-		"flavoring", "vanilla", "baking powder", "baking soda",
-		"yeast", "water", "ice", "stock", "broth",
-	}
-
-	ingredientLower := strings.ToLower(ingredientName)
-	
-	// Check if ingredient contains any unavailable terms
-	for _, unavailable := range unavailableIngredients {
-		if strings.Contains(ingredientLower, unavailable) {
-			return false
-		}
-	}
-	
-	// For other ingredients, assume they might be available
-	return true
+	// Pantry staples aren't stocked; assume anything else might be.
+	return !isPantryIngredient(ingredientName)
 }
 
 // Handler for adding suggested recipe ingredients to cart
@@ -1167,14 +2293,6 @@ func (fe *frontendServer) addSuggestedRecipeToCartHandler(w http.ResponseWriter,
 		return
 	}
 
-	// Parse servings from form data
-	servings := int32(4) // default
-	if servingsStr := r.FormValue("servings"); servingsStr != "" {
-		if parsedServings, err := strconv.ParseInt(servingsStr, 10, 32); err == nil {
-			servings = int32(parsedServings)
-		}
-	}
-
 	// Get selected ingredients from form data
 	selectedIngredients := r.FormValue("ingredient_list")
 
@@ -1197,7 +2315,6 @@ func (fe *frontendServer) addSuggestedRecipeToCartHandler(w http.ResponseWriter,
 
 	log.WithFields(logrus.Fields{
 		"recipe_id":            id,
-		"servings":             servings,
 		"selected_ingredients": selectedIngredients,
 	}).Info("[Suggested Recipe] adding ingredients to cart")
 
@@ -1219,64 +2336,60 @@ func (fe *frontendServer) addSuggestedRecipeToCartHandler(w http.ResponseWriter,
 		return
 	}
 
-	// Find the specific recipe
-	var recipe *CachedRecipe
-	for i := range cachedRecipes {
-		if cachedRecipes[i].RecipeId == id {
-			recipe = &cachedRecipes[i]
-			break
-		}
-	}
-
+	recipe := findCachedRecipe(cachedRecipes, id)
 	if recipe == nil {
 		renderHTTPError(log, r, w, errors.New("suggested recipe not found"), http.StatusNotFound)
 		return
 	}
-
-	// Build recipe text with selected ingredients for processing (same format as regular recipe handler)
-	recipeText := fmt.Sprintf("Add selected ingredients to cart (serves %d): %s",
-		servings, selectedIngredients)
-
-	// Call RecipeService to process the suggested recipe ingredients
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
-
-	client := pb.NewRecipeServiceClient(fe.recipeSvcConn)
-	_, err := client.ProcessRecipeRequest(ctx, &pb.ProcessRecipeRequestMessage{
-		Message:  recipeText, // Use the formatted message instead of raw ingredients
-		Servings: servings,
-		UserId:   sessionId,
-		// Deliberately NOT setting RecipeId to match the working handler pattern
-	})
-
+	servings, err := parseServings(r, recipe.DefaultServings)
 	if err != nil {
-		log.WithError(err).Error("failed to process suggested recipe request")
-		renderHTTPError(log, r, w, errors.Wrap(err, "could not add suggested recipe ingredients to cart"), http.StatusInternalServerError)
+		renderHTTPError(log, r, w, err, http.StatusBadRequest)
 		return
 	}
 
-	log.WithField("recipe_id", id).Info("[Suggested Recipe] successfully added ingredients to cart")
-
-	// Wait for cart to be updated and then notify SSE clients
-	go func() {
-		userID := sessionID(r) // Use sessionID(r) instead of sessionId variable
-		log.WithField("user_id", userID).Info("[Suggested Recipe] starting cart notification goroutine")
+	var names []string
+	for _, name := range strings.Split(selectedIngredients, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	toBuy, pantry := splitPantryIngredients(names)
 
-		// Wait a moment for the async cart operations to complete
-		time.Sleep(2 * time.Second)
+	if len(toBuy) > 0 {
+		// Call RecipeService to process the suggested recipe ingredients
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
 
-		// Get updated cart and notify SSE clients
-		if updatedCart, err := fe.getCart(context.Background(), userID); err == nil {
-			log.WithFields(logrus.Fields{
-				"user_id":          userID,
-				"cart_items_count": len(updatedCart),
-			}).Info("[Suggested Recipe] sending cart notification")
-			fe.notifyCartUpdate(userID, updatedCart)
-		} else {
-			log.WithError(err).WithField("user_id", userID).Error("[Suggested Recipe] failed to get cart for notification")
+		if _, err := fe.addIngredientsToCart(ctx, sessionId, servings, strings.Join(toBuy, ", ")); err != nil {
+			log.WithError(err).Error("failed to process suggested recipe request")
+			renderHTTPError(log, r, w, errors.Wrap(err, "could not add suggested recipe ingredients to cart"), http.StatusInternalServerError)
+			return
 		}
-	}()
+		fe.announceRecipeAdded(id, recipe.Title)
+	}
+
+	log.WithFields(logrus.Fields{
+		"recipe_id":      id,
+		"servings":       servings,
+		"skipped_pantry": pantry,
+	}).Info("[Suggested Recipe] successfully added ingredients to cart")
 
 	// Redirect back to the suggested recipe detail page with success flag
-	http.Redirect(w, r, fmt.Sprintf("%s/suggested-recipe/%s?added=true", baseUrl, id), http.StatusFound)
+	http.Redirect(w, r, baseUrl+"/suggested-recipe/"+id+addedQuery(len(toBuy) > 0, pantry), http.StatusFound)
+}
+
+// addedQuery is the query string recipe pages are redirected to after adding
+// ingredients: whether any were added, and the pantry staples left out.
+func addedQuery(added bool, skipped []string) string {
+	q := url.Values{}
+	if added {
+		q.Set("added", "true")
+	}
+	if len(skipped) > 0 {
+		q["skipped"] = skipped
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
 }
@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// backendHealth is the connection state of one gRPC backend.
+type backendHealth struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	State   string `json:"state"`
+}
+
+// healthSummary is the body of /api/health. Status is "degraded" when any
+// backend is failing, shut down or was never connected, and "ok" otherwise.
+type healthSummary struct {
+	Status   string          `json:"status"`
+	Backends []backendHealth `json:"backends"`
+}
+
+type healthTarget struct {
+	name string
+	addr string
+	conn *grpc.ClientConn
+}
+
+// checkHealth reports the state of every gRPC connection the frontend holds.
+// Reading the state doesn't make the connection dial, so idle backends show
+// as IDLE. The shopping assistant is reached over plain HTTP and has no
+// connection to report.
+func (fe *frontendServer) checkHealth() healthSummary {
+	targets := []healthTarget{
+		{"productcatalog", fe.productCatalogSvcAddr, fe.productCatalogSvcConn},
+		{"currency", fe.currencySvcAddr, fe.currencySvcConn},
+		{"cart", fe.cartSvcAddr, fe.cartSvcConn},
+		{"recommendation", fe.recommendationSvcAddr, fe.recommendationSvcConn},
+		{"checkout", fe.checkoutSvcAddr, fe.checkoutSvcConn},
+		{"shipping", fe.shippingSvcAddr, fe.shippingSvcConn},
+		{"ad", fe.adSvcAddr, fe.adSvcConn},
+		{"recipe", fe.recipeSvcAddr, fe.recipeSvcConn},
+	}
+	if fe.collectorConn != nil {
+		// Only connected when tracing is enabled.
+		targets = append(targets, healthTarget{"collector", fe.collectorAddr, fe.collectorConn})
+	}
+
+	summary := healthSummary{Status: "ok", Backends: make([]backendHealth, len(targets))}
+	for i, t := range targets {
+		state := "NOT_CONNECTED"
+		if t.conn != nil {
+			state = t.conn.GetState().String()
+		}
+		switch state {
+		case "NOT_CONNECTED", connectivity.TransientFailure.String(), connectivity.Shutdown.String():
+			summary.Status = "degraded"
+		}
+		summary.Backends[i] = backendHealth{Name: t.name, Address: t.addr, State: state}
+	}
+	return summary
+}
+
+// healthAPIHandler serves the connection state of every backend. It is
+// informational and always answers 200, whatever the backends' state.
+func (fe *frontendServer) healthAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(fe.checkHealth()); err != nil {
+		log.WithError(err).Error("failed to encode health summary")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthAPIHandlerListsBackends(t *testing.T) {
+	fe, _ := newTestFrontend(t)
+	fe.cartSvcAddr = "cartservice:7070"
+	state := fe.cartSvcConn.GetState().String()
+	// A backend that was never connected makes the summary degraded, but the
+	// endpoint still answers 200.
+	fe.adSvcConn = nil
+
+	rr := httptest.NewRecorder()
+	fe.healthAPIHandler(rr, newTestRequest(http.MethodGet, "/api/health", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+	}
+	var got healthSummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding %q: %v", rr.Body, err)
+	}
+	if got.Status != "degraded" {
+		t.Errorf("got status %q, want degraded", got.Status)
+	}
+
+	backends := map[string]backendHealth{}
+	for _, b := range got.Backends {
+		backends[b.Name] = b
+	}
+	for _, name := range []string{"productcatalog", "currency", "cart", "recommendation", "checkout", "shipping", "ad", "recipe"} {
+		if _, ok := backends[name]; !ok {
+			t.Errorf("health summary is missing backend %q", name)
+		}
+	}
+	if _, ok := backends["collector"]; ok {
+		t.Error("health summary lists the collector although tracing is off")
+	}
+	if b := backends["cart"]; b.Address != "cartservice:7070" || b.State != state {
+		t.Errorf("got cart backend %+v, want address cartservice:7070 and state %s", b, state)
+	}
+	if b := backends["ad"]; b.State != "NOT_CONNECTED" {
+		t.Errorf("got ad backend state %q, want NOT_CONNECTED", b.State)
+	}
+}
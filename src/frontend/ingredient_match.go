@@ -0,0 +1,67 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "strings"
+
+// matchIngredientToCart finds the cart product that best matches a recipe
+// ingredient. cartProductNames maps product IDs to names. The confidence runs
+// from 0, nothing in common, to 1, the same name; callers compare it with
+// ingredientMatchThreshold. It is the share of words the two names have in
+// common, so "basil" against "Fresh Basil Leaves" scores 0.5. Words match when
+// one is a prefix of the other, which covers most plurals.
+func matchIngredientToCart(ingredient string, cartProductNames map[string]string) (productID string, confidence float64) {
+	words := strings.Fields(pantryWords(ingredient))
+	if len(words) == 0 {
+		return "", 0
+	}
+	for id, name := range cartProductNames {
+		score := nameMatchConfidence(words, strings.Fields(pantryWords(name)))
+		// Map order is random, so break ties on the ID to keep pages stable.
+		if score > confidence || score == confidence && score > 0 && id < productID {
+			productID, confidence = id, score
+		}
+	}
+	return productID, confidence
+}
+
+// nameMatchConfidence is the Dice coefficient of two names' words.
+func nameMatchConfidence(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if strings.Join(a, " ") == strings.Join(b, " ") {
+		return 1
+	}
+	shared := 0
+	for _, w := range a {
+		for _, v := range b {
+			if wordsMatch(w, v) {
+				shared++
+				break
+			}
+		}
+	}
+	return min(1, float64(2*shared)/float64(len(a)+len(b)))
+}
+
+// wordsMatch reports whether w and v are the same word, allowing for a suffix
+// such as a plural. Very short words must match exactly.
+func wordsMatch(w, v string) bool {
+	if len(w) > len(v) {
+		w, v = v, w
+	}
+	return w == v || len(w) >= 3 && strings.HasPrefix(v, w)
+}
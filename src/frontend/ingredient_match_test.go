@@ -0,0 +1,86 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestMatchIngredientToCart(t *testing.T) {
+	cart := map[string]string{
+		"BASIL":   "basil",
+		"OIL":     "extra virgin olive oil",
+		"TOMATO":  "tomatoes",
+		"CHICKEN": "chicken thighs",
+	}
+	tests := []struct {
+		ingredient string
+		wantID     string
+		want       float64
+	}{
+		{"Basil", "BASIL", 1},
+		{"Tomato", "TOMATO", 1},
+		{"fresh basil leaves", "BASIL", 0.5},
+		{"olive oil", "OIL", 2.0 * 2 / 6},
+		{"chicken breast", "CHICKEN", 0.5},
+		{"saffron", "", 0},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		id, got := matchIngredientToCart(tt.ingredient, cart)
+		if id != tt.wantID || got != tt.want {
+			t.Errorf("matchIngredientToCart(%q) = %q, %v, want %q, %v", tt.ingredient, id, got, tt.wantID, tt.want)
+		}
+	}
+}
+
+func TestRecipeCartCoverageHonorsMatchThreshold(t *testing.T) {
+	defer func(old float64) { ingredientMatchThreshold = old }(ingredientMatchThreshold)
+
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(
+		&pb.Product{Id: "BASIL", Name: "Basil"},
+		&pb.Product{Id: "OIL", Name: "Extra Virgin Olive Oil"},
+	)
+	cart := []*pb.CartItem{{ProductId: "BASIL", Quantity: 1}, {ProductId: "OIL", Quantity: 1}}
+	// Against the cart these score 0.5 and 0.67.
+	ingredients := []*pb.Ingredient{{Name: "Fresh Basil Leaves"}, {Name: "Olive Oil"}}
+
+	tests := []struct {
+		threshold float64
+		want      []string
+	}{
+		{0.4, []string{"Fresh Basil Leaves", "Olive Oil"}},
+		{0.5, []string{"Fresh Basil Leaves", "Olive Oil"}},
+		{0.6, []string{"Olive Oil"}},
+		{0.9, nil},
+	}
+	for _, tt := range tests {
+		ingredientMatchThreshold = tt.threshold
+		coverage := fe.recipeCartCoverage(context.Background(), log, ingredients, cart)
+		if len(coverage) != len(tt.want) {
+			t.Errorf("threshold %v: got %d ingredients in the cart, want %v", tt.threshold, len(coverage), tt.want)
+			continue
+		}
+		for _, name := range tt.want {
+			if _, ok := coverage[name]; !ok {
+				t.Errorf("threshold %v: %q is not in the cart, want it to be", tt.threshold, name)
+			}
+		}
+	}
+}
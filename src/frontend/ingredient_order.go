@@ -0,0 +1,72 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Orders the recipe detail page can list ingredients in.
+const (
+	ingredientOrderOriginal     = "original"     // as the recipe gives them
+	ingredientOrderAvailability = "availability" // in cart, then available, then not available
+	ingredientOrderName         = "name"         // alphabetical
+)
+
+func validIngredientOrder(order string) bool {
+	switch order {
+	case ingredientOrderOriginal, ingredientOrderAvailability, ingredientOrderName:
+		return true
+	}
+	return false
+}
+
+// parseIngredientOrder reads the sort query parameter. Unknown values are
+// ignored, leaving the configured ingredientOrder.
+func parseIngredientOrder(r *http.Request) string {
+	if order := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("sort"))); validIngredientOrder(order) {
+		return order
+	}
+	return ingredientOrder
+}
+
+// orderIngredients returns a copy of ingredients in the given order. status
+// is the page's ingredient_cart_status, which is keyed by name and so isn't
+// affected by the reordering. Ties keep the recipe's order.
+func orderIngredients[T interface{ GetName() string }](ingredients []T, order string, status map[string]map[string]interface{}) []T {
+	sorted := slices.Clone(ingredients)
+	switch order {
+	case ingredientOrderAvailability:
+		rank := func(ing T) int {
+			s := status[ing.GetName()]
+			switch {
+			case s["in_cart"] == true:
+				return 0
+			case s["not_available"] == true:
+				return 2
+			default:
+				return 1
+			}
+		}
+		slices.SortStableFunc(sorted, func(a, b T) int { return rank(a) - rank(b) })
+	case ingredientOrderName:
+		slices.SortStableFunc(sorted, func(a, b T) int {
+			return strings.Compare(strings.ToLower(a.GetName()), strings.ToLower(b.GetName()))
+		})
+	}
+	return sorted
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func ingredientNames[T interface{ GetName() string }](ingredients []T) []string {
+	names := make([]string, len(ingredients))
+	for i, ing := range ingredients {
+		names[i] = ing.GetName()
+	}
+	return names
+}
+
+func TestOrderIngredients(t *testing.T) {
+	ingredients := testIngredients("Saffron", "basil", "Tomatoes", "Garlic")
+	status := map[string]map[string]interface{}{
+		"Tomatoes": {"in_cart": true, "quantity": int32(1)},
+		"Saffron":  {"in_cart": false, "not_available": true},
+	}
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{ingredientOrderOriginal, []string{"Saffron", "basil", "Tomatoes", "Garlic"}},
+		{ingredientOrderAvailability, []string{"Tomatoes", "basil", "Garlic", "Saffron"}},
+		{ingredientOrderName, []string{"basil", "Garlic", "Saffron", "Tomatoes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			if got := ingredientNames(orderIngredients(ingredients, tt.order, status)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	cached := []*CachedIngredient{{Name: "Tomatoes"}, {Name: "Basil"}}
+	if got := ingredientNames(orderIngredients(cached, ingredientOrderName, nil)); !reflect.DeepEqual(got, []string{"Basil", "Tomatoes"}) {
+		t.Errorf("got cached ingredients %v, want them sorted by name", got)
+	}
+	if got := ingredientNames(ingredients); got[0] != "Saffron" {
+		t.Errorf("ordering changed the recipe's own ingredients to %v", got)
+	}
+}
+
+func TestRecipeDetailIngredientOrder(t *testing.T) {
+	defer func(old string) { ingredientOrder = old }(ingredientOrder)
+	ingredientOrder = ingredientOrderOriginal
+
+	listed := regexp.MustCompile(`data-name="([^"]*)"`)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Tomatoes", "Basil", "Garlic"}},
+		{"?sort=name", []string{"Basil", "Garlic", "Tomatoes"}},
+		{"?sort=NAME", []string{"Basil", "Garlic", "Tomatoes"}},
+		{"?sort=bogus", []string{"Tomatoes", "Basil", "Garlic"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			backends.recipe.recipes = []*pb.Recipe{{RecipeId: "r1", Title: "Bruschetta", Ingredients: testIngredients("Tomatoes", "Basil", "Garlic")}}
+
+			req := mux.SetURLVars(newTestRequest(http.MethodGet, "/recipe/r1"+tt.query, nil), map[string]string{"id": "r1"})
+			rr := httptest.NewRecorder()
+			fe.recipeDetailHandler(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200", rr.Code)
+			}
+			var got []string
+			for _, m := range listed.FindAllStringSubmatch(rr.Body.String(), -1) {
+				got = append(got, m[1])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listed ingredients %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -16,16 +16,18 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/profiler"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
@@ -34,6 +36,7 @@ import (
 	"go.opentelemetry.io/otel/propagation"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
 )
@@ -41,6 +44,7 @@ import (
 // CachedRecipe represents a suggested recipe stored in the cache
 type CachedRecipe struct {
 	RecipeId        string              `json:"recipe_id"`
+	StableID        string              `json:"stable_id"` // See stableRecipeID
 	Title           string              `json:"title"`
 	Description     string              `json:"description"`
 	CookTime        string              `json:"cook_time"`
@@ -59,16 +63,37 @@ type CachedIngredient struct {
 	Unit     string  `json:"unit"`
 }
 
+// GetName matches pb.Ingredient, so either kind can be passed to
+// orderIngredients.
+func (i *CachedIngredient) GetName() string {
+	if i == nil {
+		return ""
+	}
+	return i.Name
+}
+
 const (
 	port            = "8080"
 	defaultCurrency = "USD"
 	cookieMaxAge    = 60 * 60 * 48
 
-	cookiePrefix    = "shop_"
+	// Fewest ingredients the recipe service needs to suggest anything.
+	minSuggestionIngredients = 2
+)
+
+// Cookie names, which start with cookiePrefix; see setCookiePrefix.
+var (
 	cookieSessionID = cookiePrefix + "session-id"
 	cookieCurrency  = cookiePrefix + "currency"
 )
 
+// setCookiePrefix sets cookiePrefix and the cookie names built from it.
+func setCookiePrefix(prefix string) {
+	cookiePrefix = prefix
+	cookieSessionID = prefix + "session-id"
+	cookieCurrency = prefix + "currency"
+}
+
 var (
 	whitelistedCurrencies = map[string]bool{
 		"USD": true,
@@ -80,13 +105,33 @@ var (
 	}
 
 	baseUrl = ""
+
+	// While the recipe service applies its asynchronous cart updates, the
+	// cart is read every recipeCartPollInterval until it changes. If it
+	// hasn't after recipeCartPollTimeout, SSE clients are sent it as it is.
+	recipeCartPollInterval = 250 * time.Millisecond
+	recipeCartPollTimeout  = 8 * time.Second
 )
 
 type ctxKeySessionID struct{}
 
 type CartUpdate struct {
+	// Seq orders updates by when their cart read began: it is reserved
+	// before the read. Reads can finish out of order, so a larger Seq
+	// usually, but not always, holds a newer cart.
+	Seq   uint64     `json:"seq"`
 	Count int        `json:"cart_items_count"`
 	Items []CartItem `json:"items"`
+	// TotalPrice is in the currency the user's client asked for. It is
+	// omitted when it couldn't be worked out.
+	TotalPrice *pb.Money `json:"total_price,omitempty"`
+}
+
+// Announcement is an event sent to every cart update client, whoever's cart
+// they follow. Unlike CartUpdate it carries no cart details.
+type Announcement struct {
+	Message  string `json:"message"`
+	RecipeID string `json:"recipe_id,omitempty"`
 }
 
 type CartItem struct {
@@ -98,174 +143,106 @@ type CartItem struct {
 type frontendServer struct {
 	productCatalogSvcAddr string
 	productCatalogSvcConn *grpc.ClientConn
+	productCatalogClient  lazyClient[pb.ProductCatalogServiceClient]
 
 	currencySvcAddr string
 	currencySvcConn *grpc.ClientConn
+	currencyClient  lazyClient[pb.CurrencyServiceClient]
 
 	cartSvcAddr string
 	cartSvcConn *grpc.ClientConn
+	cartClient  lazyClient[pb.CartServiceClient]
 
 	recommendationSvcAddr string
 	recommendationSvcConn *grpc.ClientConn
+	recommendationClient  lazyClient[pb.RecommendationServiceClient]
 
 	checkoutSvcAddr string
 	checkoutSvcConn *grpc.ClientConn
+	checkoutClient  lazyClient[pb.CheckoutServiceClient]
 
 	shippingSvcAddr string
 	shippingSvcConn *grpc.ClientConn
+	shippingClient  lazyClient[pb.ShippingServiceClient]
 
 	adSvcAddr string
 	adSvcConn *grpc.ClientConn
+	adClient  lazyClient[pb.AdServiceClient]
 
 	recipeSvcAddr string
 	recipeSvcConn *grpc.ClientConn
+	recipeClient  lazyClient[pb.RecipeServiceClient]
+
+	// Post-processes recommendations; nil shows them as the service returns
+	// them.
+	recommender recommendationStrategy
 
 	collectorAddr string
 	collectorConn *grpc.ClientConn
 
 	shoppingAssistantSvcAddr string
 
-	// SSE client tracking for real-time cart updates
-	cartUpdateClients sync.Map // userID -> chan CartUpdate
+	// SSE and WebSocket clients receiving real-time cart updates
+	cartUpdateClients cartUpdateHub
 
-	// Cache for suggested recipes by session
-	suggestedRecipesCache sync.Map // sessionID -> []Recipe
-}
+	// Serializes this frontend's changes to each user's cart
+	cartLocks keyedMutex
 
-// SSE Methods for cart updates
-func (fe *frontendServer) cartUpdatesHandler(w http.ResponseWriter, r *http.Request) {
-	sessionID := sessionID(r)
-	userID := sessionID // Use sessionID as userID for cart updates
+	// Recent cart update connections per user, to throttle reconnect loops
+	cartUpdateReconnects reconnectLimiter
 
-	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
-	log.WithField("user_id", userID).Info("Creating new session for path: /cart/updates")
+	// Rate limits the per-update and per-connection cart update logs
+	cartUpdateLogs logSampler
 
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	// Rate limits the method-not-allowed logs per session
+	methodNotAllowedLogs logSampler
 
-	// Create a channel for this client
-	clientChan := make(chan CartUpdate, 10)
+	// Product names for cart updates, shared across clients
+	productNames productNameCache
 
-	// Store the client channel
-	fe.cartUpdateClients.Store(userID, clientChan)
+	// Exchange rates for converting pages of prices
+	currencyRates currencyRateCache
 
-	// Clean up when client disconnects
-	defer func() {
-		fe.cartUpdateClients.Delete(userID)
-		close(clientChan)
-	}()
-
-	// Keep connection alive and send updates
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
-		return
-	}
+	// Rendered recipe list grids, shared across sessions
+	recipeLists recipeListCache
 
-	// Send initial cart data with items
-	if cart, err := fe.getCart(r.Context(), userID); err == nil {
-		cartItemsCount := cartSize(cart)
-
-		// Convert cart items to serializable format (same as notifyCartUpdate)
-		cartItems := make([]CartItem, len(cart))
-		for i, item := range cart {
-			productName := fe.getProductName(item.ProductId)
-			cartItems[i] = CartItem{
-				ProductID:   item.ProductId,
-				ProductName: productName,
-				Quantity:    item.Quantity,
-			}
-		}
-
-		update := CartUpdate{
-			Count: cartItemsCount,
-			Items: cartItems,
-		}
-
-		data, _ := json.Marshal(update)
-		fmt.Fprintf(w, "data: %s\n\n", data)
-		flusher.Flush()
-	}
-
-	// Listen for updates
-	for {
-		select {
-		case update := <-clientChan:
-			data, err := json.Marshal(update)
-			if err != nil {
-				log.WithError(err).Error("Failed to marshal cart update")
-				continue
-			}
+	// Cache for suggested recipes by session
+	suggestedRecipesCache sync.Map // sessionID -> []Recipe
+	suggestedRecipeWrites suggestionWrites
 
-			fmt.Fprintf(w, "data: %s\n\n", data)
-			flusher.Flush()
+	// Last known product catalog, served when the catalog service is down
+	productSnapshot productSnapshot
 
-		case <-r.Context().Done():
-			return
-		}
-	}
+	// Orders placed in each session
+	orderHistory orderHistory
 }
 
-func (fe *frontendServer) notifyCartUpdate(userID string, cart []*pb.CartItem) {
-	cartItemsCount := cartSize(cart)
-
-	log.WithFields(logrus.Fields{
-		"user_id":          userID,
-		"cart_items_count": cartItemsCount,
-	}).Info("notifyCartUpdate called")
-
-	if clientChan, ok := fe.cartUpdateClients.Load(userID); ok {
-		log.WithFields(logrus.Fields{
-			"user_id":          userID,
-			"cart_items_count": cartItemsCount,
-		}).Info("found SSE client for user, sending update")
-
-		// Convert protobuf cart items to serializable format with product names
-		cartItems := make([]CartItem, len(cart))
-		for i, item := range cart {
-			productName := fe.getProductName(item.ProductId)
-			cartItems[i] = CartItem{
-				ProductID:   item.ProductId,
-				ProductName: productName,
-				Quantity:    item.Quantity,
-			}
-		}
-
-		update := CartUpdate{
-			Count: cartItemsCount,
-			Items: cartItems,
-		}
+func (fe *frontendServer) getProductName(productID string) string {
+	name, _, _ := fe.getCartProduct(productID)
+	return name
+}
 
-		select {
-		case clientChan.(chan CartUpdate) <- update:
-			log.WithFields(logrus.Fields{
-				"user_id":          userID,
-				"cart_items_count": cartItemsCount,
-			}).Info("successfully sent cart update via SSE")
-		default:
-			log.WithField("user_id", userID).Warn("failed to send cart update, channel full")
-		}
-	} else {
-		log.WithField("user_id", userID).Debug("no SSE client found for user")
+// getCartProduct returns a product's name and USD price, from fe.productNames
+// when it has them. If the product can't be looked up, the name falls back to
+// its ID.
+func (fe *frontendServer) getCartProduct(productID string) (string, *pb.Money, error) {
+	if e, ok := fe.productNames.lookup(productID, time.Now()); ok {
+		return e.name, e.priceUSD, nil
 	}
-}
 
-func (fe *frontendServer) getProductName(productID string) string {
 	// Try to get product name from product catalog service
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
 	defer cancel()
 
-	client := pb.NewProductCatalogServiceClient(fe.productCatalogSvcConn)
+	client := fe.productCatalogService()
 	resp, err := client.GetProduct(ctx, &pb.GetProductRequest{Id: productID})
 	if err != nil {
 		log.WithError(err).WithField("product_id", productID).Warn("failed to get product name")
-		return productID // fallback to product ID
+		return productID, nil, err // fallback to product ID
 	}
 
-	return resp.Name
+	fe.productNames.set(resp, time.Now())
+	return resp.Name, resp.GetPriceUsd(), nil
 }
 
 func main() {
@@ -288,7 +265,13 @@ func main() {
 		propagation.NewCompositeTextMapPropagator(
 			propagation.TraceContext{}, propagation.Baggage{}))
 
-	baseUrl = os.Getenv("BASE_URL")
+	var err error
+	if baseUrl, err = normalizeBaseURL(os.Getenv("BASE_URL")); err != nil {
+		log.Fatal(err)
+	}
+	loadConfig(log)
+	plat = detectPlatform(log)
+	svc.recommender = recommendationStrategies[recommendationStrategyName]
 
 	if os.Getenv("ENABLE_TRACING") == "1" {
 		log.Info("Tracing enabled.")
@@ -319,14 +302,16 @@ func main() {
 	mustMapEnv(&svc.recipeSvcAddr, "RECIPE_SERVICE_ADDR")
 	mustMapEnv(&svc.shoppingAssistantSvcAddr, "SHOPPING_ASSISTANT_SERVICE_ADDR")
 
-	mustConnGRPC(ctx, &svc.currencySvcConn, svc.currencySvcAddr)
-	mustConnGRPC(ctx, &svc.productCatalogSvcConn, svc.productCatalogSvcAddr)
-	mustConnGRPC(ctx, &svc.cartSvcConn, svc.cartSvcAddr)
-	mustConnGRPC(ctx, &svc.recommendationSvcConn, svc.recommendationSvcAddr)
-	mustConnGRPC(ctx, &svc.shippingSvcConn, svc.shippingSvcAddr)
-	mustConnGRPC(ctx, &svc.checkoutSvcConn, svc.checkoutSvcAddr)
-	mustConnGRPC(ctx, &svc.adSvcConn, svc.adSvcAddr)
-	mustConnGRPC(ctx, &svc.recipeSvcConn, svc.recipeSvcAddr)
+	svc.connectBackends(ctx, log)
+
+	if selfTestMode {
+		log.Info("running self-test instead of serving")
+		os.Exit(svc.runSelfTest(ctx, os.Stdout))
+	}
+
+	if productSnapshotRefreshInterval > 0 {
+		go svc.runProductSnapshotRefresher(ctx, realClock{}, productSnapshotRefreshInterval)
+	}
 
 	r := mux.NewRouter()
 	r.HandleFunc(baseUrl+"/", svc.homeHandler).Methods(http.MethodGet, http.MethodHead)
@@ -334,30 +319,126 @@ func main() {
 	r.HandleFunc(baseUrl+"/cart", svc.viewCartHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(baseUrl+"/cart", svc.addToCartHandler).Methods(http.MethodPost)
 	r.HandleFunc(baseUrl+"/cart/empty", svc.emptyCartHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl+"/cart/remove", svc.removeFromCartHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl+"/api/cart/ingredients", svc.cartIngredientsAPIHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/cart/summary", svc.cartSummaryAPIHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/cart/contains", svc.cartContainsAPIHandler).Methods(http.MethodPost)
 	r.HandleFunc(baseUrl+"/setCurrency", svc.setCurrencyHandler).Methods(http.MethodPost)
 	r.HandleFunc(baseUrl+"/logout", svc.logoutHandler).Methods(http.MethodGet)
 	r.HandleFunc(baseUrl+"/cart/checkout", svc.placeOrderHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl+"/orders", svc.ordersHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(baseUrl+"/api/orders", svc.ordersAPIHandler).Methods(http.MethodGet)
+	streams(r.HandleFunc(baseUrl+"/api/products", svc.productsAPIHandler)).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/product/{id}", svc.productAPIHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/recommendations", svc.recommendationsAPIHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/convert", svc.convertAPIHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/health", svc.healthAPIHandler).Methods(http.MethodGet)
 	r.HandleFunc(baseUrl+"/recipes", svc.recipesHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(baseUrl+"/recipe/{id}", svc.recipeDetailHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(baseUrl+"/recipe/{id}/add-to-cart", svc.addRecipeToCartHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl+"/recipe/{id}/complete-cart", svc.completeCartHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl+"/recipe/{id}/remove-from-cart", svc.removeRecipeFromCartHandler).Methods(http.MethodPost)
+	r.HandleFunc(baseUrl+"/recipe/{id}/similar", svc.similarRecipesHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(baseUrl+"/api/recipe/{id}/instructions", svc.recipeInstructionsAPIHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/recipe/{id}/ingredients", svc.recipeIngredientsAPIHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/recipe/{id}/similar", svc.similarRecipesAPIHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/suggested-recipe/{id}/ingredients", svc.suggestedRecipeIngredientsAPIHandler).Methods(http.MethodGet)
 	r.HandleFunc(baseUrl+"/suggested-recipe/{id}", svc.suggestedRecipeDetailHandler).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(baseUrl+"/suggested-recipe/{id}/image", svc.suggestedRecipeImageHandler).Methods(http.MethodGet, http.MethodHead)
 	r.HandleFunc(baseUrl+"/suggested-recipe/{id}/add-to-cart", svc.addSuggestedRecipeToCartHandler).Methods(http.MethodPost)
 	r.HandleFunc(baseUrl+"/suggested-recipes", svc.suggestedRecipesHandler).Methods(http.MethodPost)
-	r.HandleFunc(baseUrl+"/cart/updates", svc.cartUpdatesHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/suggested-recipes/export", svc.exportSuggestedRecipesHandler).Methods(http.MethodGet)
+	r.HandleFunc(baseUrl+"/api/what-can-i-make", svc.whatCanIMakeHandler).Methods(http.MethodPost)
+	streams(r.HandleFunc(baseUrl+"/cart/updates", svc.cartUpdatesHandler)).Methods(http.MethodGet)
+	streams(r.HandleFunc(baseUrl+"/cart/ws", svc.cartWebSocketHandler)).Methods(http.MethodGet)
 	r.HandleFunc(baseUrl+"/assistant", svc.assistantHandler).Methods(http.MethodGet, http.MethodHead)
 	r.PathPrefix(baseUrl + "/static/").Handler(http.StripPrefix(baseUrl+"/static/", http.FileServer(http.Dir("./static/"))))
 	r.HandleFunc(baseUrl+"/robots.txt", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "User-agent: *\nDisallow: /") })
+	r.Handle(baseUrl+"/metrics", promhttp.Handler()).Methods(http.MethodGet)
 	r.HandleFunc(baseUrl+"/_healthz", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "ok") })
 	r.HandleFunc(baseUrl+"/product-meta/{ids}", svc.getProductByID).Methods(http.MethodGet)
 	r.HandleFunc(baseUrl+"/bot", svc.chatBotHandler).Methods(http.MethodPost)
+	r.MethodNotAllowedHandler = svc.methodNotAllowedHandler(r)
+
+	srv := newHTTPServer(addr+":"+srvPort, withMiddleware(r, log))
+	stopped := make(chan struct{})
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	go func() {
+		defer close(stopped)
+		<-sigCtx.Done()
+		stop() // a second signal kills the process as usual
+		log.Info("shutting down")
+		svc.shutdown(log, srv)
+	}()
 
-	var handler http.Handler = r
+	log.Infof("starting server on " + addr + ":" + srvPort)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-stopped
+}
+
+// shutdown stops srv gracefully and then closes the backend connections.
+// The cart update streams go first: they never end on their own, so
+// srv.Shutdown would otherwise wait them out until shutdownTimeout. WebSocket
+// streams aren't tracked by srv at all once upgraded.
+func (fe *frontendServer) shutdown(log logrus.FieldLogger, srv *http.Server) {
+	fe.drainCartUpdates(log, cartUpdateDrainGrace)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.WithError(err).Warn("requests were still in flight at shutdown")
+	}
+	fe.closeConns(log)
+}
+
+// closeConns closes every backend connection that was made.
+func (fe *frontendServer) closeConns(log logrus.FieldLogger) {
+	for name, conn := range map[string]*grpc.ClientConn{
+		"productcatalog": fe.productCatalogSvcConn,
+		"currency":       fe.currencySvcConn,
+		"cart":           fe.cartSvcConn,
+		"recommendation": fe.recommendationSvcConn,
+		"checkout":       fe.checkoutSvcConn,
+		"shipping":       fe.shippingSvcConn,
+		"ad":             fe.adSvcConn,
+		"recipe":         fe.recipeSvcConn,
+		"collector":      fe.collectorConn,
+	} {
+		if conn == nil {
+			continue
+		}
+		if err := conn.Close(); err != nil {
+			log.WithError(err).WithField("backend", name).Debug("could not close backend connection")
+		}
+	}
+}
+
+// withMiddleware wraps routes in the middleware every request goes through.
+func withMiddleware(routes *mux.Router, log *logrus.Logger) http.Handler {
+	var handler http.Handler = routes
+	handler = redirectTrailingSlash(handler)           // canonicalize "/path/" to "/path"
+	handler = withHandlerTimeout(handler)              // bound non-streaming requests
+	handler = securityHeaders(handler)                 // add CSP and other security headers
+	handler = instrumentRequests(routes, handler)      // record request metrics
 	handler = &logHandler{log: log, next: handler}     // add logging
 	handler = ensureSessionID(handler)                 // add session ID
 	handler = otelhttp.NewHandler(handler, "frontend") // add OTel tracing
+	return handler
+}
 
-	log.Infof("starting server on " + addr + ":" + srvPort)
-	log.Fatal(http.ListenAndServe(addr+":"+srvPort, handler))
+// newHTTPServer returns a server with the configured timeouts, so slow or
+// idle clients can't hold connections open indefinitely.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		ReadTimeout:       httpReadTimeout,
+		WriteTimeout:      httpWriteTimeout,
+		IdleTimeout:       httpIdleTimeout,
+	}
 }
 func initStats(log logrus.FieldLogger) {
 	// TODO(arbrown) Implement OpenTelemtry stats
@@ -365,7 +446,7 @@ func initStats(log logrus.FieldLogger) {
 
 func initTracing(log logrus.FieldLogger, ctx context.Context, svc *frontendServer) (*sdktrace.TracerProvider, error) {
 	mustMapEnv(&svc.collectorAddr, "COLLECTOR_SERVICE_ADDR")
-	mustConnGRPC(ctx, &svc.collectorConn, svc.collectorAddr)
+	mustConnGRPC(ctx, log, &svc.collectorConn, svc.collectorAddr, startupDialTimeout(log, "COLLECTOR_SERVICE_ADDR"))
 	exporter, err := otlptracegrpc.New(
 		ctx,
 		otlptracegrpc.WithGRPCConn(svc.collectorConn))
@@ -411,15 +492,65 @@ func mustMapEnv(target *string, envKey string) {
 	*target = v
 }
 
-func mustConnGRPC(ctx context.Context, conn **grpc.ClientConn, addr string) {
-	var err error
-	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
-	defer cancel()
-	*conn, err = grpc.DialContext(ctx, addr,
-		grpc.WithInsecure(),
+// connectBackends creates a client for every backend. Only the ones every
+// page needs are waited for; see startupDialTimeout.
+func (fe *frontendServer) connectBackends(ctx context.Context, log logrus.FieldLogger) {
+	mustConnGRPC(ctx, log, &fe.currencySvcConn, fe.currencySvcAddr, startupDialTimeout(log, "CURRENCY_SERVICE_ADDR"))
+	mustConnGRPC(ctx, log, &fe.productCatalogSvcConn, fe.productCatalogSvcAddr, startupDialTimeout(log, "PRODUCT_CATALOG_SERVICE_ADDR"))
+	mustConnGRPC(ctx, log, &fe.cartSvcConn, fe.cartSvcAddr, startupDialTimeout(log, "CART_SERVICE_ADDR"))
+	mustConnGRPC(ctx, log, &fe.recommendationSvcConn, fe.recommendationSvcAddr, startupDialTimeout(log, "RECOMMENDATION_SERVICE_ADDR"))
+	mustConnGRPC(ctx, log, &fe.shippingSvcConn, fe.shippingSvcAddr, startupDialTimeout(log, "SHIPPING_SERVICE_ADDR"))
+	mustConnGRPC(ctx, log, &fe.checkoutSvcConn, fe.checkoutSvcAddr, startupDialTimeout(log, "CHECKOUT_SERVICE_ADDR"))
+	mustConnGRPC(ctx, log, &fe.adSvcConn, fe.adSvcAddr, startupDialTimeout(log, "AD_SERVICE_ADDR"))
+	mustConnGRPC(ctx, log, &fe.recipeSvcConn, fe.recipeSvcAddr, startupDialTimeout(log, "RECIPE_SERVICE_ADDR"))
+}
+
+// connectGRPC creates a client for addr, which connects in the background.
+// With wait it also waits until the connection is ready or ctx is done.
+// Replaced in tests.
+var connectGRPC = func(ctx context.Context, addr string, wait bool) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
 		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()))
 	if err != nil {
-		panic(errors.Wrapf(err, "grpc: failed to connect %s", addr))
+		return nil, err
+	}
+	if !wait {
+		conn.Connect()
+		return conn, nil
+	}
+	if err := waitForReady(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// mustConnGRPC creates a client for addr and, unless timeout is zero, waits
+// up to timeout for it to connect. Backends may start after the frontend, so
+// failed attempts are retried up to grpcDialAttempts in all, waiting
+// grpcDialBackoff and then twice as long each time, before it panics. With a
+// zero timeout only an invalid addr fails, and the client keeps trying to
+// connect in the background.
+func mustConnGRPC(ctx context.Context, log logrus.FieldLogger, conn **grpc.ClientConn, addr string, timeout time.Duration) {
+	wait := grpcDialBackoff
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		c, err := connectGRPC(attemptCtx, addr, timeout > 0)
+		cancel()
+		if err == nil {
+			*conn = c
+			return
+		}
+		if attempt >= grpcDialAttempts {
+			panic(errors.Wrapf(err, "grpc: failed to connect %s after %d attempts", addr, attempt))
+		}
+		log.WithError(err).WithFields(logrus.Fields{"addr": addr, "attempt": attempt}).Warnf("could not connect to backend, retrying in %v", wait)
+		time.Sleep(wait)
+		wait *= 2
 	}
 }
@@ -0,0 +1,273 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const testSessionID = "test-session"
+
+func TestMain(m *testing.M) {
+	log.Out = io.Discard
+	os.Exit(m.Run())
+}
+
+// newTestRequest builds a request carrying the context values that the
+// logHandler and ensureSessionID middleware would normally attach.
+func newTestRequest(method, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	l := logrus.New()
+	l.Out = io.Discard
+	ctx := context.WithValue(r.Context(), ctxKeyLog{}, logrus.FieldLogger(l))
+	ctx = context.WithValue(ctx, ctxKeySessionID{}, testSessionID)
+	ctx = withStaleRatesFlag(ctx)
+	ctx = withDemoToggles(ctx, r)
+	return r.WithContext(ctx)
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	srv := newHTTPServer(":8080", http.NotFoundHandler())
+	for name, got := range map[string]time.Duration{
+		"ReadHeaderTimeout": srv.ReadHeaderTimeout,
+		"ReadTimeout":       srv.ReadTimeout,
+		"WriteTimeout":      srv.WriteTimeout,
+		"IdleTimeout":       srv.IdleTimeout,
+	} {
+		if got <= 0 {
+			t.Errorf("%s is %v, want a positive timeout", name, got)
+		}
+	}
+}
+
+func TestMustConnGRPCUsesConfiguredDialTimeout(t *testing.T) {
+	defer func(old time.Duration) { grpcDialTimeout = old }(grpcDialTimeout)
+	old := connectGRPC
+	defer func() { connectGRPC = old }()
+
+	var deadline time.Time
+	var hasDeadline, waited bool
+	connectGRPC = func(ctx context.Context, addr string, wait bool) (*grpc.ClientConn, error) {
+		deadline, hasDeadline = ctx.Deadline()
+		waited = wait
+		return nil, nil
+	}
+
+	tests := []struct {
+		name     string
+		global   time.Duration
+		override string
+		want     time.Duration
+	}{
+		{"default", 3 * time.Second, "", 3 * time.Second},
+		{"global", 10 * time.Second, "", 10 * time.Second},
+		{"per backend", 10 * time.Second, "500ms", 500 * time.Millisecond},
+		{"no timeout", 0, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grpcDialTimeout = tt.global
+			t.Setenv("CART_SERVICE_DIAL_TIMEOUT", tt.override)
+
+			var conn *grpc.ClientConn
+			start := time.Now()
+			mustConnGRPC(context.Background(), log, &conn, "cart:7070", backendDialTimeout(log, "CART_SERVICE_ADDR"))
+
+			if waited != (tt.want > 0) {
+				t.Errorf("waited for the connection: %v, want %v", waited, tt.want > 0)
+			}
+			if tt.want == 0 {
+				if hasDeadline {
+					t.Errorf("dialed with a deadline in %v, want none", deadline.Sub(start))
+				}
+				return
+			}
+			if got := deadline.Sub(start); !hasDeadline || got < tt.want || got > tt.want+time.Second {
+				t.Errorf("dialed with a deadline in %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseConnsClosesEveryConnection(t *testing.T) {
+	newConn := func() *grpc.ClientConn {
+		t.Helper()
+		conn, err := grpc.NewClient("passthrough:///unused", grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("creating client: %v", err)
+		}
+		return conn
+	}
+	// Backends that were never connected are left nil.
+	fe := &frontendServer{cartSvcConn: newConn(), recipeSvcConn: newConn(), collectorConn: newConn()}
+
+	fe.closeConns(log)
+
+	for name, conn := range map[string]*grpc.ClientConn{"cart": fe.cartSvcConn, "recipe": fe.recipeSvcConn, "collector": fe.collectorConn} {
+		if got := conn.GetState(); got != connectivity.Shutdown {
+			t.Errorf("%s connection is %v after closing, want %v", name, got, connectivity.Shutdown)
+		}
+	}
+}
+
+func TestMustConnGRPCRetriesWithBackoff(t *testing.T) {
+	defer func(attempts int, backoff time.Duration) {
+		grpcDialAttempts, grpcDialBackoff = attempts, backoff
+	}(grpcDialAttempts, grpcDialBackoff)
+	grpcDialAttempts, grpcDialBackoff = 3, 10*time.Millisecond
+	old := connectGRPC
+	defer func() { connectGRPC = old }()
+
+	tests := []struct {
+		name      string
+		failures  int
+		wantPanic bool
+	}{
+		{"first try", 0, false},
+		{"backend starts late", 2, false},
+		{"backend never starts", 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []time.Time
+			connectGRPC = func(ctx context.Context, addr string, wait bool) (*grpc.ClientConn, error) {
+				calls = append(calls, time.Now())
+				if len(calls) <= tt.failures {
+					return nil, errors.New("connection refused")
+				}
+				return &grpc.ClientConn{}, nil
+			}
+			logger, hook := test.NewNullLogger()
+
+			var conn *grpc.ClientConn
+			panicked := func() (panicked bool) {
+				defer func() { panicked = recover() != nil }()
+				mustConnGRPC(context.Background(), logger, &conn, "cart:7070", time.Second)
+				return false
+			}()
+
+			if panicked != tt.wantPanic {
+				t.Errorf("panicked: %v, want %v", panicked, tt.wantPanic)
+			}
+			if want := min(tt.failures+1, grpcDialAttempts); len(calls) != want {
+				t.Errorf("made %d attempts, want %d", len(calls), want)
+			}
+			if !tt.wantPanic && conn == nil {
+				t.Error("conn not set after connecting")
+			}
+			if want := min(tt.failures, grpcDialAttempts-1); len(hook.AllEntries()) != want {
+				t.Errorf("logged %d failed attempts, want %d", len(hook.AllEntries()), want)
+			}
+			// The wait doubles after each failure.
+			for i := 1; i < len(calls); i++ {
+				if gap, want := calls[i].Sub(calls[i-1]), grpcDialBackoff<<(i-1); gap < want {
+					t.Errorf("attempt %d came %v after the last, want at least %v", i+1, gap, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStartupToleratesUnreachableBackends(t *testing.T) {
+	defer func(mode bool, timeout, dial time.Duration, attempts int) {
+		selfTestMode, selfTestTimeout, grpcDialTimeout, grpcDialAttempts = mode, timeout, dial, attempts
+	}(selfTestMode, selfTestTimeout, grpcDialTimeout, grpcDialAttempts)
+	selfTestTimeout, grpcDialTimeout, grpcDialAttempts = 200*time.Millisecond, 200*time.Millisecond, 1
+
+	listen := func() net.Listener {
+		t.Helper()
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lis
+	}
+	// A gRPC server, which a client can connect to, and an address nothing
+	// listens on.
+	lis := listen()
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+	up := lis.Addr().String()
+	lis = listen()
+	down := lis.Addr().String()
+	lis.Close()
+
+	connect := func(fe *frontendServer) (panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		fe.connectBackends(context.Background(), log)
+		return false
+	}
+	withAddrs := func(critical, optional string) *frontendServer {
+		return &frontendServer{
+			productCatalogSvcAddr: critical, currencySvcAddr: critical, cartSvcAddr: critical, checkoutSvcAddr: critical,
+			recommendationSvcAddr: optional, shippingSvcAddr: optional, adSvcAddr: optional, recipeSvcAddr: optional,
+		}
+	}
+
+	t.Run("optional backends down", func(t *testing.T) {
+		selfTestMode, grpcDialTimeout = false, 5*time.Second
+		fe := withAddrs(up, down)
+		defer fe.closeConns(log)
+		if connect(fe) {
+			t.Error("startup panicked with only optional backends down")
+		}
+	})
+
+	t.Run("critical backend down", func(t *testing.T) {
+		selfTestMode, grpcDialTimeout = false, 200*time.Millisecond
+		fe := withAddrs(down, down)
+		defer fe.closeConns(log)
+		if !connect(fe) {
+			t.Error("startup went ahead with critical backends down")
+		}
+	})
+
+	t.Run("self-test", func(t *testing.T) {
+		selfTestMode, grpcDialTimeout = true, 200*time.Millisecond
+		fe := withAddrs(down, down)
+		defer fe.closeConns(log)
+		if connect(fe) {
+			t.Fatal("self-test startup panicked instead of reporting")
+		}
+		var out bytes.Buffer
+		if code := fe.runSelfTest(context.Background(), &out); code != 1 {
+			t.Errorf("self-test exited %d, want 1", code)
+		}
+		var report selfTestReport
+		if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+			t.Fatalf("self-test printed %q: %v", out.String(), err)
+		}
+		if report.Status != "fail" {
+			t.Errorf("got status %q, want fail", report.Status)
+		}
+	})
+}
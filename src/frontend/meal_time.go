@@ -0,0 +1,70 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+// mealTimeMetadataKey is the gRPC metadata key GetSuggestedRecipes receives
+// the meal time under. SuggestedRecipesRequest has no field for it, so the
+// recipe service can opt in to reading it without a proto change.
+const mealTimeMetadataKey = "meal-time"
+
+// Meal times suggestions can be biased towards.
+const (
+	mealBreakfast = "breakfast"
+	mealLunch     = "lunch"
+	mealDinner    = "dinner"
+	mealSnack     = "snack"
+)
+
+// mealTimeAt is the meal eaten around t in mealTimeLocation: breakfast from
+// 5am, lunch from 11am, dinner from 5pm until 10pm, and a snack in between.
+func mealTimeAt(t time.Time) string {
+	switch h := t.In(mealTimeLocation).Hour(); {
+	case h >= 5 && h < 11:
+		return mealBreakfast
+	case h >= 11 && h < 15:
+		return mealLunch
+	case h >= 17 && h < 22:
+		return mealDinner
+	default:
+		return mealSnack
+	}
+}
+
+// resolveMealTime returns the meal time a client asked for, or the one for
+// now if it didn't ask.
+func resolveMealTime(requested string, now time.Time) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(requested)); m {
+	case "":
+		return mealTimeAt(now), nil
+	case mealBreakfast, mealLunch, mealDinner, mealSnack:
+		return m, nil
+	default:
+		return "", errors.Errorf("invalid meal time %q, want breakfast, lunch, dinner or snack", requested)
+	}
+}
+
+// withMealTime attaches mealTime to ctx's outgoing gRPC metadata.
+func withMealTime(ctx context.Context, mealTime string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, mealTimeMetadataKey, mealTime)
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestMealTimeAt(t *testing.T) {
+	defer func(old *time.Location) { mealTimeLocation = old }(mealTimeLocation)
+	mealTimeLocation = time.FixedZone("UTC-5", -5*60*60)
+
+	for _, tt := range []struct {
+		utcHour int
+		want    string
+	}{
+		{12, mealBreakfast}, // 7am local
+		{17, mealLunch},     // noon
+		{21, mealSnack},     // 4pm
+		{0, mealDinner},     // 7pm the day before
+		{4, mealSnack},      // 11pm
+		{9, mealSnack},      // 4am
+		{10, mealBreakfast}, // 5am
+	} {
+		at := time.Date(2026, 3, 2, tt.utcHour, 30, 0, 0, time.UTC)
+		if got := mealTimeAt(at); got != tt.want {
+			t.Errorf("mealTimeAt(%v) = %q, want %q", at.In(mealTimeLocation), got, tt.want)
+		}
+	}
+}
+
+func TestResolveMealTime(t *testing.T) {
+	defer func(old *time.Location) { mealTimeLocation = old }(mealTimeLocation)
+	mealTimeLocation = time.UTC
+	evening := time.Date(2026, 3, 2, 19, 0, 0, 0, time.UTC)
+
+	for requested, want := range map[string]string{"": mealDinner, "Breakfast ": mealBreakfast, "lunch": mealLunch} {
+		if got, err := resolveMealTime(requested, evening); err != nil || got != want {
+			t.Errorf("resolveMealTime(%q) = %q, %v; want %q", requested, got, err, want)
+		}
+	}
+	if _, err := resolveMealTime("brunch", evening); err == nil {
+		t.Error("resolveMealTime(\"brunch\") succeeded, want an error")
+	}
+}
+
+func TestSuggestedRecipesForwardsMealTime(t *testing.T) {
+	for _, tt := range []struct {
+		name, body string
+		want       string
+	}{
+		{"explicit", `{"cart_items": ["eggs", "rice"], "meal_time": "breakfast"}`, mealBreakfast},
+		{"derived", `{"cart_items": ["eggs", "rice"]}`, mealTimeAt(time.Now())},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			var got []string
+			backends.recipe.suggest = func(ctx context.Context, _ *pb.SuggestedRecipesRequest) (*pb.ListRecipesResponse, error) {
+				md, _ := metadata.FromIncomingContext(ctx)
+				got = md.Get(mealTimeMetadataKey)
+				return &pb.ListRecipesResponse{}, nil
+			}
+
+			rr := httptest.NewRecorder()
+			fe.suggestedRecipesHandler(rr, newTestRequest(http.MethodPost, "/suggested-recipes", strings.NewReader(tt.body)))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("forwarded meal time %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestedRecipesRejectsUnknownMealTime(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	rr := httptest.NewRecorder()
+	body := strings.NewReader(`{"cart_items": ["eggs", "rice"], "meal_time": "brunch"}`)
+	fe.suggestedRecipesHandler(rr, newTestRequest(http.MethodPost, "/suggested-recipes", body))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+	backends.recipe.mu.Lock()
+	defer backends.recipe.mu.Unlock()
+	if n := len(backends.recipe.suggestCalls); n != 0 {
+		t.Errorf("made %d GetSuggestedRecipes calls, want 0", n)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are served in the Prometheus text format on /metrics.
+var (
+	suggestedRecipeRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "frontend_suggested_recipe_requests_total",
+		Help: "GetSuggestedRecipes calls by outcome (ok, timeout, error, canceled by the client).",
+	}, []string{"outcome"})
+
+	suggestedRecipeImages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "frontend_suggested_recipe_images_total",
+		Help: "Suggested recipes by generated image outcome (present, missing, dropped for size).",
+	}, []string{"image"})
+
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "frontend_http_requests_total",
+		Help: "HTTP requests by route template and response status.",
+	}, []string{"handler", "code"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "frontend_http_request_duration_seconds",
+		Help:    "HTTP request latency by route template. Cart update streams are observed when they close.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	cartUpdateClientsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "frontend_cart_update_clients",
+		Help: "Cart update streams, SSE and WebSocket, currently open.",
+	})
+
+	recipeCartConversions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "frontend_recipe_cart_conversions_total",
+		Help: "Recipe ingredients sent to the recipe service to be added to a cart.",
+	})
+)
+
+// instrumentRequests records httpRequests and httpRequestDuration for every
+// request. Requests are labelled with the route template they match in
+// routes, such as "/product/{id}", which keeps the label set small; those
+// matching no route are labelled "unmatched".
+func instrumentRequests(routes *mux.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler := "unmatched"
+		var match mux.RouteMatch
+		if routes.Match(r, &match) && match.Route != nil {
+			if tmpl, err := match.Route.GetPathTemplate(); err == nil {
+				handler = tmpl
+			}
+		}
+
+		start := time.Now()
+		rr := &responseRecorder{w: w}
+		next.ServeHTTP(rr, r)
+		code := rr.status
+		if code == 0 {
+			code = http.StatusOK
+		}
+		httpRequests.WithLabelValues(handler, strconv.Itoa(code)).Inc()
+		httpRequestDuration.WithLabelValues(handler).Observe(time.Since(start).Seconds())
+	})
+}
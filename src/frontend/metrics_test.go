@@ -0,0 +1,115 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// scrapeMetric fetches /metrics from srv and returns the value of the
+// unlabelled metric name.
+func scrapeMetric(t *testing.T, srv *httptest.Server, name string) float64 {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("scraping metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading metrics: %v", err)
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if value, ok := strings.CutPrefix(line, name+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("parsing %q: %v", line, err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("%s missing from metrics:\n%s", name, body)
+	return 0
+}
+
+func TestMetricsCountCartUpdateClients(t *testing.T) {
+	metrics := httptest.NewServer(promhttp.Handler())
+	defer metrics.Close()
+
+	fe, _ := newTestFrontend(t)
+	srv := httptest.NewServer(&logHandler{log: log, next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fe.cartUpdatesHandler(w, r.WithContext(context.WithValue(r.Context(), ctxKeySessionID{}, testSessionID)))
+	})})
+	defer srv.Close()
+
+	before := scrapeMetric(t, metrics, "frontend_cart_update_clients")
+	resp, err := http.Get(srv.URL + "/cart/updates")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	// The initial cart is sent once the client is subscribed.
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	if got := scrapeMetric(t, metrics, "frontend_cart_update_clients"); got != before+1 {
+		t.Errorf("got %v open cart update clients, want %v", got, before+1)
+	}
+
+	resp.Body.Close()
+	for deadline := time.Now().Add(time.Second); fe.cartUpdateClients.clientCount() > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("cart update client still subscribed after disconnecting")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := scrapeMetric(t, metrics, "frontend_cart_update_clients"); got != before {
+		t.Errorf("got %v open cart update clients after disconnecting, want %v", got, before)
+	}
+}
+
+func TestInstrumentRequestsLabelsByRoute(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/product/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := instrumentRequests(r, r)
+
+	product := httpRequests.WithLabelValues("/product/{id}", "418")
+	unmatched := httpRequests.WithLabelValues("unmatched", "404")
+	productBefore, unmatchedBefore := testutil.ToFloat64(product), testutil.ToFloat64(unmatched)
+
+	for _, target := range []string{"/product/A", "/product/B", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	if got := testutil.ToFloat64(product) - productBefore; got != 2 {
+		t.Errorf("counted %v product requests, want 2 under the route template", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - unmatchedBefore; got != 1 {
+		t.Errorf("counted %v unmatched requests, want 1", got)
+	}
+}
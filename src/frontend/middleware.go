@@ -15,12 +15,19 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"net"
 	"net/http"
 	"os"
+	"path"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
@@ -61,6 +68,19 @@ func (r *responseRecorder) Flush() {
 	}
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter { return r.w }
+
+// Hijack implements http.Hijacker for WebSocket upgrades
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
 func (lh *logHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	requestID, _ := uuid.NewRandom()
@@ -85,6 +105,8 @@ func (lh *logHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	ctx = context.WithValue(ctx, ctxKeyLog{}, log)
+	ctx = withStaleRatesFlag(ctx)
+	ctx = withDemoToggles(ctx, r)
 	r = r.WithContext(ctx)
 	lh.next.ServeHTTP(rr, r)
 }
@@ -121,3 +143,127 @@ func ensureSessionID(next http.Handler) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	}
 }
+
+// redirectTrailingSlash redirects "/path/" to the canonical "/path" route so
+// both spellings resolve. The root, the static file prefix and the SSE stream
+// are passed through untouched. GET/HEAD get a 301; other methods get a 308 so
+// the method and body are preserved.
+func redirectTrailingSlash(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if p == baseUrl+"/" || !strings.HasSuffix(p, "/") ||
+			strings.HasPrefix(p, baseUrl+"/static/") ||
+			path.Clean(p) == baseUrl+"/cart/updates" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// path.Clean also collapses leading slashes, so "//evil.com/" can't
+		// turn into a protocol-relative redirect.
+		target := *r.URL
+		target.Path = path.Clean(p)
+		target.RawPath = ""
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, target.RequestURI(), code)
+	}
+}
+
+// streamingPaths are the paths of the routes marked with streams.
+var streamingPaths = map[string]bool{}
+
+// streams marks route as streaming its response, so withHandlerTimeout
+// passes it through. Its path must not have variables. Every route that
+// flushes, stays open or is hijacked needs it.
+func streams(route *mux.Route) *mux.Route {
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		panic(err)
+	}
+	streamingPaths[tmpl] = true
+	return route
+}
+
+// withHandlerTimeout cuts off requests that run longer than handlerTimeout
+// with a branded 503 and cancels their context. http.TimeoutHandler buffers
+// the whole response, and the writer it gives handlers is neither an
+// http.Flusher nor unwrappable, so flushing there silently does nothing.
+// Routes marked with streams are therefore passed through untouched, as is
+// everything when handlerTimeout is zero.
+func withHandlerTimeout(next http.Handler) http.Handler {
+	if handlerTimeout <= 0 {
+		return next
+	}
+	bounded := http.TimeoutHandler(next, handlerTimeout, timeoutPage())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streamingPaths[path.Clean(r.URL.Path)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		bounded.ServeHTTP(w, r)
+	})
+}
+
+// securityHeaders sets the Content-Security-Policy and other security headers
+// on every response. It passes everything through untouched when
+// securityHeadersEnabled is off, and leaves out the CSP when
+// contentSecurityPolicy is empty.
+func securityHeaders(next http.Handler) http.Handler {
+	if !securityHeadersEnabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if contentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", contentSecurityPolicy)
+		}
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// methodNotAllowedHandler answers requests to routes whose path matches but
+// whose method doesn't, listing the methods that would match in an Allow
+// header, and logs the attempt so probing stands out. Repeats from one
+// session are sampled per methodNotAllowedLogInterval.
+func (fe *frontendServer) methodNotAllowedHandler(routes *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+		if l, ok := fe.methodNotAllowedLogs.sampleEvery(log, sessionID(r), methodNotAllowedLogInterval); ok {
+			l.WithFields(logrus.Fields{
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}).Warn("method not allowed")
+		}
+		w.Header().Set("Allow", strings.Join(allowedMethods(routes, r), ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	})
+}
+
+// allowedMethods returns the methods, in order, that a route in routes
+// would accept r with.
+func allowedMethods(routes *mux.Router, r *http.Request) []string {
+	var methods []string
+	routes.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		ms, _ := route.GetMethods()
+		methods = append(methods, ms...)
+		return nil
+	})
+	slices.Sort(methods)
+	methods = slices.Compact(methods)
+
+	var allowed []string
+	for _, m := range methods {
+		var match mux.RouteMatch
+		req := r.Clone(r.Context())
+		req.Method = m
+		if routes.Match(req, &match) && match.MatchErr == nil {
+			allowed = append(allowed, m)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,220 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		wantCode     int
+		wantLocation string
+	}{
+		{"recipes redirects", http.MethodGet, "/recipes/", http.StatusMovedPermanently, "/recipes"},
+		{"query is preserved", http.MethodGet, "/recipes/?page=2", http.StatusMovedPermanently, "/recipes?page=2"},
+		{"post keeps method", http.MethodPost, "/cart/", http.StatusPermanentRedirect, "/cart"},
+		{"no protocol-relative redirect", http.MethodGet, "//evil.example/", http.StatusMovedPermanently, "/evil.example"},
+		{"canonical path untouched", http.MethodGet, "/recipes", http.StatusOK, ""},
+		{"root untouched", http.MethodGet, "/", http.StatusOK, ""},
+		{"static prefix untouched", http.MethodGet, "/static/styles/", http.StatusOK, ""},
+		{"sse endpoint untouched", http.MethodGet, "/cart/updates/", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			rr := httptest.NewRecorder()
+			redirectTrailingSlash(next).ServeHTTP(rr, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("%s %s: got status %d, want %d", tt.method, tt.target, rr.Code, tt.wantCode)
+			}
+			if got := rr.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("%s %s: got Location %q, want %q", tt.method, tt.target, got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestWithHandlerTimeout(t *testing.T) {
+	defer func(old time.Duration) { handlerTimeout = old }(handlerTimeout)
+	handlerTimeout = 50 * time.Millisecond
+	defer func(old map[string]bool) { streamingPaths = old }(streamingPaths)
+	streamingPaths = map[string]bool{}
+	routes := mux.NewRouter()
+	streams(routes.Path("/cart/updates"))
+	streams(routes.Path("/cart/ws"))
+
+	cancelled := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/cart/") {
+			// Streams outlive the timeout and need to flush.
+			time.Sleep(3 * handlerTimeout)
+			w.(http.Flusher).Flush()
+			w.Write([]byte("data: {}\n\n"))
+			return
+		}
+		<-r.Context().Done()
+		close(cancelled)
+	})
+	handler := withHandlerTimeout(slow)
+
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rr, newTestRequest(http.MethodGet, "/recipes", nil))
+	if elapsed := time.Since(start); elapsed > 20*handlerTimeout {
+		t.Errorf("slow request took %v, want it cut off after about %v", elapsed, handlerTimeout)
+	}
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	if !strings.Contains(rr.Body.String(), "Uh, oh!") {
+		t.Error("timeout response is not the branded error page")
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("timed out request's context was not cancelled")
+	}
+
+	for _, target := range []string{"/cart/updates", "/cart/ws"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newTestRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusOK || !rr.Flushed {
+			t.Errorf("%s: got status %d (flushed %v), want an unbounded 200 stream", target, rr.Code, rr.Flushed)
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	defer func(enabled bool, csp string) {
+		securityHeadersEnabled, contentSecurityPolicy = enabled, csp
+	}(securityHeadersEnabled, contentSecurityPolicy)
+
+	fe, backends := newTestFrontend(t)
+	backends.recipe.recipes = []*pb.Recipe{{RecipeId: "r1", Title: "First Recipe"}}
+	get := func() http.Header {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		securityHeaders(http.HandlerFunc(fe.recipesHandler)).ServeHTTP(rr, newTestRequest(http.MethodGet, "/recipes", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+		}
+		return rr.Header()
+	}
+
+	securityHeadersEnabled = true
+	h := get()
+	for name, want := range map[string]string{
+		"Content-Security-Policy": contentSecurityPolicy,
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "strict-origin-when-cross-origin",
+	} {
+		if got := h.Get(name); got != want {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+	if csp := h.Get("Content-Security-Policy"); !strings.Contains(csp, "connect-src 'self'") || !strings.Contains(csp, "img-src 'self' data:") {
+		t.Errorf("default CSP %q blocks cart updates or recipe images", csp)
+	}
+
+	contentSecurityPolicy = "default-src 'self'"
+	if got := get().Get("Content-Security-Policy"); got != "default-src 'self'" {
+		t.Errorf("got CSP %q, want the configured one", got)
+	}
+
+	contentSecurityPolicy = ""
+	if h := get(); h.Get("Content-Security-Policy") != "" || h.Get("X-Frame-Options") != "DENY" {
+		t.Errorf("with an empty CSP got headers %v, want only the CSP left out", h)
+	}
+
+	securityHeadersEnabled = false
+	if h := get(); h.Get("X-Content-Type-Options") != "" {
+		t.Errorf("with security headers off got headers %v", h)
+	}
+}
+
+func TestMethodNotAllowedSetsAllow(t *testing.T) {
+	fe, _ := newTestFrontend(t)
+	r := mux.NewRouter()
+	r.HandleFunc("/cart", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodGet)
+	r.HandleFunc("/cart", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodPost)
+	r.HandleFunc("/cart/empty", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodPost)
+	r.HandleFunc("/bot", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodPut)
+	r.MethodNotAllowedHandler = fe.methodNotAllowedHandler(r)
+
+	for path, want := range map[string]string{
+		"/cart":       "GET, POST",
+		"/cart/empty": "POST",
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, newTestRequest(http.MethodDelete, path, nil))
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("DELETE %s: got status %d, want %d", path, rr.Code, http.StatusMethodNotAllowed)
+		}
+		if got := rr.Header().Get("Allow"); got != want {
+			t.Errorf("DELETE %s: got Allow %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestMethodNotAllowedIsLogged(t *testing.T) {
+	defer func(old time.Duration) { methodNotAllowedLogInterval = old }(methodNotAllowedLogInterval)
+	methodNotAllowedLogInterval = time.Minute
+
+	fe, _ := newTestFrontend(t)
+	r := mux.NewRouter()
+	r.HandleFunc("/cart/checkout", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodPost)
+	r.MethodNotAllowedHandler = fe.methodNotAllowedHandler(r)
+	logger, hook := test.NewNullLogger()
+	h := &logHandler{log: logger, next: r}
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, newTestRequest(http.MethodDelete, "/cart/checkout", nil))
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("got status %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+		}
+	}
+
+	var logged []*logrus.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Message == "method not allowed" {
+			logged = append(logged, e)
+		}
+	}
+	if len(logged) != 1 {
+		t.Fatalf("logged %d method-not-allowed entries for repeated requests, want 1", len(logged))
+	}
+	if e := logged[0]; e.Level != logrus.WarnLevel || e.Data["method"] != http.MethodDelete || e.Data["path"] != "/cart/checkout" {
+		t.Errorf("got entry %v %v, want a warning with the method and path", e.Level, e.Data)
+	}
+}
@@ -16,6 +16,7 @@ package money
 
 import (
 	"errors"
+	"math/big"
 
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
 )
@@ -130,3 +131,55 @@ func MultiplySlow(m pb.Money, n uint32) pb.Money {
 	}
 	return out
 }
+
+// Convert multiplies m by rate, the value of one unit of m's currency in
+// rate's currency, and returns the result in rate's currency rounded to the
+// nearest nano (halves away from zero).
+func Convert(m pb.Money, rate pb.Money) pb.Money {
+	n := new(big.Int).Mul(nanoValue(m), nanoValue(rate))
+	sign := n.Sign()
+
+	// n is in nanos of nanos; bring it back to nanos.
+	q, rem := n.QuoRem(n, big.NewInt(nanosMod), new(big.Int))
+	if rem.Abs(rem).Cmp(big.NewInt(nanosMod/2)) >= 0 {
+		q.Add(q, big.NewInt(int64(sign)))
+	}
+	units, nanos := q.QuoRem(q, big.NewInt(nanosMod), new(big.Int))
+	return pb.Money{
+		Units:        units.Int64(),
+		Nanos:        int32(nanos.Int64()),
+		CurrencyCode: rate.GetCurrencyCode()}
+}
+
+// Round rounds m to the given number of decimal places, from 0 to 9, halves
+// away from zero.
+func Round(m pb.Money, digits int) pb.Money {
+	if digits < 0 {
+		digits = 0
+	}
+	if digits >= 9 {
+		return m
+	}
+	step := big.NewInt(1)
+	for i := digits; i < 9; i++ {
+		step.Mul(step, big.NewInt(10))
+	}
+	n := nanoValue(m)
+	sign := n.Sign()
+
+	q, rem := n.QuoRem(n, step, new(big.Int))
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(step) >= 0 {
+		q.Add(q, big.NewInt(int64(sign)))
+	}
+	q.Mul(q, step)
+	units, nanos := q.QuoRem(q, big.NewInt(nanosMod), new(big.Int))
+	return pb.Money{
+		Units:        units.Int64(),
+		Nanos:        int32(nanos.Int64()),
+		CurrencyCode: m.GetCurrencyCode()}
+}
+
+func nanoValue(m pb.Money) *big.Int {
+	v := new(big.Int).Mul(big.NewInt(m.GetUnits()), big.NewInt(nanosMod))
+	return v.Add(v, big.NewInt(int64(m.GetNanos())))
+}
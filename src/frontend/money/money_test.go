@@ -243,3 +243,54 @@ func TestSum(t *testing.T) {
 		})
 	}
 }
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name string
+		m    pb.Money
+		rate pb.Money
+		want pb.Money
+	}{
+		{"zero", mmc(0, 0, "USD"), mmc(0, 900000000, "EUR"), mmc(0, 0, "EUR")},
+		{"identity", mmc(19, 990000000, "USD"), mmc(1, 0, "USD"), mmc(19, 990000000, "USD")},
+		{"fractional rate", mmc(3, 0, "USD"), mmc(0, 900000000, "EUR"), mmc(2, 700000000, "EUR")},
+		{"carry into units", mmc(19, 990000000, "USD"), mmc(148, 500000000, "JPY"), mmc(2968, 515000000, "JPY")},
+		{"rounds half up", mmc(0, 1, "USD"), mmc(0, 500000000, "EUR"), mmc(0, 1, "EUR")},
+		{"rounds down", mmc(0, 1, "USD"), mmc(0, 499999999, "EUR"), mmc(0, 0, "EUR")},
+		{"negative", mmc(-3, 0, "USD"), mmc(0, 900000000, "EUR"), mmc(-2, -700000000, "EUR")},
+		{"negative rounds away from zero", mmc(0, -1, "USD"), mmc(0, 500000000, "EUR"), mmc(0, -1, "EUR")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Convert(tt.m, tt.rate); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Convert([%v],[%v]) = %v, want %v", tt.m, tt.rate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		name   string
+		m      pb.Money
+		digits int
+		want   pb.Money
+	}{
+		{"already at precision", mmc(19, 990000000, "USD"), 2, mmc(19, 990000000, "USD")},
+		{"rounds up at the cent boundary", mmc(19, 995000000, "USD"), 2, mmc(20, 0, "USD")},
+		{"rounds down below the cent boundary", mmc(19, 994999999, "USD"), 2, mmc(19, 990000000, "USD")},
+		{"rounds up above half a cent", mmc(0, 5500000, "USD"), 2, mmc(0, 10000000, "USD")},
+		{"whole units", mmc(2968, 500000000, "JPY"), 0, mmc(2969, 0, "JPY")},
+		{"three decimals", mmc(1, 234500000, "BHD"), 3, mmc(1, 235000000, "BHD")},
+		{"full precision", mmc(0, 1, "USD"), 9, mmc(0, 1, "USD")},
+		{"negative rounds away from zero", mmc(-1, -995000000, "USD"), 2, mmc(-2, 0, "USD")},
+		{"negative rounds toward zero", mmc(0, -4999999, "USD"), 2, mmc(0, 0, "USD")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Round(tt.m, tt.digits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Round([%v], %d) = %v, want %v", tt.m, tt.digits, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// maxOrdersPerSession caps the history kept for a single session; the oldest
+// orders are dropped first.
+const maxOrdersPerSession = 50
+
+// orderRecord is a placed order as shown in the order history.
+type orderRecord struct {
+	ID       string            `json:"id"`
+	Items    []orderRecordItem `json:"items"`
+	Total    *pb.Money         `json:"total"`
+	PlacedAt time.Time         `json:"placed_at"`
+}
+
+type orderRecordItem struct {
+	ProductID string    `json:"product_id"`
+	Quantity  int32     `json:"quantity"`
+	Cost      *pb.Money `json:"cost"`
+}
+
+func newOrderRecord(order *pb.OrderResult, total *pb.Money, placedAt time.Time) orderRecord {
+	rec := orderRecord{
+		ID:       order.GetOrderId(),
+		Total:    total,
+		PlacedAt: placedAt,
+	}
+	for _, it := range order.GetItems() {
+		rec.Items = append(rec.Items, orderRecordItem{
+			ProductID: it.GetItem().GetProductId(),
+			Quantity:  it.GetItem().GetQuantity(),
+			Cost:      it.GetCost(),
+		})
+	}
+	return rec
+}
+
+// orderHistory keeps the orders placed in each session in memory. Sessions
+// with no new orders for orderHistoryTTL are evicted when the next order is
+// recorded. The zero value is ready to use.
+type orderHistory struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionOrders
+}
+
+type sessionOrders struct {
+	orders   []orderRecord
+	lastSeen time.Time
+}
+
+func (h *orderHistory) add(sessionID string, rec orderRecord, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sessions == nil {
+		h.sessions = make(map[string]*sessionOrders)
+	}
+	for id, s := range h.sessions {
+		if now.Sub(s.lastSeen) > orderHistoryTTL {
+			delete(h.sessions, id)
+		}
+	}
+
+	s, ok := h.sessions[sessionID]
+	if !ok {
+		s = &sessionOrders{}
+		h.sessions[sessionID] = s
+	}
+	s.orders = append(s.orders, rec)
+	if len(s.orders) > maxOrdersPerSession {
+		s.orders = s.orders[len(s.orders)-maxOrdersPerSession:]
+	}
+	s.lastSeen = now
+}
+
+// list returns the session's orders, newest first.
+func (h *orderHistory) list(sessionID string) []orderRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	out := make([]orderRecord, len(s.orders))
+	for i, rec := range s.orders {
+		out[len(out)-1-i] = rec
+	}
+	return out
+}
+
+func (fe *frontendServer) ordersHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	orders := fe.orderHistory.list(sessionID(r))
+	log.WithField("orders", len(orders)).Debug("listing order history")
+
+	currencies, err := fe.getCurrencies(r.Context())
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
+		return
+	}
+
+	if err := executeTemplate(w, "orders", injectCommonTemplateData(r, map[string]interface{}{
+		"show_currency": false,
+		"currencies":    currencies,
+		"orders":        orders,
+	})); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
+	}
+}
+
+func (fe *frontendServer) ordersAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	orders := fe.orderHistory.list(sessionID(r))
+	if orders == nil {
+		orders = []orderRecord{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"orders": orders}); err != nil {
+		log.WithError(err).Error("failed to encode order history")
+	}
+}
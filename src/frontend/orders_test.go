@@ -0,0 +1,284 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// checkoutForm is a valid checkout form submission.
+func checkoutForm() url.Values {
+	return url.Values{
+		"email":                        {"someone@example.com"},
+		"street_address":               {"1600 Amphitheatre Parkway"},
+		"zip_code":                     {"94043"},
+		"city":                         {"Mountain View"},
+		"state":                        {"CA"},
+		"country":                      {"United States"},
+		"credit_card_number":           {"4432801561520454"},
+		"credit_card_expiration_month": {"1"},
+		"credit_card_expiration_year":  {"2039"},
+		"credit_card_cvv":              {"672"},
+	}
+}
+
+func placeTestOrder(t *testing.T, fe *frontendServer) {
+	t.Helper()
+	form := checkoutForm()
+	req := newTestRequest(http.MethodPost, "/cart/checkout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	fe.placeOrderHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("placing order: got status %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestPlacedOrderAppearsInHistory(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.checkout.order = &pb.OrderResult{
+		OrderId:      "order-42",
+		ShippingCost: usd(5, 0),
+		Items: []*pb.OrderItem{
+			{Item: &pb.CartItem{ProductId: "OLJCESPC7Z", Quantity: 2}, Cost: usd(10, 0)},
+		},
+	}
+	placeTestOrder(t, fe)
+
+	rr := httptest.NewRecorder()
+	fe.ordersAPIHandler(rr, newTestRequest(http.MethodGet, "/api/orders", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+	}
+	var resp struct {
+		Orders []orderRecord `json:"orders"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Orders) != 1 {
+		t.Fatalf("got %d orders, want 1", len(resp.Orders))
+	}
+	got := resp.Orders[0]
+	if got.ID != "order-42" {
+		t.Errorf("got order ID %q, want %q", got.ID, "order-42")
+	}
+	if len(got.Items) != 1 || got.Items[0].ProductID != "OLJCESPC7Z" || got.Items[0].Quantity != 2 {
+		t.Errorf("got items %+v, want 2x OLJCESPC7Z", got.Items)
+	}
+	if got.Total.GetUnits() != 25 {
+		t.Errorf("got total %d units, want 25", got.Total.GetUnits())
+	}
+	if got.PlacedAt.IsZero() {
+		t.Error("order has no timestamp")
+	}
+
+	rr = httptest.NewRecorder()
+	fe.ordersHandler(rr, newTestRequest(http.MethodGet, "/orders", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), "order-42") {
+		t.Error("order history page does not list order-42")
+	}
+}
+
+func TestOrderHistoryEvictsIdleSessions(t *testing.T) {
+	var h orderHistory
+	start := time.Now()
+	h.add("old", orderRecord{ID: "a"}, start)
+	h.add("new", orderRecord{ID: "b"}, start.Add(orderHistoryTTL+time.Minute))
+
+	if got := h.list("old"); got != nil {
+		t.Errorf("idle session still has orders %v", got)
+	}
+	if got := h.list("new"); len(got) != 1 {
+		t.Errorf("got %d orders for active session, want 1", len(got))
+	}
+}
+
+func TestPlaceOrderRejectsMalformedCard(t *testing.T) {
+	tests := []struct {
+		field, value string
+	}{
+		{"credit_card_expiration_month", "jan"},
+		{"credit_card_expiration_year", "soon"},
+		{"credit_card_cvv", "abc"},
+		{"credit_card_number", "4432801561520455"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			form := checkoutForm()
+			form.Set(tt.field, tt.value)
+			req := newTestRequest(http.MethodPost, "/cart/checkout", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+			fe.placeOrderHandler(rr, req)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("got status %d, want %d", rr.Code, http.StatusUnprocessableEntity)
+			}
+			if n := backends.checkout.callCount(); n != 0 {
+				t.Errorf("made %d PlaceOrder calls, want 0", n)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderForwardsParsedCard(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	placeTestOrder(t, fe)
+
+	backends.checkout.mu.Lock()
+	defer backends.checkout.mu.Unlock()
+	if len(backends.checkout.requests) != 1 {
+		t.Fatalf("got %d PlaceOrder calls, want 1", len(backends.checkout.requests))
+	}
+	card := backends.checkout.requests[0].GetCreditCard()
+	if card.GetCreditCardNumber() != "4432801561520454" || card.GetCreditCardExpirationMonth() != 1 ||
+		card.GetCreditCardExpirationYear() != 2039 || card.GetCreditCardCvv() != 672 {
+		t.Errorf("forwarded card %v, want the submitted one", card)
+	}
+}
+
+func TestPlaceOrderDryRun(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta", PriceUsd: usd(3, 500000000)})
+	backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 2})
+
+	form := checkoutForm()
+	form.Set("dry_run", "true")
+	req := newTestRequest(http.MethodPost, "/cart/checkout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	fe.placeOrderHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if n := backends.checkout.callCount(); n != 0 {
+		t.Errorf("dry run made %d PlaceOrder calls, want 0", n)
+	}
+	var summary struct {
+		DryRun   bool      `json:"dry_run"`
+		Shipping *pb.Money `json:"shipping_cost"`
+		Total    *pb.Money `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decoding summary: %v", err)
+	}
+	if !summary.DryRun {
+		t.Error("summary is not marked as a dry run")
+	}
+	// 2 x 3.50 plus the fake's 8.99 shipping.
+	if summary.Total.GetUnits() != 15 || summary.Total.GetNanos() != 990000000 {
+		t.Errorf("got total %v, want 15.99", summary.Total)
+	}
+	if got := fe.orderHistory.list(testSessionID); len(got) != 0 {
+		t.Errorf("dry run was recorded in the order history: %v", got)
+	}
+}
+
+func TestPlaceOrderDryRunHeader(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+
+	req := newTestRequest(http.MethodPost, "/cart/checkout", strings.NewReader(checkoutForm().Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Dry-Run", "1")
+	rr := httptest.NewRecorder()
+	fe.placeOrderHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+	}
+	if n := backends.checkout.callCount(); n != 0 {
+		t.Errorf("dry run made %d PlaceOrder calls, want 0", n)
+	}
+}
+
+func TestPlaceOrderDryRunReportsUnavailableProducts(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(
+		&pb.Product{Id: "P1", Name: "Pasta", PriceUsd: usd(3, 0)},
+		&pb.Product{Id: "P2", Name: "Pesto", PriceUsd: usd(5, 0)},
+	)
+	backends.cart.setCart(testSessionID,
+		&pb.CartItem{ProductId: "P1", Quantity: 2},
+		&pb.CartItem{ProductId: "GONE", Quantity: 1},
+		&pb.CartItem{ProductId: "P2", Quantity: 1})
+	backends.currency.rates["EUR"] = 2
+
+	form := checkoutForm()
+	form.Set("dry_run", "true")
+	req := newTestRequest(http.MethodPost, "/cart/checkout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: cookieCurrency, Value: "EUR"})
+	rr := httptest.NewRecorder()
+	fe.placeOrderHandler(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusUnprocessableEntity, rr.Body)
+	}
+	var summary struct {
+		Items []struct {
+			ProductID string    `json:"product_id"`
+			Cost      *pb.Money `json:"cost"`
+		} `json:"items"`
+		Unavailable []string `json:"unavailable_products"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decoding summary: %v", err)
+	}
+	if len(summary.Unavailable) != 1 || summary.Unavailable[0] != "GONE" {
+		t.Errorf("got unavailable products %q, want [GONE]", summary.Unavailable)
+	}
+	if len(summary.Items) != 2 || summary.Items[1].ProductID != "P2" || summary.Items[1].Cost.GetUnits() != 10 {
+		t.Errorf("got items %+v, want P1 and P2 with P2 at 10 EUR", summary.Items)
+	}
+	// One conversion for both USD prices and one for shipping.
+	if n := backends.currency.convertCallCount(); n != 2 {
+		t.Errorf("made %d currency conversion calls, want 2", n)
+	}
+}
+
+func TestPlaceOrderDryRunJSONError(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.cart.getErr = errors.New("cart is down")
+
+	form := checkoutForm()
+	form.Set("dry_run", "true")
+	req := newTestRequest(http.MethodPost, "/cart/checkout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	fe.placeOrderHandler(rr, req)
+
+	var body struct {
+		StatusCode int `json:"status_code"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got %d %q, want a JSON 500 error", rr.Code, rr.Body)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const defaultPageSize = 20
+
+// pagination is a validated page/page_size pair. Page is 1-based.
+type pagination struct {
+	Page     int
+	PageSize int
+}
+
+// parsePagination reads the page and page_size query parameters. Missing or
+// unparseable values fall back to the defaults; page is clamped to >= 1 and
+// page_size to 1..maxPageSize.
+func parsePagination(r *http.Request) pagination {
+	p := pagination{Page: 1, PageSize: defaultPageSize}
+	if n, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil {
+		p.Page = n
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("page_size")); err == nil {
+		p.PageSize = n
+	}
+
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.PageSize > maxPageSize {
+		p.PageSize = maxPageSize
+	}
+	if p.PageSize < 1 {
+		p.PageSize = 1
+	}
+	return p
+}
+
+// bounds returns the [start, end) slice indexes of the current page within a
+// list of n items. Pages past the end yield an empty range.
+func (p pagination) bounds(n int) (start, end int) {
+	// Checked before multiplying, which a huge page would overflow.
+	if p.Page-1 > n/p.PageSize {
+		return n, n
+	}
+	start = (p.Page - 1) * p.PageSize
+	if start > n {
+		start = n
+	}
+	end = start + p.PageSize
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+// templateData describes the page for templates rendering a list of n items.
+func (p pagination) templateData(n int) map[string]interface{} {
+	_, end := p.bounds(n)
+	return map[string]interface{}{
+		"page":      p.Page,
+		"page_size": p.PageSize,
+		"has_prev":  p.Page > 1,
+		"has_next":  end < n,
+		"prev_page": p.Page - 1,
+		"next_page": p.Page + 1,
+	}
+}
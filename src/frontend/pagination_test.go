@@ -0,0 +1,72 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  pagination
+	}{
+		{"defaults", "", pagination{Page: 1, PageSize: defaultPageSize}},
+		{"explicit values", "?page=3&page_size=5", pagination{Page: 3, PageSize: 5}},
+		{"page clamped to 1", "?page=-2", pagination{Page: 1, PageSize: defaultPageSize}},
+		{"zero page size clamped to 1", "?page_size=0", pagination{Page: 1, PageSize: 1}},
+		{"page size clamped to max", "?page_size=100000", pagination{Page: 1, PageSize: maxPageSize}},
+		{"non-numeric page", "?page=abc&page_size=10", pagination{Page: 1, PageSize: 10}},
+		{"non-numeric page size", "?page=2&page_size=lots", pagination{Page: 2, PageSize: defaultPageSize}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePagination(httptest.NewRequest("GET", "/recipes"+tt.query, nil)); got != tt.want {
+				t.Errorf("parsePagination(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePaginationConfiguredMax(t *testing.T) {
+	defer func(old int) { maxPageSize = old }(maxPageSize)
+	maxPageSize = 7
+
+	if got := parsePagination(httptest.NewRequest("GET", "/recipes?page_size=50", nil)); got.PageSize != 7 {
+		t.Errorf("got page size %d, want the configured max 7", got.PageSize)
+	}
+}
+
+func TestPaginationBounds(t *testing.T) {
+	tests := []struct {
+		p                     pagination
+		n, wantStart, wantEnd int
+	}{
+		{pagination{Page: 1, PageSize: 10}, 25, 0, 10},
+		{pagination{Page: 3, PageSize: 10}, 25, 20, 25},
+		{pagination{Page: 4, PageSize: 10}, 25, 25, 25},
+		{pagination{Page: 1, PageSize: 10}, 0, 0, 0},
+		{pagination{Page: math.MaxInt/3 + 1, PageSize: 3}, 25, 25, 25},
+		{pagination{Page: math.MaxInt, PageSize: maxPageSize}, 25, 25, 25},
+	}
+	for _, tt := range tests {
+		if start, end := tt.p.bounds(tt.n); start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("%+v.bounds(%d) = [%d, %d), want [%d, %d)", tt.p, tt.n, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
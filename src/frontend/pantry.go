@@ -0,0 +1,55 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// isPantryIngredient reports whether name is a staple listed in
+// pantryIngredients. Terms match whole words, so "salt" matches "sea salt"
+// but "ice" doesn't match "rice".
+func isPantryIngredient(name string) bool {
+	normalized := " " + pantryWords(name) + " "
+	for _, term := range pantryIngredients {
+		if strings.Contains(normalized, " "+term+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPantryIngredients separates pantry staples from the ingredients worth
+// buying, keeping their order.
+func splitPantryIngredients(names []string) (buy, pantry []string) {
+	for _, name := range names {
+		if isPantryIngredient(name) {
+			pantry = append(pantry, name)
+		} else {
+			buy = append(buy, name)
+		}
+	}
+	return buy, pantry
+}
+
+// pantryWords lowercases s and reduces it to words separated by single
+// spaces, so names and terms compare regardless of punctuation.
+func pantryWords(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	return strings.Join(words, " ")
+}
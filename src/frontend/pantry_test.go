@@ -0,0 +1,122 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestIsPantryIngredient(t *testing.T) {
+	defer func(old []string) { pantryIngredients = old }(pantryIngredients)
+	pantryIngredients = parsePantryIngredients("salt, Black  Pepper,water")
+
+	for name, want := range map[string]bool{
+		"Salt":                        true,
+		"sea salt":                    true,
+		"freshly ground black pepper": true,
+		"Water (warm)":                true,
+		"Red bell pepper":             false,
+		"Saltine crackers":            false,
+		"Watermelon":                  false,
+	} {
+		if got := isPantryIngredient(name); got != want {
+			t.Errorf("isPantryIngredient(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	pantryIngredients = parsePantryIngredients("")
+	if isPantryIngredient("salt") {
+		t.Error("salt is a pantry ingredient with an empty list")
+	}
+}
+
+func TestAddToCartHandlersSkipPantryIngredients(t *testing.T) {
+	defer func(old []string) { pantryIngredients = old }(pantryIngredients)
+	pantryIngredients = []string{"salt", "water"}
+
+	tests := []struct {
+		name     string
+		handler  func(*frontendServer) http.HandlerFunc
+		target   string
+		list     string
+		forward  string
+		location string
+	}{
+		{"recipe", func(fe *frontendServer) http.HandlerFunc { return fe.addRecipeToCartHandler },
+			"/recipe/r1/add-to-cart", "Pasta, Salt, Water", "Pasta", "/recipe/r1?added=true&skipped=Salt&skipped=Water"},
+		{"suggested recipe", func(fe *frontendServer) http.HandlerFunc { return fe.addSuggestedRecipeToCartHandler },
+			"/suggested-recipe/r1/add-to-cart", "Pasta, Salt, Water", "Pasta", "/suggested-recipe/r1?added=true&skipped=Salt&skipped=Water"},
+		{"only pantry", func(fe *frontendServer) http.HandlerFunc { return fe.addRecipeToCartHandler },
+			"/recipe/r1/add-to-cart", "Salt", "", "/recipe/r1?skipped=Salt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			backends.recipe.recipes = []*pb.Recipe{{
+				RecipeId:    "r1",
+				Ingredients: []*pb.Ingredient{{Name: "Pasta"}, {Name: "Salt"}, {Name: "Water"}},
+			}}
+			fe.suggestedRecipesCache.Store(testSessionID, []CachedRecipe{{RecipeId: "r1"}})
+
+			form := url.Values{"ingredient_list": {tt.list}}
+			req := newTestRequest(http.MethodPost, tt.target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = mux.SetURLVars(req, map[string]string{"id": "r1"})
+			rr := httptest.NewRecorder()
+			tt.handler(fe).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusFound {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusFound, rr.Body)
+			}
+			if got := rr.Header().Get("Location"); got != tt.location {
+				t.Errorf("redirected to %q, want %q", got, tt.location)
+			}
+			calls := backends.recipe.processRequests()
+			if tt.forward == "" {
+				if len(calls) != 0 {
+					t.Errorf("forwarded %q, want nothing", calls[0].GetMessage())
+				}
+				return
+			}
+			if len(calls) != 1 || !strings.HasSuffix(calls[0].GetMessage(), ": "+tt.forward) {
+				t.Errorf("got recipe service calls %v, want one ending in %q", calls, tt.forward)
+			}
+		})
+	}
+}
+
+func TestRecipeDetailShowsSkippedPantryIngredients(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.recipe.recipes = []*pb.Recipe{{RecipeId: "r1", Ingredients: []*pb.Ingredient{{Name: "Salt"}}}}
+
+	req := mux.SetURLVars(newTestRequest(http.MethodGet, "/recipe/r1?skipped=Salt&skipped=Water", nil), map[string]string{"id": "r1"})
+	rr := httptest.NewRecorder()
+	fe.recipeDetailHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if !strings.Contains(rr.Body.String(), "Salt, Water") {
+		t.Error("recipe page does not list the skipped pantry ingredients")
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// productsStreamFlushEvery is how many products productsAPIHandler writes
+// between flushes.
+const productsStreamFlushEvery = 100
+
+// productView is a catalog product as returned by productsAPIHandler.
+type productView struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Picture     string    `json:"picture"`
+	Categories  []string  `json:"categories"`
+	Price       *pb.Money `json:"price"`
+}
+
+// productsAPIHandler streams the catalog as a JSON array with prices in the
+// user's currency. Products are encoded one at a time and flushed as they go,
+// so large catalogs are never held in the response buffer. Once the array has
+// started the status can't change, so a failure or client disconnect part way
+// through ends the stream early and leaves the array unterminated.
+func (fe *frontendServer) productsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	ctx := r.Context()
+	currency := currentCurrency(r)
+
+	products, err := fe.getProducts(ctx)
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "could not retrieve products"), http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	started := false
+	for i, p := range products {
+		if err := ctx.Err(); err != nil {
+			log.WithField("written", i).Debug("client went away while streaming products")
+			return
+		}
+		// convertPrices caches rates, so this is one currency call per
+		// stream rather than one per product.
+		prices, err := fe.convertPrices(ctx, []*pb.Money{p.GetPriceUsd()}, currency)
+		if err != nil {
+			if !started {
+				renderAPIError(log, r, w, errors.Wrap(err, "failed to convert currency"), http.StatusInternalServerError)
+				return
+			}
+			log.WithError(err).WithField("product_id", p.GetId()).Error("failed to convert product price mid-stream")
+			return
+		}
+
+		sep := ","
+		if !started {
+			w.Header().Set("Content-Type", "application/json")
+			sep = "["
+			started = true
+		}
+		if _, err := w.Write([]byte(sep)); err != nil {
+			log.WithError(err).WithField("written", i).Debug("stopped streaming products")
+			return
+		}
+		if err := enc.Encode(productView{
+			ID:          p.GetId(),
+			Name:        p.GetName(),
+			Description: p.GetDescription(),
+			Picture:     p.GetPicture(),
+			Categories:  p.GetCategories(),
+			Price:       prices[0],
+		}); err != nil {
+			log.WithError(err).WithField("written", i).Debug("stopped streaming products")
+			return
+		}
+		if (i+1)%productsStreamFlushEvery == 0 {
+			rc.Flush()
+		}
+	}
+
+	if !started {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("["))
+	}
+	w.Write([]byte("]\n"))
+	rc.Flush()
+}
+
+// productDetailView is a product as returned by productAPIHandler, with its
+// original USD price alongside the converted one.
+type productDetailView struct {
+	productView
+	PriceUSD *pb.Money `json:"price_usd"`
+}
+
+// productAPIHandler returns one product as JSON with its price in the user's
+// currency.
+func (fe *frontendServer) productAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	id := mux.Vars(r)["id"]
+
+	p, err := fe.getProduct(r.Context(), id)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if status.Code(err) == codes.NotFound {
+			code = http.StatusNotFound
+		}
+		renderAPIError(log, r, w, errors.Wrapf(err, "could not retrieve product %q", id), code)
+		return
+	}
+	price, err := fe.convertCurrency(r.Context(), p.GetPriceUsd(), currentCurrency(r))
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "failed to convert currency"), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(productDetailView{
+		productView: productView{
+			ID:          p.GetId(),
+			Name:        p.GetName(),
+			Description: p.GetDescription(),
+			Picture:     p.GetPicture(),
+			Categories:  p.GetCategories(),
+			Price:       price,
+		},
+		PriceUSD: p.GetPriceUsd(),
+	}); err != nil {
+		log.WithError(err).Error("failed to encode product")
+	}
+}
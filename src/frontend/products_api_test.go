@@ -0,0 +1,191 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestProductsAPIHandlerStreamsLargeCatalog(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.currency.rates["EUR"] = 0.5
+	const n = 2500
+	products := make([]*pb.Product, n)
+	for i := range products {
+		products[i] = &pb.Product{Id: fmt.Sprintf("P%d", i), Name: fmt.Sprintf("Product %d", i), PriceUsd: usd(int64(i+1), 0)}
+	}
+	backends.catalog.setProducts(products...)
+
+	req := newTestRequest(http.MethodGet, "/api/products", nil)
+	req.AddCookie(&http.Cookie{Name: cookieCurrency, Value: "EUR"})
+	rr := httptest.NewRecorder()
+	fe.productsAPIHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	var got []productView
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("streamed body is not valid JSON: %v", err)
+	}
+	if len(got) != n {
+		t.Fatalf("got %d products, want %d", len(got), n)
+	}
+	if last := got[n-1]; last.ID != "P2499" || last.Price.GetCurrencyCode() != "EUR" || last.Price.GetUnits() != 1250 {
+		t.Errorf("got last product %+v, want P2499 at 1250 EUR", last)
+	}
+	if !rr.Flushed {
+		t.Error("response was never flushed")
+	}
+	if calls := backends.currency.convertCallCount(); calls != 1 {
+		t.Errorf("made %d currency conversion calls, want 1", calls)
+	}
+}
+
+func TestProductsAPIStreamsThroughMiddleware(t *testing.T) {
+	defer func(old time.Duration) { handlerTimeout = old }(handlerTimeout)
+	handlerTimeout = time.Minute
+	defer func(old map[string]bool) { streamingPaths = old }(streamingPaths)
+	streamingPaths = map[string]bool{}
+
+	fe, backends := newTestFrontend(t)
+	products := make([]*pb.Product, 2*productsStreamFlushEvery)
+	for i := range products {
+		products[i] = &pb.Product{Id: fmt.Sprintf("P%d", i), PriceUsd: usd(1, 0)}
+	}
+	backends.catalog.setProducts(products...)
+	r := mux.NewRouter()
+	streams(r.HandleFunc("/api/products", fe.productsAPIHandler))
+
+	rr := httptest.NewRecorder()
+	withMiddleware(r, log).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/products", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if !rr.Flushed {
+		t.Error("response was never flushed: the timeout handler buffered it")
+	}
+	var got []productView
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || len(got) != len(products) {
+		t.Errorf("got %d products (%v), want %d", len(got), err, len(products))
+	}
+}
+
+func TestProductsAPIHandlerEmptyCatalog(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts()
+
+	rr := httptest.NewRecorder()
+	fe.productsAPIHandler(rr, newTestRequest(http.MethodGet, "/api/products", nil))
+
+	var got []productView
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("got %q (%v), want an empty array", rr.Body, err)
+	}
+}
+
+// disconnectingWriter cancels the request context once the first product
+// has been written, as if the client hung up mid-stream.
+type disconnectingWriter struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (w disconnectingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseRecorder.Write(b)
+	if b[0] == '{' {
+		w.cancel()
+	}
+	return n, err
+}
+
+func TestProductsAPIHandlerStopsWhenClientGoesAway(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "P1", PriceUsd: usd(1, 0)}, &pb.Product{Id: "P2", PriceUsd: usd(2, 0)})
+
+	req := newTestRequest(http.MethodGet, "/api/products", nil)
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	rr := httptest.NewRecorder()
+	fe.productsAPIHandler(disconnectingWriter{rr, cancel}, req.WithContext(ctx))
+
+	body := rr.Body.String()
+	if strings.Contains(body, "P2") || strings.HasSuffix(body, "]\n") {
+		t.Errorf("got %q, want the stream to stop after the first product", body)
+	}
+}
+
+func TestProductAPIHandler(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.currency.rates["EUR"] = 0.5
+	backends.catalog.setProducts(&pb.Product{Id: "P1", Name: "Pasta", PriceUsd: usd(4, 0)})
+
+	for _, tt := range []struct {
+		currency  string
+		wantUnits int64
+	}{
+		{"EUR", 2},
+		{"USD", 4},
+	} {
+		t.Run(tt.currency, func(t *testing.T) {
+			req := newTestRequest(http.MethodGet, "/api/product/P1", nil)
+			req.AddCookie(&http.Cookie{Name: cookieCurrency, Value: tt.currency})
+			req = mux.SetURLVars(req, map[string]string{"id": "P1"})
+			rr := httptest.NewRecorder()
+			fe.productAPIHandler(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			var got productDetailView
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != "P1" || got.Name != "Pasta" {
+				t.Errorf("got product %+v, want P1 Pasta", got)
+			}
+			if got.Price.GetCurrencyCode() != tt.currency || got.Price.GetUnits() != tt.wantUnits {
+				t.Errorf("got price %v, want %d %s", got.Price, tt.wantUnits, tt.currency)
+			}
+			if got.PriceUSD.GetCurrencyCode() != "USD" || got.PriceUSD.GetUnits() != 4 {
+				t.Errorf("got USD price %v, want 4 USD", got.PriceUSD)
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		req := newTestRequest(http.MethodGet, "/api/product/missing", nil)
+		req.Header.Set("Accept", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": "missing"})
+		rr := httptest.NewRecorder()
+		fe.productAPIHandler(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("got status %d, want %d", rr.Code, http.StatusNotFound)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// recipeExport is the session's suggested recipes as written by
+// exportSuggestedRecipesHandler.
+type recipeExport struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	Recipes    []exportedRecipe `json:"recipes"`
+}
+
+// exportedRecipe is a CachedRecipe with its image replaced by a URL, which
+// keeps exports small.
+type exportedRecipe struct {
+	RecipeId               string              `json:"recipe_id"`
+	StableID               string              `json:"stable_id"`
+	Title                  string              `json:"title"`
+	Description            string              `json:"description"`
+	CookTime               string              `json:"cook_time"`
+	DefaultServings        int32               `json:"default_servings"`
+	Ingredients            []*CachedIngredient `json:"ingredients"`
+	Instructions           []string            `json:"instructions"`
+	StructuredInstructions []InstructionStep   `json:"structured_instructions"`
+	CreatedAt              time.Time           `json:"created_at"`
+	ImageURL               string              `json:"image_url"`
+}
+
+// suggestedRecipeImageURL is where recipe's image is served, or the
+// placeholder if it has none.
+func suggestedRecipeImageURL(recipe CachedRecipe) string {
+	if recipe.ImageData == "" {
+		return baseUrl + recipePlaceholderImage
+	}
+	id := recipe.StableID
+	if id == "" {
+		id = recipe.RecipeId
+	}
+	return baseUrl + "/suggested-recipe/" + id + "/image"
+}
+
+// exportSuggestedRecipesHandler returns the session's suggested recipes as
+// JSON. With ?download=1 the response is an attachment, so browsers save it
+// as a file.
+func (fe *frontendServer) exportSuggestedRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	cached, ok := fe.suggestedRecipesCache.Load(sessionID(r))
+	if !ok {
+		renderAPIError(log, r, w, errors.New("no suggested recipes found for session"), http.StatusNotFound)
+		return
+	}
+	recipes, ok := cached.([]CachedRecipe)
+	if !ok {
+		renderAPIError(log, r, w, errors.New("invalid cached recipes format"), http.StatusInternalServerError)
+		return
+	}
+
+	export := recipeExport{ExportedAt: time.Now().UTC(), Recipes: make([]exportedRecipe, len(recipes))}
+	for i, recipe := range recipes {
+		export.Recipes[i] = exportedRecipe{
+			RecipeId:               recipe.RecipeId,
+			StableID:               recipe.StableID,
+			Title:                  recipe.Title,
+			Description:            recipe.Description,
+			CookTime:               recipe.CookTime,
+			DefaultServings:        recipe.DefaultServings,
+			Ingredients:            recipe.Ingredients,
+			Instructions:           recipe.Instructions,
+			StructuredInstructions: structureInstructions(recipe.Instructions),
+			CreatedAt:              recipe.CreatedAt,
+			ImageURL:               suggestedRecipeImageURL(recipe),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if download, _ := strconv.ParseBool(r.URL.Query().Get("download")); download {
+		w.Header().Set("Content-Disposition", `attachment; filename="suggested-recipes.json"`)
+	}
+	if err := json.NewEncoder(w).Encode(export); err != nil {
+		log.WithError(err).Error("failed to encode suggested recipe export")
+	}
+}
+
+// suggestedRecipeImageHandler serves a suggested recipe's generated image.
+func (fe *frontendServer) suggestedRecipeImageHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	id := mux.Vars(r)["id"]
+	var recipe *CachedRecipe
+	if cached, ok := fe.suggestedRecipesCache.Load(sessionID(r)); ok {
+		recipes, _ := cached.([]CachedRecipe)
+		recipe = findCachedRecipe(recipes, id)
+	}
+	if recipe == nil || recipe.ImageData == "" {
+		renderHTTPError(log, r, w, errors.Errorf("no image for suggested recipe %q", id), http.StatusNotFound)
+		return
+	}
+	img, err := base64.StdEncoding.DecodeString(recipe.ImageData)
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "invalid suggested recipe image"), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(img))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write(img)
+}
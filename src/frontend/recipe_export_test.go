@@ -0,0 +1,97 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestExportSuggestedRecipesHandler(t *testing.T) {
+	fe, _ := newTestFrontend(t)
+	png := []byte("\x89PNG\r\n\x1a\nrest of the image")
+	fe.suggestedRecipesCache.Store(testSessionID, []CachedRecipe{
+		{
+			RecipeId:     "r1",
+			StableID:     "sr-1",
+			Title:        "Pesto Pasta",
+			Ingredients:  []*CachedIngredient{{Name: "Pasta", Quantity: 200, Unit: "g"}},
+			Instructions: []string{"1. Boil the pasta for 10 minutes."},
+			ImageData:    base64.StdEncoding.EncodeToString(png),
+		},
+		{RecipeId: "r2", StableID: "sr-2", Title: "Toast"},
+	})
+
+	rr := httptest.NewRecorder()
+	fe.exportSuggestedRecipesHandler(rr, newTestRequest(http.MethodGet, "/api/suggested-recipes/export?download=1", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.Contains(got, "attachment") {
+		t.Errorf("got Content-Disposition %q, want an attachment", got)
+	}
+	if strings.Contains(rr.Body.String(), "image_data") {
+		t.Error("export embeds image data, want image URLs")
+	}
+	var export recipeExport
+	if err := json.Unmarshal(rr.Body.Bytes(), &export); err != nil {
+		t.Fatalf("decoding %q: %v", rr.Body, err)
+	}
+	if len(export.Recipes) != 2 {
+		t.Fatalf("got %d recipes, want 2", len(export.Recipes))
+	}
+	pasta := export.Recipes[0]
+	if pasta.Title != "Pesto Pasta" || len(pasta.Ingredients) != 1 || len(pasta.StructuredInstructions) != 1 {
+		t.Errorf("got recipe %+v, want Pesto Pasta with its ingredient and instruction", pasta)
+	}
+	if pasta.ImageURL != "/suggested-recipe/sr-1/image" {
+		t.Errorf("got image URL %q, want /suggested-recipe/sr-1/image", pasta.ImageURL)
+	}
+	if got := export.Recipes[1].ImageURL; got != recipePlaceholderImage {
+		t.Errorf("got image URL %q for a recipe without an image, want the placeholder", got)
+	}
+
+	// The exported image URL serves the image.
+	rr = httptest.NewRecorder()
+	req := mux.SetURLVars(newTestRequest(http.MethodGet, pasta.ImageURL, nil), map[string]string{"id": "sr-1"})
+	fe.suggestedRecipeImageHandler(rr, req)
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), png) {
+		t.Errorf("got status %d and %d bytes, want the %d byte image", rr.Code, rr.Body.Len(), len(png))
+	}
+	if got := rr.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("got Content-Type %q, want image/png", got)
+	}
+}
+
+func TestExportSuggestedRecipesHandlerWithoutCache(t *testing.T) {
+	fe, _ := newTestFrontend(t)
+
+	req := newTestRequest(http.MethodGet, "/api/suggested-recipes/export", nil)
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	fe.exportSuggestedRecipesHandler(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// Recipe difficulties, easiest first.
+var recipeDifficulties = []string{"easy", "medium", "hard"}
+
+// recipeFilters narrows the recipe list. Zero values don't filter.
+type recipeFilters struct {
+	MaxCookTime int    // minutes
+	Difficulty  string // one of recipeDifficulties
+}
+
+// parseRecipeFilters reads the max_cook_time (minutes) and difficulty query
+// parameters. Unparseable values are ignored, as if they weren't given.
+func parseRecipeFilters(r *http.Request) recipeFilters {
+	var f recipeFilters
+	if n, err := strconv.Atoi(r.URL.Query().Get("max_cook_time")); err == nil && n > 0 {
+		f.MaxCookTime = n
+	}
+	d := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("difficulty")))
+	for _, known := range recipeDifficulties {
+		if d == known {
+			f.Difficulty = d
+		}
+	}
+	return f
+}
+
+// apply returns the recipes that pass the filters. Recipes whose cook time
+// can't be parsed are left out when filtering by cook time.
+func (f recipeFilters) apply(recipes []*pb.Recipe) []*pb.Recipe {
+	if f == (recipeFilters{}) {
+		return recipes
+	}
+	var out []*pb.Recipe
+	for _, rec := range recipes {
+		if f.MaxCookTime > 0 {
+			if minutes, ok := parseCookTime(rec.GetCookTime()); !ok || minutes > f.MaxCookTime {
+				continue
+			}
+		}
+		if f.Difficulty != "" && recipeDifficulty(rec) != f.Difficulty {
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+var cookTimePart = regexp.MustCompile(`(?i)(\d+)\s*(hours?|hrs?|h|minutes?|mins?|m)\b`)
+
+// parseCookTime converts cook times such as "25 minutes" or "1 hr 15 min"
+// to minutes.
+func parseCookTime(s string) (int, bool) {
+	parts := cookTimePart.FindAllStringSubmatch(s, -1)
+	if parts == nil {
+		return 0, false
+	}
+	minutes := 0
+	for _, p := range parts {
+		n, _ := strconv.Atoi(p[1])
+		if strings.HasPrefix(strings.ToLower(p[2]), "h") {
+			n *= 60
+		}
+		minutes += n
+	}
+	return minutes, true
+}
+
+// recipeDifficulty rates a recipe by its cook time and number of steps, the
+// harder of the two deciding. The recipe service doesn't rate recipes itself.
+func recipeDifficulty(rec *pb.Recipe) string {
+	level := 0
+	if minutes, ok := parseCookTime(rec.GetCookTime()); ok {
+		switch {
+		case minutes > 45:
+			level = 2
+		case minutes > 20:
+			level = 1
+		}
+	}
+	switch steps := len(rec.GetInstructions()); {
+	case steps > 10:
+		level = 2
+	case steps > 5 && level < 1:
+		level = 1
+	}
+	return recipeDifficulties[level]
+}
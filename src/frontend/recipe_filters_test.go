@@ -0,0 +1,117 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestParseCookTime(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int
+		wantOK bool
+	}{
+		{"25 minutes", 25, true},
+		{"1 hour 15 minutes", 75, true},
+		{"2 hrs", 120, true},
+		{"1h 30m", 90, true},
+		{"45 Min", 45, true},
+		{"", 0, false},
+		{"a while", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseCookTime(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseCookTime(%q) = (%d, %v), want (%d, %v)", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRecipeDifficulty(t *testing.T) {
+	steps := func(n int) []string { return make([]string, n) }
+	tests := []struct {
+		name string
+		rec  *pb.Recipe
+		want string
+	}{
+		{"quick and short", &pb.Recipe{CookTime: "15 minutes", Instructions: steps(3)}, "easy"},
+		{"longer cook", &pb.Recipe{CookTime: "30 minutes", Instructions: steps(3)}, "medium"},
+		{"many steps", &pb.Recipe{CookTime: "15 minutes", Instructions: steps(12)}, "hard"},
+		{"slow cook", &pb.Recipe{CookTime: "2 hours", Instructions: steps(6)}, "hard"},
+		{"unknown time", &pb.Recipe{CookTime: "varies", Instructions: steps(7)}, "medium"},
+	}
+	for _, tt := range tests {
+		if got := recipeDifficulty(tt.rec); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecipesHandlerFilters(t *testing.T) {
+	steps := func(n int) []string { return make([]string, n) }
+	recipes := []*pb.Recipe{
+		{RecipeId: "omelette", Title: "Omelette", CookTime: "10 minutes", Instructions: steps(3)},
+		{RecipeId: "risotto", Title: "Risotto", CookTime: "30 minutes", Instructions: steps(6)},
+		{RecipeId: "stir_fry", Title: "Stir Fry", CookTime: "15 minutes", Instructions: steps(8)},
+		{RecipeId: "stew", Title: "Beef Stew", CookTime: "2 hours", Instructions: steps(4)},
+		{RecipeId: "mystery", Title: "Mystery Dish", CookTime: "a while", Instructions: steps(2)},
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"cook time", "max_cook_time=20", []string{"Omelette", "Stir Fry"}},
+		{"cook time and difficulty", "max_cook_time=30&difficulty=medium", []string{"Risotto", "Stir Fry"}},
+		{"difficulty", "difficulty=HARD", []string{"Beef Stew"}},
+		{"unparseable values are ignored", "max_cook_time=soon&difficulty=impossible",
+			[]string{"Omelette", "Risotto", "Stir Fry", "Beef Stew", "Mystery Dish"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			backends.recipe.recipes = recipes
+
+			rr := httptest.NewRecorder()
+			fe.recipesHandler(rr, newTestRequest(http.MethodGet, "/recipes?fragment=true&"+tt.query, nil))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+			}
+			body := rr.Body.String()
+			shown := map[string]bool{}
+			for _, rec := range recipes {
+				shown[rec.Title] = strings.Contains(body, "<h3>"+rec.Title+"</h3>")
+			}
+			for _, title := range tt.want {
+				if !shown[title] {
+					t.Errorf("%s is missing", title)
+				}
+				delete(shown, title)
+			}
+			for title, ok := range shown {
+				if ok {
+					t.Errorf("%s should have been filtered out", title)
+				}
+			}
+		})
+	}
+}
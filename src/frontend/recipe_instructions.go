@@ -0,0 +1,123 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// InstructionStep is one numbered step of a recipe's instructions, with the
+// time it takes and any note split out of its text.
+type InstructionStep struct {
+	Number   int    `json:"number"`
+	Text     string `json:"text"`
+	Duration string `json:"duration,omitempty"` // e.g. "1 hr 15 min"
+	Seconds  int    `json:"duration_seconds,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+var (
+	// A step number such as "2.", "3)" or "Step 4:" at the start of a step.
+	stepNumber = regexp.MustCompile(`(?i)(?:^|\s)(?:step\s*)?\d+[.):]\s+`)
+
+	// A duration such as "20 minutes", "1 hr" or a range like "10-12 mins".
+	stepDuration = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)(?:\s*(?:-|–|to)\s*(\d+(?:\.\d+)?))?\s*(hours?|hrs?|minutes?|mins?|seconds?|secs?)\b`)
+
+	// A trailing note such as "Tip: cover with foil."
+	stepNote = regexp.MustCompile(`(?i)\b(?:note|tip):\s*`)
+)
+
+// structureInstructions turns a recipe's raw instructions into numbered steps.
+// Leading step numbers are dropped, and a single string holding several
+// numbered steps ("1. Boil water. 2. Add pasta.") is split into them. Empty
+// instructions are skipped.
+func structureInstructions(raw []string) []InstructionStep {
+	var steps []InstructionStep
+	for _, instruction := range raw {
+		for _, text := range splitNumberedSteps(strings.TrimSpace(instruction)) {
+			if text == "" {
+				continue
+			}
+			step := InstructionStep{Number: len(steps) + 1, Text: text}
+			if loc := stepNote.FindStringIndex(text); loc != nil && loc[0] > 0 {
+				step.Text = strings.TrimSpace(text[:loc[0]])
+				step.Note = strings.TrimSpace(text[loc[1]:])
+			}
+			if step.Seconds = stepSeconds(step.Text); step.Seconds > 0 {
+				step.Duration = formatStepDuration(step.Seconds)
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// splitNumberedSteps strips the step number from s, splitting s first if it
+// starts with a step number and holds more.
+func splitNumberedSteps(s string) []string {
+	locs := stepNumber.FindAllStringIndex(s, -1)
+	if len(locs) == 0 || locs[0][0] != 0 {
+		return []string{s}
+	}
+	parts := make([]string, len(locs))
+	for i, loc := range locs {
+		end := len(s)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		parts[i] = strings.TrimSpace(s[loc[1]:end])
+	}
+	return parts
+}
+
+// stepSeconds adds up the durations mentioned in a step, taking the longer
+// end of ranges.
+func stepSeconds(text string) int {
+	total := 0.0
+	for _, m := range stepDuration.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.ParseFloat(m[1], 64)
+		if m[2] != "" {
+			n, _ = strconv.ParseFloat(m[2], 64)
+		}
+		switch unit := strings.ToLower(m[3]); {
+		case strings.HasPrefix(unit, "h"):
+			n *= 3600
+		case strings.HasPrefix(unit, "m"):
+			n *= 60
+		}
+		total += n
+	}
+	return int(math.Round(total))
+}
+
+// formatStepDuration renders seconds as "1 hr 15 min", "20 min" or "30 sec".
+func formatStepDuration(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%d sec", seconds)
+	}
+	minutes := (seconds + 30) / 60
+	switch h, m := minutes/60, minutes%60; {
+	case h == 0:
+		return fmt.Sprintf("%d min", m)
+	case m == 0:
+		return fmt.Sprintf("%d hr", h)
+	default:
+		return fmt.Sprintf("%d hr %d min", h, m)
+	}
+}
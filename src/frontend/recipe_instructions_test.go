@@ -0,0 +1,120 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestStructureInstructions(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []string
+		want []InstructionStep
+	}{
+		{
+			name: "no durations",
+			raw:  []string{"Chop the onions.", "  ", "Season to taste."},
+			want: []InstructionStep{
+				{Number: 1, Text: "Chop the onions."},
+				{Number: 2, Text: "Season to taste."},
+			},
+		},
+		{
+			name: "durations",
+			raw: []string{
+				"Simmer for 20 minutes.",
+				"Bake 1 hour 15 mins until golden.",
+				"Rest for 10-12 minutes.",
+				"Whisk for 30 seconds.",
+			},
+			want: []InstructionStep{
+				{Number: 1, Text: "Simmer for 20 minutes.", Duration: "20 min", Seconds: 1200},
+				{Number: 2, Text: "Bake 1 hour 15 mins until golden.", Duration: "1 hr 15 min", Seconds: 4500},
+				{Number: 3, Text: "Rest for 10-12 minutes.", Duration: "12 min", Seconds: 720},
+				{Number: 4, Text: "Whisk for 30 seconds.", Duration: "30 sec", Seconds: 30},
+			},
+		},
+		{
+			name: "numbered and notes",
+			raw: []string{
+				"1. Boil water. 2) Add pasta and cook 8 minutes. Tip: salt the water first.",
+				"Step 3: Drain.",
+			},
+			want: []InstructionStep{
+				{Number: 1, Text: "Boil water."},
+				{Number: 2, Text: "Add pasta and cook 8 minutes.", Duration: "8 min", Seconds: 480, Note: "salt the water first."},
+				{Number: 3, Text: "Drain."},
+			},
+		},
+		{
+			name: "numbers inside a step",
+			raw:  []string{"Preheat the oven to 200. 2 trays are needed."},
+			want: []InstructionStep{{Number: 1, Text: "Preheat the oven to 200. 2 trays are needed."}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := structureInstructions(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("structureInstructions(%q)\n got %+v\nwant %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecipeInstructionsAPIHandler(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.recipe.recipes = []*pb.Recipe{{
+		RecipeId:     "r1",
+		Instructions: []string{"1. Boil water.", "2. Cook pasta for 8 minutes."},
+	}}
+
+	req := mux.SetURLVars(newTestRequest(http.MethodGet, "/api/recipe/r1/instructions", nil), map[string]string{"id": "r1"})
+	rr := httptest.NewRecorder()
+	fe.recipeInstructionsAPIHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	var got struct {
+		Instructions []string          `json:"instructions"`
+		Steps        []InstructionStep `json:"steps"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding %q: %v", rr.Body, err)
+	}
+	if len(got.Instructions) != 2 || got.Instructions[0] != "1. Boil water." {
+		t.Errorf("got raw instructions %q, want them unchanged", got.Instructions)
+	}
+	if len(got.Steps) != 2 || got.Steps[1].Text != "Cook pasta for 8 minutes." || got.Steps[1].Seconds != 480 {
+		t.Errorf("got steps %+v, want the second one timed at 8 minutes", got.Steps)
+	}
+
+	detail := mux.SetURLVars(newTestRequest(http.MethodGet, "/recipe/r1", nil), map[string]string{"id": "r1"})
+	rr = httptest.NewRecorder()
+	fe.recipeDetailHandler(rr, detail)
+	if !strings.Contains(rr.Body.String(), "⏱️ 8 min") {
+		t.Error("recipe page does not show the step duration")
+	}
+}
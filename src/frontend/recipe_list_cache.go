@@ -0,0 +1,78 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"html/template"
+	"sync"
+	"time"
+)
+
+// maxRecipeListCacheEntries bounds the recipe list cache, since every page
+// number and filter value makes a new key.
+const maxRecipeListCacheEntries = 256
+
+// recipeListKey identifies one rendering of the recipe grid.
+type recipeListKey struct {
+	currency string
+	page     pagination
+	filters  recipeFilters
+}
+
+// recipeListCache remembers rendered recipe grids for recipeListCacheTTL, so
+// repeat views of the recipe list don't call ListRecipes. Only the grid is
+// cached; the rest of the page, such as the cart size, is rendered per
+// request. The zero value is ready to use.
+type recipeListCache struct {
+	mu      sync.Mutex
+	entries map[recipeListKey]recipeListEntry
+}
+
+type recipeListEntry struct {
+	grid    template.HTML
+	expires time.Time
+}
+
+func (c *recipeListCache) get(key recipeListKey, now time.Time) (template.HTML, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !now.Before(e.expires) {
+		return "", false
+	}
+	return e.grid, true
+}
+
+func (c *recipeListCache) set(key recipeListKey, grid template.HTML, now time.Time) {
+	if recipeListCacheTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[recipeListKey]recipeListEntry)
+	}
+	if len(c.entries) >= maxRecipeListCacheEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxRecipeListCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = recipeListEntry{grid: grid, expires: now.Add(recipeListCacheTTL)}
+}
@@ -0,0 +1,140 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// removeRecipeFromCartHandler takes the cart items that match a recipe's
+// ingredients out of the cart in one go. The cart doesn't record which
+// recipe an item was added for, so an item that another recipe in the cart
+// also needs is kept: a recipe counts as in the cart when an item this
+// recipe doesn't match covers one of its ingredients. If the removal fails
+// part way the cart is restored as removeFromCart describes, so unrelated
+// items aren't lost with the recipe's.
+func (fe *frontendServer) removeRecipeFromCartHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	id := mux.Vars(r)["id"]
+
+	resp, err := fe.recipeService().GetRecipe(r.Context(), &pb.GetRecipeRequest{RecipeId: id})
+	if status.Code(err) == codes.NotFound {
+		renderAPIError(log, r, w, errors.Errorf("recipe %q not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "could not get recipe"), http.StatusInternalServerError)
+		return
+	}
+	cart, err := fe.getCart(r.Context(), sessionID(r))
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "could not retrieve cart"), http.StatusInternalServerError)
+		return
+	}
+	names := make(map[string]string, len(cart))
+	for _, item := range cart {
+		names[item.GetProductId()] = fe.getProductName(item.GetProductId())
+	}
+
+	matched := recipeCartProducts(resp.Recipe.GetIngredients(), names)
+	kept := make(map[string]bool)
+	if len(matched) > 0 {
+		others, err := fe.recipeService().ListRecipes(r.Context(), &pb.ListRecipesRequest{})
+		if err != nil {
+			renderAPIError(log, r, w, errors.Wrap(err, "could not list recipes"), http.StatusInternalServerError)
+			return
+		}
+		for _, other := range others.GetRecipes() {
+			if other.GetRecipeId() == id {
+				continue
+			}
+			needs := recipeCartProducts(other.GetIngredients(), names)
+			inCart := false
+			for productID := range needs {
+				if !matched[productID] {
+					inCart = true
+					break
+				}
+			}
+			if !inCart {
+				continue
+			}
+			for productID := range needs {
+				if matched[productID] {
+					kept[productID] = true
+				}
+			}
+		}
+	}
+
+	removed := []string{}
+	for _, item := range cart {
+		if productID := item.GetProductId(); matched[productID] && !kept[productID] {
+			removed = append(removed, productID)
+		}
+	}
+	if len(removed) > 0 {
+		if err := fe.mutateCart(r.Context(), sessionID(r), func(ctx context.Context) error {
+			return fe.removeFromCart(ctx, sessionID(r), removed...)
+		}); err != nil {
+			renderAPIError(log, r, w, errors.Wrap(err, "failed to remove recipe from cart"), http.StatusInternalServerError)
+			return
+		}
+	}
+	keptIDs := []string{}
+	for _, item := range cart {
+		if kept[item.GetProductId()] {
+			keptIDs = append(keptIDs, item.GetProductId())
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"recipe_id": id,
+		"removed":   removed,
+		"kept":      keptIDs,
+	}).Info("[Recipe] removed recipe ingredients from cart")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"recipe_id": id,
+		"removed":   removed,
+		"kept":      keptIDs,
+	}); err != nil {
+		log.WithError(err).Error("failed to encode response")
+	}
+}
+
+// recipeCartProducts returns the IDs of the cart products that
+// matchIngredientToCart pairs with the ingredients. names maps the cart's
+// product IDs to product names.
+func recipeCartProducts(ingredients []*pb.Ingredient, names map[string]string) map[string]bool {
+	products := make(map[string]bool)
+	for _, ingredient := range ingredients {
+		if productID, confidence := matchIngredientToCart(ingredient.GetName(), names); productID != "" && confidence >= ingredientMatchThreshold {
+			products[productID] = true
+		}
+	}
+	return products
+}
@@ -0,0 +1,162 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestRemoveRecipeFromCartHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		cart        []string
+		wantRemoved []string
+		wantKept    []string
+		wantCart    []string
+	}{
+		{
+			name:        "leaves unrelated items",
+			cart:        []string{"TOMATO", "SOAP", "BASIL", "PASTA"},
+			wantRemoved: []string{"TOMATO", "BASIL", "PASTA"},
+			wantKept:    []string{},
+			wantCart:    []string{"SOAP"},
+		},
+		{
+			name:        "keeps items another recipe in the cart needs",
+			cart:        []string{"TOMATO", "BASIL", "PASTA", "EGGS"},
+			wantRemoved: []string{"TOMATO", "BASIL"},
+			wantKept:    []string{"PASTA"},
+			wantCart:    []string{"PASTA", "EGGS"},
+		},
+		{
+			name:        "nothing to remove",
+			cart:        []string{"SOAP"},
+			wantRemoved: []string{},
+			wantKept:    []string{},
+			wantCart:    []string{"SOAP"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			backends.catalog.setProducts(
+				&pb.Product{Id: "TOMATO", Name: "Tomatoes"},
+				&pb.Product{Id: "BASIL", Name: "Basil"},
+				&pb.Product{Id: "PASTA", Name: "Spaghetti Pasta"},
+				&pb.Product{Id: "EGGS", Name: "Eggs"},
+				&pb.Product{Id: "SOAP", Name: "Dish Soap"},
+			)
+			backends.recipe.recipes = []*pb.Recipe{
+				{RecipeId: "r1", Ingredients: []*pb.Ingredient{{Name: "Tomatoes"}, {Name: "Fresh Basil"}, {Name: "Pasta"}}},
+				{RecipeId: "r2", Ingredients: []*pb.Ingredient{{Name: "Eggs"}, {Name: "Pasta"}}},
+			}
+			var items []*pb.CartItem
+			for _, id := range tt.cart {
+				items = append(items, &pb.CartItem{ProductId: id, Quantity: 1})
+			}
+			backends.cart.setCart(testSessionID, items...)
+			updates, unsubscribe := fe.cartUpdateClients.subscribe(testSessionID)
+			defer unsubscribe()
+
+			req := mux.SetURLVars(newTestRequest(http.MethodPost, "/recipe/r1/remove-from-cart", nil), map[string]string{"id": "r1"})
+			rr := httptest.NewRecorder()
+			fe.removeRecipeFromCartHandler(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			var resp struct {
+				Removed []string `json:"removed"`
+				Kept    []string `json:"kept"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if !reflect.DeepEqual(resp.Removed, tt.wantRemoved) || !reflect.DeepEqual(resp.Kept, tt.wantKept) {
+				t.Errorf("got removed %q and kept %q, want %q and %q", resp.Removed, resp.Kept, tt.wantRemoved, tt.wantKept)
+			}
+
+			cart, _ := fe.getCart(req.Context(), testSessionID)
+			var got []string
+			for _, item := range cart {
+				got = append(got, item.GetProductId())
+			}
+			if !reflect.DeepEqual(got, tt.wantCart) {
+				t.Errorf("got cart %q, want %q", got, tt.wantCart)
+			}
+
+			select {
+			case update := <-updates:
+				if len(tt.wantRemoved) == 0 {
+					t.Errorf("got cart update %+v, want none when nothing was removed", update)
+				} else if update.Count != len(tt.wantCart) {
+					t.Errorf("got cart update with %d items, want %d", update.Count, len(tt.wantCart))
+				}
+			case <-time.After(100 * time.Millisecond):
+				if len(tt.wantRemoved) > 0 {
+					t.Error("no cart update was sent")
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveRecipeFromCartHandlerKeepsCartOnFailure(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(&pb.Product{Id: "TOMATO", Name: "Tomatoes"}, &pb.Product{Id: "SOAP", Name: "Dish Soap"})
+	backends.recipe.recipes = []*pb.Recipe{{RecipeId: "r1", Ingredients: []*pb.Ingredient{{Name: "Tomatoes"}}}}
+	backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "TOMATO", Quantity: 1}, &pb.CartItem{ProductId: "SOAP", Quantity: 2})
+	// Adding the soap back fails once; restoring the cart then works.
+	var failed atomic.Bool
+	backends.cart.onAdd = func(*pb.AddItemRequest) error {
+		if failed.CompareAndSwap(false, true) {
+			return status.Error(codes.Unavailable, "cart is down")
+		}
+		return nil
+	}
+
+	req := mux.SetURLVars(newTestRequest(http.MethodPost, "/recipe/r1/remove-from-cart", nil), map[string]string{"id": "r1"})
+	rr := httptest.NewRecorder()
+	fe.removeRecipeFromCartHandler(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusInternalServerError)
+	}
+	if got, want := cartContents(backends, testSessionID), "SOAP×2, TOMATO×1"; got != want {
+		t.Errorf("cart holds %q after the failed removal, want it unchanged: %q", got, want)
+	}
+}
+
+func TestRemoveRecipeFromCartHandlerUnknownRecipe(t *testing.T) {
+	fe, _ := newTestFrontend(t)
+	req := mux.SetURLVars(newTestRequest(http.MethodPost, "/recipe/nope/remove-from-cart", nil), map[string]string{"id": "nope"})
+	rr := httptest.NewRecorder()
+	fe.removeRecipeFromCartHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
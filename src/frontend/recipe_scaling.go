@@ -0,0 +1,143 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// Servings assumed for recipes that don't say.
+const fallbackRecipeServings = 4
+
+// ScaledIngredient is a recipe ingredient scaled to a number of servings.
+type ScaledIngredient struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+// countableUnits are rounded to whole numbers when scaled.
+var countableUnits = map[string]bool{"piece": true, "pieces": true, "cloves": true, "packet": true}
+
+// scaleQuantity scales quantity from one number of servings to another,
+// rounding the way the recipe page does: countable units to whole numbers,
+// everything else to one decimal.
+func scaleQuantity(quantity float64, unit string, from, to int32) float64 {
+	scaled := quantity * float64(to) / float64(from)
+	if countableUnits[unit] {
+		return math.Round(scaled)
+	}
+	return math.Round(scaled*10) / 10
+}
+
+// parseServings reads the servings form or query value. Without one it is
+// recipeDefault, or fallbackRecipeServings if the recipe doesn't say. Values
+// outside 1 to maxRecipeServings are clamped; anything but a whole number is
+// an error.
+func parseServings(r *http.Request, recipeDefault int32) (int32, error) {
+	v := strings.TrimSpace(r.FormValue("servings"))
+	if v == "" {
+		if recipeDefault <= 0 {
+			return fallbackRecipeServings, nil
+		}
+		return recipeDefault, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("servings must be a whole number, got %q", v)
+	}
+	return int32(min(max(n, 1), int64(maxRecipeServings))), nil
+}
+
+func writeScaledIngredients(log logrus.FieldLogger, r *http.Request, w http.ResponseWriter, id string, defaultServings int32, ingredients []ScaledIngredient) {
+	if defaultServings <= 0 {
+		defaultServings = fallbackRecipeServings
+	}
+	servings, err := parseServings(r, defaultServings)
+	if err != nil {
+		renderAPIError(log, r, w, err, http.StatusUnprocessableEntity)
+		return
+	}
+	for i, ing := range ingredients {
+		ingredients[i].Quantity = scaleQuantity(ing.Quantity, ing.Unit, defaultServings, servings)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"recipe_id":        id,
+		"servings":         servings,
+		"default_servings": defaultServings,
+		"ingredients":      ingredients,
+	}); err != nil {
+		log.WithError(err).Error("failed to encode scaled ingredients")
+	}
+}
+
+// recipeIngredientsAPIHandler returns a recipe's ingredients as JSON, scaled
+// to the servings query parameter.
+func (fe *frontendServer) recipeIngredientsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	id := mux.Vars(r)["id"]
+	resp, err := fe.recipeService().GetRecipe(r.Context(), &pb.GetRecipeRequest{RecipeId: id})
+	if status.Code(err) == codes.NotFound {
+		renderAPIError(log, r, w, errors.Errorf("recipe %q not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		renderAPIError(log, r, w, errors.Wrap(err, "could not get recipe"), http.StatusInternalServerError)
+		return
+	}
+
+	ingredients := make([]ScaledIngredient, len(resp.Recipe.GetIngredients()))
+	for i, ing := range resp.Recipe.GetIngredients() {
+		ingredients[i] = ScaledIngredient{Name: ing.GetName(), Quantity: float64(ing.GetQuantity()), Unit: ing.GetUnit()}
+	}
+	writeScaledIngredients(log, r, w, id, resp.Recipe.GetDefaultServings(), ingredients)
+}
+
+// suggestedRecipeIngredientsAPIHandler does the same as
+// recipeIngredientsAPIHandler for a suggested recipe in the session's cache.
+func (fe *frontendServer) suggestedRecipeIngredientsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	id := mux.Vars(r)["id"]
+	var recipe *CachedRecipe
+	if cached, ok := fe.suggestedRecipesCache.Load(sessionID(r)); ok {
+		recipes, _ := cached.([]CachedRecipe)
+		recipe = findCachedRecipe(recipes, id)
+	}
+	if recipe == nil {
+		renderAPIError(log, r, w, errors.Errorf("suggested recipe %q not found", id), http.StatusNotFound)
+		return
+	}
+
+	ingredients := make([]ScaledIngredient, len(recipe.Ingredients))
+	for i, ing := range recipe.Ingredients {
+		ingredients[i] = ScaledIngredient{Name: ing.Name, Quantity: float64(ing.Quantity), Unit: ing.Unit}
+	}
+	writeScaledIngredients(log, r, w, id, recipe.DefaultServings, ingredients)
+}
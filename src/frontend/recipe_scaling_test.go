@@ -0,0 +1,141 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestRecipeIngredientsAPIHandlers(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.recipe.recipes = []*pb.Recipe{{
+		RecipeId:        "r1",
+		DefaultServings: 4,
+		Ingredients: []*pb.Ingredient{
+			{Name: "Flour", Quantity: 250, Unit: "g"},
+			{Name: "Eggs", Quantity: 3, Unit: "pieces"},
+			{Name: "Milk", Quantity: 0.5, Unit: "l"},
+		},
+	}}
+	fe.suggestedRecipesCache.Store(testSessionID, []CachedRecipe{{
+		RecipeId:        "r1",
+		DefaultServings: 4,
+		Ingredients: []*CachedIngredient{
+			{Name: "Flour", Quantity: 250, Unit: "g"},
+			{Name: "Eggs", Quantity: 3, Unit: "pieces"},
+			{Name: "Milk", Quantity: 0.5, Unit: "l"},
+		},
+	}})
+
+	tests := []struct {
+		name         string
+		query        string
+		wantServings int32
+		want         []ScaledIngredient
+	}{
+		{"default servings", "", 4, []ScaledIngredient{{"Flour", 250, "g"}, {"Eggs", 3, "pieces"}, {"Milk", 0.5, "l"}}},
+		{"scaled up", "?servings=6", 6, []ScaledIngredient{{"Flour", 375, "g"}, {"Eggs", 5, "pieces"}, {"Milk", 0.8, "l"}}},
+		{"scaled down", "?servings=1", 1, []ScaledIngredient{{"Flour", 62.5, "g"}, {"Eggs", 1, "pieces"}, {"Milk", 0.1, "l"}}},
+	}
+	for path, handler := range map[string]http.HandlerFunc{
+		"/api/recipe/r1/ingredients":           fe.recipeIngredientsAPIHandler,
+		"/api/suggested-recipe/r1/ingredients": fe.suggestedRecipeIngredientsAPIHandler,
+	} {
+		for _, tt := range tests {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				req := mux.SetURLVars(newTestRequest(http.MethodGet, path+tt.query, nil), map[string]string{"id": "r1"})
+				rr := httptest.NewRecorder()
+				handler(rr, req)
+
+				if rr.Code != http.StatusOK {
+					t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+				}
+				var got struct {
+					Servings    int32              `json:"servings"`
+					Ingredients []ScaledIngredient `json:"ingredients"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+					t.Fatalf("decoding %q: %v", rr.Body, err)
+				}
+				if got.Servings != tt.wantServings || !reflect.DeepEqual(got.Ingredients, tt.want) {
+					t.Errorf("got %d servings of %v, want %d of %v", got.Servings, got.Ingredients, tt.wantServings, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestRecipeIngredientsAPIHandlerValidatesServings(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.recipe.recipes = []*pb.Recipe{{RecipeId: "r1", Ingredients: []*pb.Ingredient{{Name: "Flour"}}}}
+
+	for _, servings := range []string{"two", "1.5"} {
+		req := mux.SetURLVars(newTestRequest(http.MethodGet, "/api/recipe/r1/ingredients?servings="+servings, nil), map[string]string{"id": "r1"})
+		req.Header.Set("Accept", "application/json")
+		rr := httptest.NewRecorder()
+		fe.recipeIngredientsAPIHandler(rr, req)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("servings=%s: got status %d, want %d", servings, rr.Code, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestParseServings(t *testing.T) {
+	defer func(old int) { maxRecipeServings = old }(maxRecipeServings)
+	maxRecipeServings = 12
+
+	tests := []struct {
+		name          string
+		value         string
+		recipeDefault int32
+		want          int32
+		wantErr       bool
+	}{
+		{"missing uses recipe default", "", 6, 6, false},
+		{"missing without recipe default", "", 0, fallbackRecipeServings, false},
+		{"valid", "3", 6, 3, false},
+		{"surrounding space", " 8 ", 6, 8, false},
+		{"zero clamps to one", "0", 6, 1, false},
+		{"negative clamps to one", "-4", 6, 1, false},
+		{"too many clamps to max", "13", 6, 12, false},
+		{"huge clamps to max", "99999999999", 6, 12, false},
+		{"non-numeric", "two", 6, 0, true},
+		{"fraction", "2.5", 6, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/recipe/r1/add-to-cart"
+			if tt.value != "" {
+				target += "?servings=" + url.QueryEscape(tt.value)
+			}
+			got, err := parseServings(newTestRequest(http.MethodPost, target, nil), tt.recipeDefault)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseServings(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseServings(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// similarRecipe is a recipe sharing ingredients with the one being viewed.
+type similarRecipe struct {
+	recipe *pb.Recipe
+	shared []string // normalized names, in the other recipe's order
+}
+
+// rankSimilarRecipes returns up to n of candidates that share ingredients
+// with recipe, most shared first, ties in candidate order. Ingredient names
+// are compared after normalizeIngredientName, and recipe itself is skipped.
+func rankSimilarRecipes(recipe *pb.Recipe, candidates []*pb.Recipe, n int) []similarRecipe {
+	mine := make(map[string]bool)
+	for _, ing := range recipe.GetIngredients() {
+		mine[normalizeIngredientName(ing.GetName())] = true
+	}
+
+	var ranked []similarRecipe
+	for _, c := range candidates {
+		if c.GetRecipeId() == recipe.GetRecipeId() {
+			continue
+		}
+		var shared []string
+		seen := make(map[string]bool)
+		for _, ing := range c.GetIngredients() {
+			name := normalizeIngredientName(ing.GetName())
+			if mine[name] && !seen[name] {
+				seen[name] = true
+				shared = append(shared, name)
+			}
+		}
+		if len(shared) > 0 {
+			ranked = append(ranked, similarRecipe{recipe: c, shared: shared})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return len(ranked[i].shared) > len(ranked[j].shared) })
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+// similarRecipes fetches the recipe with the given ID and the recipes most
+// like it.
+func (fe *frontendServer) similarRecipes(ctx context.Context, id string) (*pb.Recipe, []similarRecipe, error) {
+	resp, err := fe.recipeService().GetRecipe(ctx, &pb.GetRecipeRequest{RecipeId: id})
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := fe.recipeService().ListRecipes(ctx, &pb.ListRecipesRequest{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not list recipes")
+	}
+	return resp.GetRecipe(), rankSimilarRecipes(resp.GetRecipe(), all.GetRecipes(), similarRecipesCount), nil
+}
+
+// similarRecipesError maps an error from similarRecipes to a status code.
+func similarRecipesError(id string, err error) (int, error) {
+	if status.Code(err) == codes.NotFound {
+		return http.StatusNotFound, errors.Errorf("recipe %q not found", id)
+	}
+	return http.StatusInternalServerError, errors.Wrap(err, "could not get similar recipes")
+}
+
+// similarRecipesHandler shows the recipes sharing the most ingredients with
+// a recipe.
+func (fe *frontendServer) similarRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	id := mux.Vars(r)["id"]
+	recipe, similar, err := fe.similarRecipes(r.Context(), id)
+	if err != nil {
+		code, err := similarRecipesError(id, err)
+		renderHTTPError(log, r, w, err, code)
+		return
+	}
+	currencies, err := fe.getCurrencies(r.Context())
+	if err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "could not retrieve currencies"), http.StatusInternalServerError)
+		return
+	}
+
+	recipes := make([]*pb.Recipe, len(similar))
+	for i, s := range similar {
+		recipes[i] = s.recipe
+	}
+	if err := executeTemplate(w, "recipe-similar", injectCommonTemplateData(r, map[string]interface{}{
+		"show_currency": false,
+		"currencies":    currencies,
+		"recipe":        recipe,
+		"recipes":       recipes,
+	})); err != nil {
+		renderHTTPError(log, r, w, errors.Wrap(err, "failed to render page"), http.StatusInternalServerError)
+	}
+}
+
+// similarRecipesAPIHandler returns the recipes sharing the most ingredients
+// with a recipe as JSON, with the ingredients they share.
+func (fe *frontendServer) similarRecipesAPIHandler(w http.ResponseWriter, r *http.Request) {
+	log := r.Context().Value(ctxKeyLog{}).(logrus.FieldLogger)
+	id := mux.Vars(r)["id"]
+	_, similar, err := fe.similarRecipes(r.Context(), id)
+	if err != nil {
+		code, err := similarRecipesError(id, err)
+		renderAPIError(log, r, w, err, code)
+		return
+	}
+
+	type similarView struct {
+		RecipeID          string   `json:"recipe_id"`
+		Title             string   `json:"title"`
+		SharedIngredients []string `json:"shared_ingredients"`
+	}
+	out := make([]similarView, len(similar))
+	for i, s := range similar {
+		out[i] = similarView{RecipeID: s.recipe.GetRecipeId(), Title: s.recipe.GetTitle(), SharedIngredients: s.shared}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"recipe_id": id,
+		"similar":   out,
+	}); err != nil {
+		log.WithError(err).Error("failed to encode similar recipes")
+	}
+}
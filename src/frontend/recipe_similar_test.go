@@ -0,0 +1,125 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func testIngredients(names ...string) []*pb.Ingredient {
+	out := make([]*pb.Ingredient, len(names))
+	for i, n := range names {
+		out[i] = &pb.Ingredient{Name: n}
+	}
+	return out
+}
+
+func TestRankSimilarRecipes(t *testing.T) {
+	recipe := &pb.Recipe{RecipeId: "pasta", Ingredients: testIngredients("Spaghetti", "Tomatoes", "Garlic", "Basil")}
+	candidates := []*pb.Recipe{
+		recipe,
+		{RecipeId: "salad", Ingredients: testIngredients("tomatoes", "Lettuce")},
+		{RecipeId: "bruschetta", Ingredients: testIngredients("Bread", " Tomatoes", "garlic", "BASIL")},
+		{RecipeId: "pancakes", Ingredients: testIngredients("Flour", "Eggs")},
+		{RecipeId: "soup", Ingredients: testIngredients("Garlic", "Onion")},
+		{RecipeId: "aglio", Ingredients: testIngredients("Spaghetti", "Garlic")},
+	}
+
+	var got []string
+	for _, s := range rankSimilarRecipes(recipe, candidates, 3) {
+		got = append(got, s.recipe.GetRecipeId())
+	}
+	if want := []string{"bruschetta", "aglio", "salad"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := rankSimilarRecipes(recipe, candidates[3:4], 3); len(got) != 0 {
+		t.Errorf("got %d similar recipes for one sharing nothing, want none", len(got))
+	}
+}
+
+func TestSimilarRecipesAPIHandler(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.recipe.recipes = []*pb.Recipe{
+		{RecipeId: "pasta", Title: "Pasta", Ingredients: testIngredients("Spaghetti", "Tomatoes", "Garlic")},
+		{RecipeId: "salad", Title: "Salad", Ingredients: testIngredients("Tomatoes", "Lettuce")},
+		{RecipeId: "aglio", Title: "Aglio e Olio", Ingredients: testIngredients("Spaghetti", "Garlic")},
+		{RecipeId: "pancakes", Title: "Pancakes", Ingredients: testIngredients("Flour", "Eggs")},
+	}
+
+	get := func(id string) *httptest.ResponseRecorder {
+		req := newTestRequest(http.MethodGet, "/api/recipe/"+id+"/similar", nil)
+		req.Header.Set("Accept", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		fe.similarRecipesAPIHandler(rr, req)
+		return rr
+	}
+	type response struct {
+		Similar []struct {
+			RecipeID          string   `json:"recipe_id"`
+			SharedIngredients []string `json:"shared_ingredients"`
+		} `json:"similar"`
+	}
+
+	rr := get("pasta")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	var got response
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Similar) != 2 || got.Similar[0].RecipeID != "aglio" || got.Similar[1].RecipeID != "salad" {
+		t.Fatalf("got %+v, want aglio then salad", got.Similar)
+	}
+	if shared := got.Similar[0].SharedIngredients; !reflect.DeepEqual(shared, []string{"spaghetti", "garlic"}) {
+		t.Errorf("got shared ingredients %q, want spaghetti and garlic", shared)
+	}
+
+	if rr := get("pancakes"); !strings.Contains(rr.Body.String(), `"similar":[]`) {
+		t.Errorf("got %s, want an empty similar list", rr.Body)
+	}
+	if rr := get("missing"); rr.Code != http.StatusNotFound {
+		t.Errorf("got status %d for a missing recipe, want %d", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestSimilarRecipesHandlerWithNoMatches(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.recipe.recipes = []*pb.Recipe{
+		{RecipeId: "pasta", Title: "Pasta", Ingredients: testIngredients("Spaghetti")},
+		{RecipeId: "pancakes", Title: "Pancakes", Ingredients: testIngredients("Flour")},
+	}
+
+	req := mux.SetURLVars(newTestRequest(http.MethodGet, "/recipe/pasta/similar", nil), map[string]string{"id": "pasta"})
+	rr := httptest.NewRecorder()
+	fe.similarRecipesHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if !strings.Contains(rr.Body.String(), "No other recipes share ingredients") {
+		t.Error("page does not say there are no similar recipes")
+	}
+}
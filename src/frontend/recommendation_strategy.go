@@ -0,0 +1,87 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// recommendationStrategy post-processes the products the recommendation
+// service suggests to userID, who is looking at productIDs, before they are
+// cut down to fit the page. It may drop and reorder products.
+type recommendationStrategy interface {
+	apply(ctx context.Context, fe *frontendServer, userID string, productIDs []string, recommended []*pb.Product) ([]*pb.Product, error)
+}
+
+// recommendationStrategies are the strategies RECOMMENDATION_STRATEGY can
+// name.
+var recommendationStrategies = map[string]recommendationStrategy{
+	"default":       passThroughRecommendations{},
+	"cart-dedup":    cartDedupRecommendations{},
+	"same-category": sameCategoryRecommendations{},
+}
+
+// passThroughRecommendations shows the recommendation service's products as
+// they are.
+type passThroughRecommendations struct{}
+
+func (passThroughRecommendations) apply(_ context.Context, _ *frontendServer, _ string, _ []string, recommended []*pb.Product) ([]*pb.Product, error) {
+	return recommended, nil
+}
+
+// cartDedupRecommendations drops products the user is already looking at or
+// already has in their cart.
+type cartDedupRecommendations struct{}
+
+func (cartDedupRecommendations) apply(ctx context.Context, fe *frontendServer, userID string, productIDs []string, recommended []*pb.Product) ([]*pb.Product, error) {
+	cart, err := fe.getCart(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cart")
+	}
+	exclude := append(cartIDs(cart), productIDs...)
+	return slices.DeleteFunc(slices.Clone(recommended), func(p *pb.Product) bool {
+		return slices.Contains(exclude, p.GetId())
+	}), nil
+}
+
+// sameCategoryRecommendations moves products sharing a category with the
+// ones the user is looking at to the front, keeping the service's order
+// otherwise.
+type sameCategoryRecommendations struct{}
+
+func (sameCategoryRecommendations) apply(ctx context.Context, fe *frontendServer, _ string, productIDs []string, recommended []*pb.Product) ([]*pb.Product, error) {
+	categories := make(map[string]bool)
+	for _, id := range productIDs {
+		p, err := fe.getProduct(ctx, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get product %s", id)
+		}
+		for _, c := range p.GetCategories() {
+			categories[c] = true
+		}
+	}
+	related := func(p *pb.Product) bool {
+		return slices.ContainsFunc(p.GetCategories(), func(c string) bool { return categories[c] })
+	}
+	out := slices.Clone(recommended)
+	sort.SliceStable(out, func(i, j int) bool { return related(out[i]) && !related(out[j]) })
+	return out, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func recommendationIDs(t *testing.T, fe *frontendServer, productIDs []string) []string {
+	t.Helper()
+	recs, err := fe.getRecommendations(context.Background(), testSessionID, productIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, p := range recs {
+		ids = append(ids, p.GetId())
+	}
+	return ids
+}
+
+func TestRecommendationStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     []string
+	}{
+		{"default", []string{"P2", "P3", "P4", "P5"}},
+		// P1 is being viewed and P3 is in the cart.
+		{"cart-dedup", []string{"P2", "P4", "P5", "P6"}},
+		// P1 is a pasta, like P4 and P6.
+		{"same-category", []string{"P4", "P6", "P2", "P3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			backends.catalog.setProducts(
+				&pb.Product{Id: "P1", Categories: []string{"pasta"}},
+				&pb.Product{Id: "P2", Categories: []string{"sauce"}},
+				&pb.Product{Id: "P3", Categories: []string{"cheese"}},
+				&pb.Product{Id: "P4", Categories: []string{"pasta"}},
+				&pb.Product{Id: "P5", Categories: []string{"herbs"}},
+				&pb.Product{Id: "P6", Categories: []string{"herbs", "pasta"}},
+			)
+			backends.recommendation.productIDs = []string{"P2", "P3", "P4", "P5", "P6"}
+			backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P3", Quantity: 1})
+			fe.recommender = recommendationStrategies[tt.strategy]
+
+			if got := recommendationIDs(t, fe, []string{"P1"}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got recommendations %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -16,56 +16,157 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+	"github.com/GoogleCloudPlatform/microservices-demo/src/frontend/money"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	avoidNoopCurrencyConversionRPC = false
 )
 
+// getCurrencies lists the supported currencies the frontend can display. The
+// default currency is always included.
 func (fe *frontendServer) getCurrencies(ctx context.Context) ([]string, error) {
-	currs, err := pb.NewCurrencyServiceClient(fe.currencySvcConn).
+	currs, err := fe.currencyService().
 		GetSupportedCurrencies(ctx, &pb.Empty{})
 	if err != nil {
 		return nil, err
 	}
+	if len(currs.GetCurrencyCodes()) == 0 {
+		log.Warnf("currency service returned no currencies, offering only %s", defaultCurrency)
+	}
 	var out []string
+	hasDefault := false
 	for _, c := range currs.CurrencyCodes {
 		if _, ok := whitelistedCurrencies[c]; ok {
 			out = append(out, c)
+			hasDefault = hasDefault || c == defaultCurrency
 		}
 	}
+	// Prices are always available in the default currency, so the selector
+	// always offers it.
+	if !hasDefault {
+		out = append(out, defaultCurrency)
+	}
 	return out, nil
 }
 
-func (fe *frontendServer) getProducts(ctx context.Context) ([]*pb.Product, error) {
-	resp, err := pb.NewProductCatalogServiceClient(fe.productCatalogSvcConn).
+// getProducts lists the catalog, falling back to the last known snapshot when
+// the catalog service is unavailable.
+func (fe *frontendServer) getProducts(ctx context.Context) (_ []*pb.Product, err error) {
+	ctx, span := startSpan(ctx, "frontend.getProducts")
+	defer func() { endSpan(span, err) }()
+
+	products, err := fe.fetchProducts(ctx)
+	if err == nil {
+		fe.productSnapshot.set(products, time.Now())
+		span.SetAttributes(attribute.Int("product.count", len(products)))
+		return products, nil
+	}
+	if snapshot, updatedAt, ok := fe.productSnapshot.get(); ok {
+		log.WithError(err).WithField("snapshot_age", time.Since(updatedAt).String()).
+			Warn("product catalog unavailable, serving snapshot")
+		span.SetAttributes(attribute.Int("product.count", len(snapshot)), attribute.Bool("product.snapshot", true))
+		return snapshot, nil
+	}
+	return nil, err
+}
+
+func (fe *frontendServer) fetchProducts(ctx context.Context) ([]*pb.Product, error) {
+	resp, err := fe.productCatalogService().
 		ListProducts(ctx, &pb.Empty{})
 	return resp.GetProducts(), err
 }
 
 func (fe *frontendServer) getProduct(ctx context.Context, id string) (*pb.Product, error) {
-	resp, err := pb.NewProductCatalogServiceClient(fe.productCatalogSvcConn).
+	resp, err := fe.productCatalogService().
 		GetProduct(ctx, &pb.GetProductRequest{Id: id})
 	return resp, err
 }
 
 func (fe *frontendServer) getCart(ctx context.Context, userID string) ([]*pb.CartItem, error) {
-	resp, err := pb.NewCartServiceClient(fe.cartSvcConn).GetCart(ctx, &pb.GetCartRequest{UserId: userID})
+	resp, err := fe.cartService().GetCart(ctx, &pb.GetCartRequest{UserId: userID})
 	return resp.GetItems(), err
 }
 
 func (fe *frontendServer) emptyCart(ctx context.Context, userID string) error {
-	_, err := pb.NewCartServiceClient(fe.cartSvcConn).EmptyCart(ctx, &pb.EmptyCartRequest{UserId: userID})
+	_, err := fe.cartService().EmptyCart(ctx, &pb.EmptyCartRequest{UserId: userID})
 	return err
 }
 
+// cartRestoreTimeout bounds putting a cart back after removeFromCart fails,
+// which runs even if the request was canceled.
+const cartRestoreTimeout = 5 * time.Second
+
+// removeFromCart takes products out of the user's cart. The cart service
+// can't remove single items, so the cart is read, emptied and the other items
+// are added back. If adding them back fails, the cart as it was read is
+// restored.
+//
+// This isn't atomic. Callers go through mutateCart, which keeps this
+// frontend's own changes to the cart from interleaving, but items added
+// between the read and the empty by another replica or by the recipe service
+// are lost, and if restoring fails too, the items not yet added back are.
+// The error then names them.
+func (fe *frontendServer) removeFromCart(ctx context.Context, userID string, productIDs ...string) (err error) {
+	ctx, span := startSpan(ctx, "frontend.removeFromCart", attribute.StringSlice("product_ids", productIDs))
+	defer func() { endSpan(span, err) }()
+
+	cart, err := fe.getCart(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := fe.emptyCart(ctx, userID); err != nil {
+		return err
+	}
+	for _, item := range cart {
+		if slices.Contains(productIDs, item.GetProductId()) {
+			continue
+		}
+		if err := fe.insertCart(ctx, userID, item.GetProductId(), item.GetQuantity()); err != nil {
+			if restoreErr := fe.restoreCart(ctx, userID, cart); restoreErr != nil {
+				return errors.Wrapf(err, "could not add cart items back, nor restore the cart (%v), which held %s", restoreErr, cartItemsString(cart))
+			}
+			return errors.Wrap(err, "could not add cart items back; the cart was restored")
+		}
+	}
+	return nil
+}
+
+// restoreCart replaces userID's cart with items.
+func (fe *frontendServer) restoreCart(ctx context.Context, userID string, items []*pb.CartItem) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cartRestoreTimeout)
+	defer cancel()
+	if err := fe.emptyCart(ctx, userID); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := fe.insertCart(ctx, userID, item.GetProductId(), item.GetQuantity()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cartItemsString lists items as "id×quantity", for errors.
+func cartItemsString(items []*pb.CartItem) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = fmt.Sprintf("%s×%d", item.GetProductId(), item.GetQuantity())
+	}
+	return strings.Join(parts, ", ")
+}
+
 func (fe *frontendServer) insertCart(ctx context.Context, userID, productID string, quantity int32) error {
-	_, err := pb.NewCartServiceClient(fe.cartSvcConn).AddItem(ctx, &pb.AddItemRequest{
+	_, err := fe.cartService().AddItem(ctx, &pb.AddItemRequest{
 		UserId: userID,
 		Item: &pb.CartItem{
 			ProductId: productID,
@@ -74,18 +175,34 @@ func (fe *frontendServer) insertCart(ctx context.Context, userID, productID stri
 	return err
 }
 
-func (fe *frontendServer) convertCurrency(ctx context.Context, money *pb.Money, currency string) (*pb.Money, error) {
-	if avoidNoopCurrencyConversionRPC && money.GetCurrencyCode() == currency {
-		return money, nil
+// convertCurrency converts m to currency. If the currency service is down,
+// the conversion may fall back to a cached rate; see fallbackRate.
+func (fe *frontendServer) convertCurrency(ctx context.Context, m *pb.Money, currency string) (*pb.Money, error) {
+	if avoidNoopCurrencyConversionRPC && m.GetCurrencyCode() == currency {
+		return m, nil
+	}
+	converted, err := fe.requestConversion(ctx, m, currency)
+	if err != nil {
+		if rate, ok := fe.fallbackRate(ctx, currencyPair{m.GetCurrencyCode(), currency}, err); ok {
+			fallback := money.Convert(*m, *rate)
+			return &fallback, nil
+		}
 	}
-	return pb.NewCurrencyServiceClient(fe.currencySvcConn).
+	return converted, err
+}
+
+func (fe *frontendServer) requestConversion(ctx context.Context, m *pb.Money, currency string) (*pb.Money, error) {
+	return fe.currencyService().
 		Convert(ctx, &pb.CurrencyConversionRequest{
-			From:   money,
+			From:   m,
 			ToCode: currency})
 }
 
-func (fe *frontendServer) getShippingQuote(ctx context.Context, items []*pb.CartItem, currency string) (*pb.Money, error) {
-	quote, err := pb.NewShippingServiceClient(fe.shippingSvcConn).GetQuote(ctx,
+func (fe *frontendServer) getShippingQuote(ctx context.Context, items []*pb.CartItem, currency string) (_ *pb.Money, err error) {
+	ctx, span := startSpan(ctx, "frontend.getShippingQuote", attribute.Int("cart.item_count", len(items)))
+	defer func() { endSpan(span, err) }()
+
+	quote, err := fe.shippingService().GetQuote(ctx,
 		&pb.GetQuoteRequest{
 			Address: nil,
 			Items:   items})
@@ -96,8 +213,16 @@ func (fe *frontendServer) getShippingQuote(ctx context.Context, items []*pb.Cart
 	return localized, errors.Wrap(err, "failed to convert currency for shipping cost")
 }
 
-func (fe *frontendServer) getRecommendations(ctx context.Context, userID string, productIDs []string) ([]*pb.Product, error) {
-	resp, err := pb.NewRecommendationServiceClient(fe.recommendationSvcConn).ListRecommendations(ctx,
+// getRecommendations returns up to four recommended products, or none if the
+// request turned recommendations off.
+func (fe *frontendServer) getRecommendations(ctx context.Context, userID string, productIDs []string) (_ []*pb.Product, err error) {
+	if recommendationsDisabled(ctx) {
+		return nil, nil
+	}
+	ctx, span := startSpan(ctx, "frontend.getRecommendations", attribute.Int("product.count", len(productIDs)))
+	defer func() { endSpan(span, err) }()
+
+	resp, err := fe.recommendationService().ListRecommendations(ctx,
 		&pb.ListRecommendationsRequest{UserId: userID, ProductIds: productIDs})
 	if err != nil {
 		return nil, err
@@ -110,17 +235,27 @@ func (fe *frontendServer) getRecommendations(ctx context.Context, userID string,
 		}
 		out[i] = p
 	}
+	if fe.recommender != nil {
+		processed, err := fe.recommender.apply(ctx, fe, userID, productIDs, out)
+		if err != nil {
+			// The service's own picks are still worth showing.
+			log.WithError(err).Warn("recommendation strategy failed, showing unprocessed recommendations")
+		} else {
+			out = processed
+		}
+	}
 	if len(out) > 4 {
 		out = out[:4] // take only first four to fit the UI
 	}
-	return out, err
+	span.SetAttributes(attribute.Int("recommendation.count", len(out)))
+	return out, nil
 }
 
 func (fe *frontendServer) getAd(ctx context.Context, ctxKeys []string) ([]*pb.Ad, error) {
 	ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
 	defer cancel()
 
-	resp, err := pb.NewAdServiceClient(fe.adSvcConn).GetAds(ctx, &pb.AdRequest{
+	resp, err := fe.adService().GetAds(ctx, &pb.AdRequest{
 		ContextKeys: ctxKeys,
 	})
 	return resp.GetAds(), errors.Wrap(err, "failed to get ads")
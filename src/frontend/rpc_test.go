@@ -0,0 +1,134 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+func TestGetCurrenciesAlwaysIncludesDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream []string
+		want     string
+	}{
+		{"empty", nil, "USD"},
+		{"default missing", []string{"EUR", "JPY"}, "EUR,JPY,USD"},
+		{"default present", []string{"EUR", "USD"}, "EUR,USD"},
+		{"only unsupported", []string{"XXX"}, "USD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			backends.currency.codes = tt.upstream
+
+			got, err := fe.getCurrencies(context.Background())
+			if err != nil {
+				t.Fatalf("getCurrencies: %v", err)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("got currencies %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// cartContents is userID's cart in the fake as "id×quantity", sorted.
+func cartContents(backends *fakeBackends, userID string) string {
+	backends.cart.mu.Lock()
+	defer backends.cart.mu.Unlock()
+	items := slices.Clone(backends.cart.carts[userID])
+	slices.SortFunc(items, func(a, b *pb.CartItem) int { return strings.Compare(a.GetProductId(), b.GetProductId()) })
+	return cartItemsString(items)
+}
+
+func TestRemoveFromCartRestoresCartOnFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int // AddItem calls that fail
+		wantErr     string
+		wantContent string
+	}{
+		{"re-add fails", 1, "the cart was restored", "P1×1, P2×2, P3×3"},
+		{"restore fails too", 100, "which held P1×1, P2×2, P3×3", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe, backends := newTestFrontend(t)
+			backends.cart.setCart(testSessionID,
+				&pb.CartItem{ProductId: "P1", Quantity: 1},
+				&pb.CartItem{ProductId: "P2", Quantity: 2},
+				&pb.CartItem{ProductId: "P3", Quantity: 3})
+			var adds atomic.Int32
+			backends.cart.onAdd = func(*pb.AddItemRequest) error {
+				if adds.Add(1) <= int32(tt.failures) {
+					return status.Error(codes.Unavailable, "cart is down")
+				}
+				return nil
+			}
+
+			err := fe.removeFromCart(context.Background(), testSessionID, "P1")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want one saying %q", err, tt.wantErr)
+			}
+			if got := cartContents(backends, testSessionID); got != tt.wantContent {
+				t.Errorf("cart holds %q, want %q", got, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestRemoveFromCartKeepsConcurrentAdds(t *testing.T) {
+	fe, backends := newTestFrontend(t)
+	backends.cart.setCart(testSessionID, &pb.CartItem{ProductId: "P1", Quantity: 1}, &pb.CartItem{ProductId: "P2", Quantity: 2})
+
+	// An add from another request arrives between removeFromCart reading
+	// the cart and emptying it. Without serializing, the empty would lose it.
+	var once sync.Once
+	added := make(chan error, 1)
+	backends.cart.onEmpty = func(string) {
+		once.Do(func() {
+			go func() {
+				added <- fe.mutateCart(context.Background(), testSessionID, func(ctx context.Context) error {
+					return fe.insertCart(ctx, testSessionID, "P9", 1)
+				})
+			}()
+			time.Sleep(20 * time.Millisecond)
+		})
+	}
+
+	if err := fe.mutateCart(context.Background(), testSessionID, func(ctx context.Context) error {
+		return fe.removeFromCart(ctx, testSessionID, "P1")
+	}); err != nil {
+		t.Fatalf("removing: %v", err)
+	}
+	if err := <-added; err != nil {
+		t.Fatalf("adding: %v", err)
+	}
+	if got, want := cartContents(backends, testSessionID), "P2×2, P9×1"; got != want {
+		t.Errorf("cart holds %q, want %q", got, want)
+	}
+}
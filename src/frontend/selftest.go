@@ -0,0 +1,164 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// selfTestUserID is the user the self-test reads a cart and recommendations
+// for. It never has a cart, so nothing real is touched.
+const selfTestUserID = "frontend-self-test"
+
+// selfTestResult is one backend's line in the self-test report.
+type selfTestResult struct {
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Critical bool   `json:"critical"`
+	Check    string `json:"check"` // "rpc" or "connection"
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Millis   int64  `json:"latency_ms"`
+}
+
+// selfTestReport is what SELF_TEST prints. Status is "fail" when any
+// critical backend is unreachable, and "pass" otherwise.
+type selfTestReport struct {
+	Status   string           `json:"status"`
+	Backends []selfTestResult `json:"backends"`
+}
+
+type selfTestCheck struct {
+	name     string
+	addr     string
+	critical bool
+	// rpc makes a cheap call. Backends without one have conn checked
+	// instead.
+	rpc  func(context.Context) error
+	conn *grpc.ClientConn
+}
+
+// selfTest checks each backend once, each within selfTestTimeout. The
+// backends every page needs are critical; the rest only degrade some pages.
+func (fe *frontendServer) selfTest(ctx context.Context) selfTestReport {
+	checks := []selfTestCheck{
+		{name: "productcatalog", addr: fe.productCatalogSvcAddr, critical: true, rpc: func(ctx context.Context) error {
+			_, err := fe.productCatalogService().ListProducts(ctx, &pb.Empty{})
+			return err
+		}},
+		{name: "currency", addr: fe.currencySvcAddr, critical: true, rpc: func(ctx context.Context) error {
+			_, err := fe.currencyService().GetSupportedCurrencies(ctx, &pb.Empty{})
+			return err
+		}},
+		{name: "cart", addr: fe.cartSvcAddr, critical: true, rpc: func(ctx context.Context) error {
+			_, err := fe.cartService().GetCart(ctx, &pb.GetCartRequest{UserId: selfTestUserID})
+			return err
+		}},
+		{name: "checkout", addr: fe.checkoutSvcAddr, critical: true, conn: fe.checkoutSvcConn},
+		{name: "recommendation", addr: fe.recommendationSvcAddr, rpc: func(ctx context.Context) error {
+			_, err := fe.recommendationService().ListRecommendations(ctx, &pb.ListRecommendationsRequest{UserId: selfTestUserID})
+			return err
+		}},
+		{name: "shipping", addr: fe.shippingSvcAddr, rpc: func(ctx context.Context) error {
+			_, err := fe.shippingService().GetQuote(ctx, &pb.GetQuoteRequest{})
+			return err
+		}},
+		{name: "ad", addr: fe.adSvcAddr, rpc: func(ctx context.Context) error {
+			_, err := fe.adService().GetAds(ctx, &pb.AdRequest{})
+			return err
+		}},
+		{name: "recipe", addr: fe.recipeSvcAddr, rpc: func(ctx context.Context) error {
+			_, err := fe.recipeService().GetRecipe(ctx, &pb.GetRecipeRequest{RecipeId: selfTestUserID})
+			return err
+		}},
+	}
+
+	report := selfTestReport{Status: "pass", Backends: make([]selfTestResult, len(checks))}
+	for i, c := range checks {
+		res := selfTestResult{Name: c.name, Address: c.addr, Critical: c.critical, Check: "rpc"}
+		start := time.Now()
+		checkCtx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+		var err error
+		if c.rpc != nil {
+			err = selfTestRPCError(c.rpc(checkCtx))
+		} else {
+			res.Check = "connection"
+			err = waitForReady(checkCtx, c.conn)
+		}
+		cancel()
+		res.Millis = time.Since(start).Milliseconds()
+		res.OK = err == nil
+		if err != nil {
+			res.Error = err.Error()
+			if c.critical {
+				report.Status = "fail"
+			}
+		}
+		report.Backends[i] = res
+	}
+	return report
+}
+
+// selfTestRPCError is err if it means the backend couldn't be reached. Any
+// other answer, such as NotFound or a missing method, shows it is up.
+func selfTestRPCError(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return err
+	}
+	return nil
+}
+
+// waitForReady connects conn and waits until it is ready or ctx is done.
+func waitForReady(ctx context.Context, conn *grpc.ClientConn) error {
+	if conn == nil {
+		return status.Error(codes.Unavailable, "not connected")
+	}
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return status.Errorf(codes.Unavailable, "connection is %s", state)
+		}
+	}
+}
+
+// runSelfTest writes the self-test report to out as JSON and returns the
+// process exit code: 1 if a critical backend is unreachable.
+func (fe *frontendServer) runSelfTest(ctx context.Context, out io.Writer) int {
+	report := fe.selfTest(ctx)
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return 1
+	}
+	if report.Status != "pass" {
+		return 1
+	}
+	return 0
+}
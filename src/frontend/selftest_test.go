@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// unreachableConn is a connection to a backend that refuses every dial.
+func unreachableConn(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient("passthrough:///down",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return nil, errors.New("connection refused") }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestSelfTest(t *testing.T) {
+	defer func(old time.Duration) { selfTestTimeout = old }(selfTestTimeout)
+	selfTestTimeout = time.Second
+
+	tests := []struct {
+		name     string
+		down     func(fe *frontendServer, conn *grpc.ClientConn)
+		wantCode int
+		wantDown string
+	}{
+		{"all reachable", func(*frontendServer, *grpc.ClientConn) {}, 0, ""},
+		{"critical backend down", func(fe *frontendServer, conn *grpc.ClientConn) { fe.cartSvcConn = conn }, 1, "cart"},
+		{"critical connection down", func(fe *frontendServer, conn *grpc.ClientConn) { fe.checkoutSvcConn = conn }, 1, "checkout"},
+		{"optional backend down", func(fe *frontendServer, conn *grpc.ClientConn) { fe.adSvcConn = conn }, 0, "ad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fe, _ := newTestFrontend(t)
+			tt.down(fe, unreachableConn(t))
+
+			var out bytes.Buffer
+			if code := fe.runSelfTest(context.Background(), &out); code != tt.wantCode {
+				t.Errorf("got exit code %d, want %d", code, tt.wantCode)
+			}
+			var report selfTestReport
+			if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+				t.Fatalf("decoding report %q: %v", out.String(), err)
+			}
+			if wantStatus := map[int]string{0: "pass", 1: "fail"}[tt.wantCode]; report.Status != wantStatus {
+				t.Errorf("got status %q, want %q", report.Status, wantStatus)
+			}
+			for _, b := range report.Backends {
+				if wantOK := b.Name != tt.wantDown; b.OK != wantOK {
+					t.Errorf("%s: got ok %v (%s), want %v", b.Name, b.OK, b.Error, wantOK)
+				}
+			}
+		})
+	}
+}
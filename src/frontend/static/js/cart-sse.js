@@ -26,8 +26,40 @@ class CartSSE {
       }
     };
 
+    // Announcements are sent to everyone when the server broadcasts recipe
+    // adds; they never carry cart contents.
+    this.eventSource.addEventListener("announcement", (event) => {
+      try {
+        const announcement = JSON.parse(event.data);
+        console.log("Received announcement:", announcement.message);
+      } catch (error) {
+        console.warn("Failed to parse announcement:", error);
+      }
+    });
+
+    // Sent instead of the initial cart when the server couldn't read it, so
+    // fetch the count rather than showing an empty cart.
+    this.eventSource.addEventListener("cart-error", () => {
+      fetch("/api/cart/summary", { headers: { Accept: "application/json" } })
+        .then((response) => (response.ok ? response.json() : null))
+        .then((summary) => summary && this.updateCartDisplay(summary))
+        .catch((error) => console.warn("Failed to refetch cart:", error));
+    });
+
+    // Sent when this frontend is shutting down. Reconnecting gets another
+    // replica; the jitter keeps every client from arriving at once.
+    this.eventSource.addEventListener("reconnect", () => {
+      this.eventSource.close();
+      setTimeout(() => this.connect(), Math.random() * 1000);
+    });
+
     this.eventSource.onerror = (error) => {
       console.warn("SSE connection error:", error);
+      // While the browser is reconnecting it sends Last-Event-ID, which a
+      // new EventSource would lose, so only replace one that gave up.
+      if (this.eventSource.readyState !== EventSource.CLOSED) {
+        return;
+      }
       // Reconnect after a delay
       setTimeout(() => this.connect(), 5000);
     };
@@ -261,7 +293,10 @@ class CartSSE {
         throw new Error(`HTTP ${response.status}`);
       }
 
-      const suggestedRecipes = await response.json();
+      const { recipes: suggestedRecipes, reason } = await response.json();
+      if (reason === "service_error") {
+        throw new Error("recipe service unavailable");
+      }
 
       // Hide loading state
       loadingState.style.display = "none";
@@ -319,7 +354,7 @@ class CartSSE {
 
       // Set up click handler for the entire card
       cardElement.onclick = () =>
-        (window.location.href = `/suggested-recipe/${recipe.recipe_id || ""}`);
+        (window.location.href = `/suggested-recipe/${recipe.stable_id || recipe.recipe_id || ""}`);
 
       // Populate text content first
       card.querySelector(".recipe-title-link").textContent = recipe.title || "Suggested Recipe";
@@ -485,12 +520,20 @@ class CartSSE {
     const poll = async () => {
       if (attempts >= maxAttempts || recipesToPoll.length === 0) {
         console.log("Stopping image polling.");
-        // On final attempt, hide any remaining loading overlays
+        // On final attempt, swap any remaining loading overlays for the
+        // placeholder image
         recipesToPoll.forEach(recipe => {
             const card = document.getElementById(`recipe-${recipe.recipe_id}`);
             if (card) {
                 const loadingOverlay = card.querySelector(".loading-overlay");
                 if (loadingOverlay) loadingOverlay.style.display = "none";
+                const imageContainer = card.querySelector(".recipe-image-container");
+                if (recipe.placeholder_image && imageContainer && !imageContainer.querySelector("img")) {
+                    const img = document.createElement("img");
+                    img.src = recipe.placeholder_image;
+                    img.alt = recipe.title;
+                    imageContainer.appendChild(img);
+                }
             }
         });
         return;
@@ -511,7 +554,8 @@ class CartSSE {
 
         if (!response.ok) throw new Error(`Polling failed with status ${response.status}`);
 
-        const updatedRecipes = await response.json();
+        const { recipes: updatedRecipes, reason } = await response.json();
+        if (reason === "service_error") throw new Error("Polling failed: recipe service unavailable");
         
         // Create a map for quick lookup
         const updatedRecipeMap = new Map(updatedRecipes.map(r => [r.recipe_id, r]));
@@ -615,7 +659,10 @@ class CartSSE {
         throw new Error(`HTTP ${response.status}`);
       }
 
-      const suggestedRecipes = await response.json();
+      const { recipes: suggestedRecipes, reason } = await response.json();
+      if (reason === "service_error") {
+        throw new Error("recipe service unavailable");
+      }
 
       // Hide loading state
       loadingState.style.display = "none";
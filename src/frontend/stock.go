@@ -0,0 +1,31 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+
+// productStock reports how many of p are available and whether that is
+// known. The catalog doesn't carry stock yet, so it is always unknown until a
+// source is wired in here. Tests replace it.
+var productStock = func(p *pb.Product) (int, bool) {
+	return 0, false
+}
+
+// isLowStock reports whether p should be shown as running out: its stock is
+// known and at or below lowStockThreshold. Unknown stock is never low.
+func isLowStock(p *pb.Product) bool {
+	n, ok := productStock(p)
+	return ok && n <= lowStockThreshold
+}
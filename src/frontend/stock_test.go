@@ -0,0 +1,84 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// stubStock makes productStock report the given stock by product ID.
+// Products not in stock have unknown stock.
+func stubStock(stock map[string]int) func() {
+	old := productStock
+	productStock = func(p *pb.Product) (int, bool) {
+		n, ok := stock[p.GetId()]
+		return n, ok
+	}
+	return func() { productStock = old }
+}
+
+func TestIsLowStock(t *testing.T) {
+	defer func(old int) { lowStockThreshold = old }(lowStockThreshold)
+	lowStockThreshold = 5
+	defer stubStock(map[string]int{"below": 2, "at": 5, "above": 6})()
+
+	for id, want := range map[string]bool{
+		"below":   true,
+		"at":      true,
+		"above":   false,
+		"unknown": false,
+	} {
+		if got := isLowStock(&pb.Product{Id: id}); got != want {
+			t.Errorf("isLowStock(%s) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestLowStockBadge(t *testing.T) {
+	defer func(old int) { lowStockThreshold = old }(lowStockThreshold)
+	lowStockThreshold = 5
+	defer stubStock(map[string]int{"LOW": 1, "PLENTY": 50})()
+
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(
+		&pb.Product{Id: "LOW", Name: "Saffron", PriceUsd: usd(9, 0)},
+		&pb.Product{Id: "PLENTY", Name: "Rice", PriceUsd: usd(2, 0)},
+		&pb.Product{Id: "UNKNOWN", Name: "Basil", PriceUsd: usd(1, 0)},
+	)
+
+	rr := httptest.NewRecorder()
+	fe.homeHandler(rr, newTestRequest(http.MethodGet, "/", nil))
+	if n := strings.Count(rr.Body.String(), `class="low-stock-badge"`); n != 1 {
+		t.Errorf("home page has %d low stock badges, want 1", n)
+	}
+
+	for id, want := range map[string]bool{"LOW": true, "PLENTY": false, "UNKNOWN": false} {
+		rr := httptest.NewRecorder()
+		fe.productHandler(rr, mux.SetURLVars(newTestRequest(http.MethodGet, "/product/"+id, nil), map[string]string{"id": id}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("product %s: got status %d, want 200", id, rr.Code)
+		}
+		if got := strings.Contains(rr.Body.String(), `class="low-stock-badge"`); got != want {
+			t.Errorf("product %s shows a low stock badge: %v, want %v", id, got, want)
+		}
+	}
+}
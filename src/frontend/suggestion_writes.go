@@ -0,0 +1,53 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "sync"
+
+// suggestionWrites orders writes to suggestedRecipesCache. Two tabs asking
+// for suggestions at once each store their own set, and the slower one would
+// otherwise replace the set made from the newer cart. The zero value is ready
+// to use.
+type suggestionWrites struct {
+	mu     sync.Mutex
+	next   uint64
+	stored map[string]uint64 // cache key -> generation of the stored set
+}
+
+// begin reserves a generation for a set of suggestions about to be
+// requested. Generations are reserved before the request so the order they
+// were asked for wins, not the order they came back in.
+func (s *suggestionWrites) begin() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	return s.next
+}
+
+// store caches recipes under key unless a set from a later generation is
+// already there, and reports whether it did.
+func (s *suggestionWrites) store(cache *sync.Map, key string, gen uint64, recipes []CachedRecipe) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if gen < s.stored[key] {
+		return false
+	}
+	if s.stored == nil {
+		s.stored = make(map[string]uint64)
+	}
+	s.stored[key] = gen
+	cache.Store(key, recipes)
+	return true
+}
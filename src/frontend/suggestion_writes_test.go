@@ -0,0 +1,70 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSuggestionWritesKeepsLaterGeneration(t *testing.T) {
+	var cache sync.Map
+	var s suggestionWrites
+	older, newer := s.begin(), s.begin()
+
+	if !s.store(&cache, "u1", newer, []CachedRecipe{{RecipeId: "newer"}}) {
+		t.Fatal("newer set was not stored")
+	}
+	if s.store(&cache, "u1", older, []CachedRecipe{{RecipeId: "older"}}) {
+		t.Error("older set replaced the newer one")
+	}
+	if got, _ := cache.Load("u1"); got.([]CachedRecipe)[0].RecipeId != "newer" {
+		t.Errorf("cached %v, want the newer set", got)
+	}
+	// Other sessions aren't affected.
+	if !s.store(&cache, "u2", older, []CachedRecipe{{RecipeId: "older"}}) {
+		t.Error("set for another session was not stored")
+	}
+}
+
+func TestSuggestionWritesConcurrentStores(t *testing.T) {
+	var cache sync.Map
+	var s suggestionWrites
+	gens := make(chan uint64, 50)
+	var wg sync.WaitGroup
+	for range cap(gens) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gen := s.begin()
+			gens <- gen
+			id := strconv.FormatUint(gen, 10)
+			s.store(&cache, testSessionID, gen, []CachedRecipe{{RecipeId: id, Title: id}})
+		}()
+	}
+	wg.Wait()
+	close(gens)
+
+	var latest uint64
+	for gen := range gens {
+		latest = max(latest, gen)
+	}
+	got, _ := cache.Load(testSessionID)
+	recipes := got.([]CachedRecipe)
+	if want := strconv.FormatUint(latest, 10); len(recipes) != 1 || recipes[0].RecipeId != want || recipes[0].Title != want {
+		t.Errorf("cached %+v, want only the set from generation %s", recipes, want)
+	}
+}
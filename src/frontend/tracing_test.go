@@ -0,0 +1,99 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	pb "github.com/GoogleCloudPlatform/microservices-demo/src/frontend/genproto"
+)
+
+// recordSpans installs a tracer provider that records every ended span for
+// the rest of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(old)
+		tp.Shutdown(context.Background())
+	})
+	return rec
+}
+
+func spanAttr(s sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestViewCartHandlerRecordsSpans(t *testing.T) {
+	rec := recordSpans(t)
+	fe, backends := newTestFrontend(t)
+	backends.catalog.setProducts(
+		&pb.Product{Id: "P1", Name: "Pasta", PriceUsd: usd(3, 0)},
+		&pb.Product{Id: "P2", Name: "Basil", PriceUsd: usd(1, 0)})
+	backends.cart.setCart(testSessionID,
+		&pb.CartItem{ProductId: "P1", Quantity: 2},
+		&pb.CartItem{ProductId: "P2", Quantity: 1})
+
+	rr := httptest.NewRecorder()
+	fe.viewCartHandler(rr, newTestRequest(http.MethodGet, "/cart", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+
+	byName := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range rec.Ended() {
+		byName[s.Name()] = append(byName[s.Name()], s)
+	}
+	for _, name := range []string{"frontend.cartItems", "frontend.getShippingQuote", "frontend.getRecommendations"} {
+		if len(byName[name]) != 1 {
+			t.Errorf("got %d %s spans, want 1", len(byName[name]), name)
+		}
+	}
+
+	items := byName["frontend.cartItems"]
+	if len(items) == 1 {
+		if v, _ := spanAttr(items[0], "cart.item_count"); v.AsInt64() != 2 {
+			t.Errorf("got cart.item_count %v, want 2", v.Emit())
+		}
+	}
+	quantities := map[string]int64{}
+	for _, s := range byName["frontend.cartItem"] {
+		id, _ := spanAttr(s, "product_id")
+		qty, _ := spanAttr(s, "quantity")
+		quantities[id.AsString()] = qty.AsInt64()
+		if len(items) == 1 && s.Parent().SpanID() != items[0].SpanContext().SpanID() {
+			t.Errorf("cart item span for %s is not a child of frontend.cartItems", id.AsString())
+		}
+	}
+	if len(quantities) != 2 || quantities["P1"] != 2 || quantities["P2"] != 1 {
+		t.Errorf("got cart item spans %v, want P1:2 and P2:1", quantities)
+	}
+}
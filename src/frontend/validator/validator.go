@@ -17,6 +17,8 @@ package validator
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -34,9 +36,18 @@ type Payload interface {
 	Validate() error
 }
 
+// DefaultMaxQuantity is the most that can be added to the cart at once when
+// AddToCartPayload.MaxQuantity is unset.
+const DefaultMaxQuantity = 10
+
 type AddToCartPayload struct {
-	Quantity  uint64 `validate:"required,gte=1,lte=10"`
+	// Quantity is cast to int32 for the cart service, so it must fit.
+	// Zero is rejected by gte rather than required, so the error names the
+	// minimum.
+	Quantity  uint64 `validate:"gte=1,lte=2147483647,ltefield=MaxQuantity"`
 	ProductID string `validate:"required"`
+	// MaxQuantity is the per-add limit; zero means DefaultMaxQuantity.
+	MaxQuantity uint64 `validate:"lte=2147483647"`
 }
 
 type PlaceOrderPayload struct {
@@ -52,13 +63,97 @@ type PlaceOrderPayload struct {
 	CcCVV         int64  `validate:"required"`
 }
 
+// CreditCardInput is the credit card from the checkout form, parsed by
+// ParseCreditCardInput.
+type CreditCardInput struct {
+	Number string
+	Month  int32
+	Year   int32
+	CVV    int32
+}
+
+// ParseCreditCardInput parses the checkout form's credit card fields. Spaces
+// and dashes in the number are ignored. Every field that is not a number, a
+// number that fails the Luhn check, and a CVV that is not 3 or 4 digits is
+// reported, in the same format as ValidationErrorResponse.
+func ParseCreditCardInput(number, month, year, cvv string) (CreditCardInput, error) {
+	var card CreditCardInput
+	var msg string
+	fail := func(field, tag string) {
+		msg += fmt.Sprintf("Field '%s' is invalid: %s\n", field, tag)
+	}
+
+	card.Number = strings.NewReplacer(" ", "", "-", "").Replace(number)
+	if !isDigits(card.Number) {
+		fail("CcNumber", "numeric")
+	} else if !luhnValid(card.Number) {
+		fail("CcNumber", "luhn")
+	}
+	if n, err := strconv.ParseInt(month, 10, 32); err != nil {
+		fail("CcMonth", "numeric")
+	} else {
+		card.Month = int32(n)
+	}
+	if n, err := strconv.ParseInt(year, 10, 32); err != nil {
+		fail("CcYear", "numeric")
+	} else {
+		card.Year = int32(n)
+	}
+	if !isDigits(cvv) {
+		fail("CcCVV", "numeric")
+	} else if len(cvv) < 3 || len(cvv) > 4 {
+		fail("CcCVV", "len")
+	} else {
+		n, _ := strconv.ParseInt(cvv, 10, 32)
+		card.CVV = int32(n)
+	}
+
+	if msg != "" {
+		return CreditCardInput{}, errors.New(msg)
+	}
+	return card, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// luhnValid reports whether digits passes the Luhn checksum.
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
 type SetCurrencyPayload struct {
 	Currency string `validate:"required,iso4217"`
 }
 
 // Implementations of the 'Payload' interface.
 func (ad *AddToCartPayload) Validate() error {
-	return validate.Struct(ad)
+	p := *ad
+	if p.MaxQuantity == 0 {
+		p.MaxQuantity = DefaultMaxQuantity
+	}
+	return validate.Struct(&p)
 }
 
 func (po *PlaceOrderPayload) Validate() error {
@@ -69,6 +164,12 @@ func (sc *SetCurrencyPayload) Validate() error {
 	return validate.Struct(sc)
 }
 
+// fieldMessages replaces the generic message for a field that fails a tag,
+// keyed by "Field.tag".
+var fieldMessages = map[string]string{
+	"Quantity.gte": "quantity must be at least 1",
+}
+
 // Reusable error response function.
 func ValidationErrorResponse(err error) error {
 	validationErrs, ok := err.(validator.ValidationErrors)
@@ -77,6 +178,10 @@ func ValidationErrorResponse(err error) error {
 	}
 	var msg string
 	for _, err := range validationErrs {
+		if m, ok := fieldMessages[err.Field()+"."+err.Tag()]; ok {
+			msg += m + "\n"
+			continue
+		}
 		msg += fmt.Sprintf("Field '%s' is invalid: %s\n", err.Field(), err.Tag())
 	}
 	return fmt.Errorf(msg)
@@ -103,6 +103,42 @@ func TestPlaceOrderFailsValidation(t *testing.T) {
 	}
 }
 
+func TestParseCreditCardInput(t *testing.T) {
+	card, err := ParseCreditCardInput("4432 8015 6152 0454", "1", "2039", "072")
+	if err != nil {
+		t.Fatalf("valid card: %v", err)
+	}
+	want := CreditCardInput{Number: "4432801561520454", Month: 1, Year: 2039, CVV: 72}
+	if card != want {
+		t.Errorf("got %+v, want %+v", card, want)
+	}
+
+	tests := []struct {
+		name                     string
+		number, month, year, cvv string
+		wantField                string
+	}{
+		{"non-numeric number", "4432-8015-6152-045x", "1", "2039", "672", "CcNumber"},
+		{"failing Luhn", "4432801561520455", "1", "2039", "672", "CcNumber"},
+		{"non-numeric month", "4432801561520454", "jan", "2039", "672", "CcMonth"},
+		{"missing year", "4432801561520454", "1", "", "672", "CcYear"},
+		{"non-numeric cvv", "4432801561520454", "1", "2039", "6a2", "CcCVV"},
+		{"short cvv", "4432801561520454", "1", "2039", "67", "CcCVV"},
+		{"long cvv", "4432801561520454", "1", "2039", "67212", "CcCVV"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCreditCardInput(tt.number, tt.month, tt.year, tt.cvv)
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if !strings.Contains(err.Error(), "'"+tt.wantField+"'") {
+				t.Errorf("got error %q, want one naming %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
 func TestAddToCartPassesValidation(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -132,6 +168,7 @@ func TestAddToCartFailsValidation(t *testing.T) {
 		{"invalid max quantity", 11, "OLJCESPC7Z"},
 		{"invalid product id", 1, ""},
 		{"invalid quantity and product id", 0, ""},
+		{"quantity above int32 max", 1 << 31, "OLJCESPC7Z"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -143,6 +180,38 @@ func TestAddToCartFailsValidation(t *testing.T) {
 	}
 }
 
+func TestAddToCartConfiguredMaxQuantity(t *testing.T) {
+	tests := []struct {
+		name        string
+		quantity    uint64
+		maxQuantity uint64
+		wantErr     bool
+	}{
+		{"at configured max", 25, 25, false},
+		{"above configured max", 26, 25, true},
+		{"above int32 max despite configured max", 1 << 31, 1 << 31, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := AddToCartPayload{Quantity: tt.quantity, ProductID: "OLJCESPC7Z", MaxQuantity: tt.maxQuantity}
+			if err := payload.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() on %v = %v, wantErr %v", payload, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddToCartZeroQuantityMessage(t *testing.T) {
+	payload := AddToCartPayload{Quantity: 0, ProductID: "OLJCESPC7Z"}
+	err := payload.Validate()
+	if err == nil {
+		t.Fatal("want zero quantity to fail validation")
+	}
+	if got := ValidationErrorResponse(err).Error(); got != "quantity must be at least 1\n" {
+		t.Errorf("got message %q, want %q", got, "quantity must be at least 1\n")
+	}
+}
+
 func TestSetCurrencyPassesValidation(t *testing.T) {
 	tests := []struct {
 		name     string